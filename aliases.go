@@ -0,0 +1,256 @@
+package goterm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// aliasFilePath returns the path of the file aliases are saved in, next to
+// the config file
+func aliasFilePath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("could not get config directory: %v", err)
+	}
+	return filepath.Join(configDir, "go-term", "aliases"), nil
+}
+
+// Alias returns the command line an alias stands for
+func (t *Terminal) Alias(name string) (string, bool) {
+	t.aliasMu.RLock()
+	defer t.aliasMu.RUnlock()
+	value, ok := t.aliases[name]
+	return value, ok
+}
+
+// Aliases returns a copy of the alias table
+func (t *Terminal) Aliases() map[string]string {
+	t.aliasMu.RLock()
+	defer t.aliasMu.RUnlock()
+	aliases := make(map[string]string, len(t.aliases))
+	for name, value := range t.aliases {
+		aliases[name] = value
+	}
+	return aliases
+}
+
+// SetAlias makes name stand for a command line, which may hold several
+// commands. Aliases are only expanded where a command name is expected.
+func (t *Terminal) SetAlias(name, value string) error {
+	// Aliases from sourced files, such as the rc file, live in those files
+	return t.setAlias(name, value, t.sourcing > 0)
+}
+
+// validAliasName reports whether name can be used for an alias
+func validAliasName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, shellSpecial+"=/")
+}
+
+// setAlias sets an alias, saving it to the alias file unless it is defined
+// elsewhere, such as in a sourced file or the config file
+func (t *Terminal) setAlias(name, value string, external bool) error {
+	if !validAliasName(name) {
+		return fmt.Errorf("%q: invalid alias name", name)
+	}
+	t.aliasMu.Lock()
+	defer t.aliasMu.Unlock()
+	if t.aliases == nil {
+		t.aliases = make(map[string]string)
+		t.aliasSourced = make(map[string]bool)
+	}
+	t.aliases[name] = value
+	t.aliasSourced[name] = external
+	return t.saveAliases()
+}
+
+// RemoveAlias removes an alias, reporting whether there was one
+func (t *Terminal) RemoveAlias(name string) (bool, error) {
+	t.aliasMu.Lock()
+	defer t.aliasMu.Unlock()
+	if _, ok := t.aliases[name]; !ok {
+		return false, nil
+	}
+	delete(t.aliases, name)
+	delete(t.aliasSourced, name)
+	return true, t.saveAliases()
+}
+
+// LoadAliases reads aliases from path, which is also where they are saved
+// when they change. Each line is an alias command, `alias name='value'`. A
+// missing file is not an error.
+func (t *Terminal) LoadAliases(path string) error {
+	t.aliasMu.Lock()
+	t.aliasFile = ""
+	t.aliasMu.Unlock()
+	// Only save to the file once it has been read, or the aliases in it
+	// would be lost
+	defer func() {
+		t.aliasMu.Lock()
+		t.aliasFile = path
+		t.aliasMu.Unlock()
+	}()
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields, err := tokenize(line)
+		if err == nil && (len(fields) != 2 || fields[0] != "alias" || !strings.Contains(fields[1], "=")) {
+			err = fmt.Errorf("expected alias name='value'")
+		}
+		if err == nil {
+			name, value, _ := strings.Cut(fields[1], "=")
+			err = t.SetAlias(name, value)
+		}
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", path, lineNum, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// formatAlias writes an alias as the alias command that defines it
+func formatAlias(name, value string) string {
+	return "alias " + name + "=" + "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// saveAliases writes the aliases to the file they were loaded from, leaving
+// out those defined by sourced files. The caller must hold t.aliasMu.
+func (t *Terminal) saveAliases() error {
+	if t.aliasFile == "" {
+		return nil
+	}
+	names := make([]string, 0, len(t.aliases))
+	for name := range t.aliases {
+		if !t.aliasSourced[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(formatAlias(name, t.aliases[name]) + "\n")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(t.aliasFile), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(t.aliasFile, []byte(b.String()), 0644)
+}
+
+// expandAliases replaces the aliases used as command names in line with the
+// commands they stand for. Quoting or escaping any part of a name stops it
+// from being expanded. The commands of an alias are expanded in turn, but an
+// alias is never expanded inside itself, so `alias ls='ls -F'` works. Lines
+// go-term leaves to the shell only have their first command expanded.
+func expandAliases(line string, lookup func(string) (string, bool), expanding map[string]bool) string {
+	tokens, err := lexCommandLine(line)
+	if err != nil {
+		tokens = nil
+		if words := splitWords(line); len(words) > 0 {
+			w := words[0]
+			tokens = []token{{kind: tokenWord, text: string([]rune(line)[w.start:w.end]), start: w.start, end: w.end}}
+		}
+	}
+
+	runes := []rune(line)
+	var b strings.Builder
+	last := 0
+	commandStart := true
+	for _, tok := range tokens {
+		if tok.kind == tokenWord && commandStart && !expanding[tok.text] {
+			if value, ok := lookup(tok.text); ok {
+				nested := map[string]bool{tok.text: true}
+				for name := range expanding {
+					nested[name] = true
+				}
+				b.WriteString(string(runes[last:tok.start]))
+				b.WriteString(expandAliases(value, lookup, nested))
+				last = tok.end
+			}
+		}
+		switch tok.kind {
+		case tokenWord, tokenRedirect:
+			commandStart = false
+		default:
+			commandStart = true
+		}
+	}
+	b.WriteString(string(runes[last:]))
+	return b.String()
+}
+
+// AliasCommand runs the alias builtin. `alias name=value` defines an alias,
+// `alias name` shows one and `alias` on its own lists them all, to out.
+func (t *Terminal) AliasCommand(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		aliases := t.Aliases()
+		names := make([]string, 0, len(aliases))
+		for name := range aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintln(out, formatAlias(name, aliases[name]))
+		}
+		return nil
+	}
+
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			value, found := t.Alias(name)
+			if !found {
+				return fmt.Errorf("alias: %s: not found", name)
+			}
+			fmt.Fprintln(out, formatAlias(name, value))
+			continue
+		}
+		if err := t.SetAlias(name, value); err != nil {
+			return fmt.Errorf("alias: %v", err)
+		}
+	}
+	return nil
+}
+
+// UnaliasCommand runs `unalias name...`, or `unalias -a` to remove every
+// alias
+func (t *Terminal) UnaliasCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: unalias [-a] name...")
+	}
+	if len(args) == 1 && args[0] == "-a" {
+		for name := range t.Aliases() {
+			if _, err := t.RemoveAlias(name); err != nil {
+				return fmt.Errorf("unalias: %v", err)
+			}
+		}
+		return nil
+	}
+	for _, name := range args {
+		found, err := t.RemoveAlias(name)
+		if err != nil {
+			return fmt.Errorf("unalias: %v", err)
+		}
+		if !found {
+			return fmt.Errorf("unalias: %s: not found", name)
+		}
+	}
+	return nil
+}
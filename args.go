@@ -0,0 +1,260 @@
+package goterm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ArgumentCompleter completes the arguments of a command using the rule
+// registered for that command, or Fallback for commands without one.
+// Variable references such as $HOME are completed by Variables instead,
+// whatever the command. A command that is an alias is completed as the
+// command it stands for.
+type ArgumentCompleter struct {
+	Rules     map[string]Completer
+	Fallback  Completer
+	Variables Completer
+	Aliases   func(name string) (string, bool) // Looks up aliases, if set
+}
+
+// RegisterArgumentCompleter sets the completer used for the arguments of
+// command, replacing the file completion used by default
+func (t *Terminal) RegisterArgumentCompleter(command string, c Completer) {
+	t.arguments.Rules[command] = c
+}
+
+// defaultArgumentRules returns the standard argument completers, sharing
+// files for directory completion
+func defaultArgumentRules(files *FileCompleter) map[string]Completer {
+	dirs := &DirCompleter{Files: files}
+	env := &EnvCompleter{}
+	return map[string]Completer{
+		"cd":     dirs,
+		"pushd":  dirs,
+		"kill":   &ProcessCompleter{},
+		"export": env,
+		"unset":  env,
+		"git":    &GitCompleter{Files: files},
+	}
+}
+
+// Complete implements Completer
+func (a *ArgumentCompleter) Complete(line string, pos int) []Completion {
+	return a.CompleteContext(context.Background(), line, pos)
+}
+
+// CompleteContext implements ContextCompleter
+func (a *ArgumentCompleter) CompleteContext(ctx context.Context, line string, pos int) []Completion {
+	if _, isCommand := wordAt(line, pos); isCommand {
+		return nil
+	}
+	line, pos = a.expandAlias(line, pos)
+
+	c := a.Fallback
+	fields := shellFields(string([]rune(line)[:pos]))
+	if rule, ok := a.Rules[fields[0]]; ok {
+		c = rule
+	}
+	if _, _, ok := variableRef(line, pos); ok {
+		c = a.Variables
+	}
+	if c == nil {
+		return nil
+	}
+	if cc, ok := c.(ContextCompleter); ok {
+		return cc.CompleteContext(ctx, line, pos)
+	}
+	return c.Complete(line, pos)
+}
+
+// expandAlias replaces an alias at the start of line with the command it
+// stands for, moving pos along with the text after it. Completions replace
+// text before pos, so they apply to the original line just the same.
+func (a *ArgumentCompleter) expandAlias(line string, pos int) (string, int) {
+	if a.Aliases == nil {
+		return line, pos
+	}
+	words := splitWords(line)
+	if len(words) == 0 || pos <= words[0].end {
+		return line, pos
+	}
+	runes := []rune(line)
+	name := string(runes[words[0].start:words[0].end])
+	value, ok := a.Aliases(name)
+	if !ok {
+		return line, pos
+	}
+	expanded := string(runes[:words[0].start]) + value + string(runes[words[0].end:])
+	return expanded, pos + len([]rune(value)) - len([]rune(name))
+}
+
+// SetMatchMode sets the match mode of every rule and the fallback
+func (a *ArgumentCompleter) SetMatchMode(mode MatchMode) {
+	for _, c := range a.Rules {
+		if s, ok := c.(matchModeSetter); ok {
+			s.SetMatchMode(mode)
+		}
+	}
+	if s, ok := a.Fallback.(matchModeSetter); ok {
+		s.SetMatchMode(mode)
+	}
+	if s, ok := a.Variables.(matchModeSetter); ok {
+		s.SetMatchMode(mode)
+	}
+}
+
+// DirCompleter offers only directories, for commands such as cd
+type DirCompleter struct {
+	Files *FileCompleter
+}
+
+// Complete implements Completer
+func (d *DirCompleter) Complete(line string, pos int) []Completion {
+	return d.CompleteContext(context.Background(), line, pos)
+}
+
+// CompleteContext implements ContextCompleter
+func (d *DirCompleter) CompleteContext(ctx context.Context, line string, pos int) []Completion {
+	return d.Files.complete(ctx, line, pos, true)
+}
+
+// ProcessCompleter offers the IDs of running processes, described by their
+// names. It reads /proc and offers nothing on systems without it.
+type ProcessCompleter struct {
+	Mode MatchMode
+}
+
+// Complete implements Completer
+func (p *ProcessCompleter) Complete(line string, pos int) []Completion {
+	w, _ := wordAt(line, pos)
+
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+
+	var completions []Completion
+	for _, entry := range entries {
+		pid := entry.Name()
+		if _, err := strconv.Atoi(pid); err != nil {
+			continue
+		}
+		score, matched, ok := match(p.Mode, w.text, pid)
+		if !ok {
+			continue
+		}
+		name, _ := os.ReadFile(filepath.Join("/proc", pid, "comm"))
+		completions = append(completions, Completion{
+			Text:        quoteWord(pid, w.openQuote, true),
+			Display:     pid,
+			Kind:        CompletionCustom,
+			Description: strings.TrimSpace(string(name)),
+			Replace:     pos - w.start,
+			Score:       score,
+			Matched:     matched,
+		})
+	}
+
+	// Order numerically rather than by name
+	sort.SliceStable(completions, func(i, j int) bool {
+		a, _ := strconv.Atoi(completions[i].Display)
+		b, _ := strconv.Atoi(completions[j].Display)
+		return a < b
+	})
+	sortByScore(completions)
+	return completions
+}
+
+// SetMatchMode implements matchModeSetter
+func (p *ProcessCompleter) SetMatchMode(mode MatchMode) {
+	p.Mode = mode
+}
+
+// EnvCompleter offers the names of environment variables, described by
+// their values
+type EnvCompleter struct {
+	Mode MatchMode
+}
+
+// Complete implements Completer
+func (e *EnvCompleter) Complete(line string, pos int) []Completion {
+	w, _ := wordAt(line, pos)
+
+	var completions []Completion
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		score, matched, ok := match(e.Mode, w.text, name)
+		if !ok {
+			continue
+		}
+		completions = append(completions, Completion{
+			Text:        quoteWord(name, w.openQuote, true),
+			Display:     name,
+			Kind:        CompletionCustom,
+			Description: value,
+			Replace:     pos - w.start,
+			Score:       score,
+			Matched:     matched,
+		})
+	}
+	sort.SliceStable(completions, func(i, j int) bool { return completions[i].Display < completions[j].Display })
+	sortByScore(completions)
+	return completions
+}
+
+// SetMatchMode implements matchModeSetter
+func (e *EnvCompleter) SetMatchMode(mode MatchMode) {
+	e.Mode = mode
+}
+
+// VariableCompleter completes a $NAME or ${NAME reference at the cursor to
+// the names of environment variables, described by their values
+type VariableCompleter struct {
+	Mode MatchMode
+}
+
+// Complete implements Completer
+func (v *VariableCompleter) Complete(line string, pos int) []Completion {
+	start, braced, ok := variableRef(line, pos)
+	if !ok {
+		return nil
+	}
+	prefix, suffix := "$", ""
+	if braced {
+		prefix, suffix = "${", "}"
+	}
+	typed := string([]rune(line)[start+len(prefix) : pos])
+
+	var completions []Completion
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		score, matched, ok := match(v.Mode, typed, name)
+		if !ok {
+			continue
+		}
+		// Offsets are shifted past the $ or ${
+		for i := range matched {
+			matched[i] += len(prefix)
+		}
+		completions = append(completions, Completion{
+			Text:        prefix + name + suffix,
+			Kind:        CompletionCustom,
+			Description: value,
+			Replace:     pos - start,
+			Score:       score,
+			Matched:     matched,
+		})
+	}
+	sort.SliceStable(completions, func(i, j int) bool { return completions[i].Text < completions[j].Text })
+	sortByScore(completions)
+	return completions
+}
+
+// SetMatchMode implements matchModeSetter
+func (v *VariableCompleter) SetMatchMode(mode MatchMode) {
+	v.Mode = mode
+}
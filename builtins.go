@@ -0,0 +1,315 @@
+package goterm
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Builtin is a command run by go-term itself rather than as a process, so
+// that it can change the terminal's state, such as its directory
+type Builtin struct {
+	Name    string
+	Summary string // Shown by help and alongside completions
+	// Usage is shown by `help name`: how the builtin is run, then what it
+	// does in more detail
+	Usage string
+	// Run runs the builtin with the arguments after its name, writing any
+	// output to out
+	Run func(t *Terminal, args []string, out io.Writer) error
+}
+
+// defaultBuiltins returns the builtins every terminal starts with
+func defaultBuiltins() []Builtin {
+	// exit [n] exits with status n, or that of the last command
+	exit := func(t *Terminal, args []string, out io.Writer) error {
+		code := t.lastStatus
+		switch {
+		case len(args) > 1:
+			return fmt.Errorf("exit: too many arguments")
+		case len(args) == 1:
+			n, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("exit: %s: numeric argument required", args[0])
+			}
+			code = n
+		}
+		t.exitRequested, t.exitStatus = true, code
+		return nil
+	}
+	builtins := []Builtin{
+		{
+			Name:    "alias",
+			Summary: "define or list aliases",
+			Run:     (*Terminal).AliasCommand,
+		},
+		{
+			Name:    "bg",
+			Summary: "continue a stopped job in the background",
+			Run:     (*Terminal).BackgroundCommand,
+		},
+		{
+			Name:    "cd",
+			Summary: "change directory, or go back with cd -",
+			Run:     (*Terminal).ChangeDirectory,
+		},
+		{
+			Name:    "clear",
+			Summary: "clear the screen",
+			Run: func(t *Terminal, args []string, out io.Writer) error {
+				return t.Clear()
+			},
+		},
+		{
+			Name:    "debug-keys",
+			Summary: "show the keys pressed as they are read",
+			Run:     (*Terminal).DebugKeysCommand,
+		},
+		{
+			Name:    "dirs",
+			Summary: "show the directory stack",
+			Run:     (*Terminal).DirsCommand,
+		},
+		{
+			Name:    "exit",
+			Summary: "exit the terminal",
+			Run:     exit,
+		},
+		{
+			Name:    "export",
+			Summary: "set an environment variable, or list them all",
+			Run:     (*Terminal).ExportCommand,
+		},
+		{
+			Name:    "fg",
+			Summary: "bring a job to the foreground",
+			Run:     (*Terminal).ForegroundCommand,
+		},
+		{
+			Name:    "help",
+			Summary: "show this help message",
+			Run:     (*Terminal).HelpCommand,
+		},
+		{
+			Name:    "history",
+			Summary: "show or edit the command history",
+			Run:     (*Terminal).HistoryCommand,
+		},
+		{
+			Name:    "j",
+			Summary: "jump to a frequently used directory",
+			Run:     (*Terminal).JumpCommand,
+		},
+		{
+			Name:    "jobs",
+			Summary: "list background and stopped jobs",
+			Run:     (*Terminal).JobsCommand,
+		},
+		{
+			Name:    "popd",
+			Summary: "return to the directory saved by pushd",
+			Run:     (*Terminal).PopdCommand,
+		},
+		{
+			Name:    "pushd",
+			Summary: "change directory, saving the current one",
+			Run:     (*Terminal).PushdCommand,
+		},
+		{
+			Name:    "quit",
+			Summary: "exit the terminal",
+			Run:     exit,
+		},
+		{
+			Name:    "reload-config",
+			Summary: "read the config file again",
+			Run:     (*Terminal).ReloadConfigCommand,
+		},
+		{
+			Name:    "set",
+			Summary: "set an environment variable, or list them all",
+			Run:     (*Terminal).SetCommand,
+		},
+		{
+			Name:    "source",
+			Summary: "run the commands in a file",
+			Run: func(t *Terminal, args []string, out io.Writer) error {
+				return t.SourceCommand(args)
+			},
+		},
+		{
+			Name:    "theme",
+			Summary: "switch color theme, or show them all",
+			Run:     (*Terminal).ThemeCommand,
+		},
+		{
+			Name:    "type",
+			Summary: "show whether a command is a builtin, an alias or a file",
+			Run:     (*Terminal).TypeCommand,
+		},
+		{
+			Name:    "unalias",
+			Summary: "remove an alias",
+			Run: func(t *Terminal, args []string, out io.Writer) error {
+				return t.UnaliasCommand(args)
+			},
+		},
+		{
+			Name:    "unset",
+			Summary: "remove an environment variable",
+			Run: func(t *Terminal, args []string, out io.Writer) error {
+				return t.UnsetCommand(args)
+			},
+		},
+		{
+			Name:    "which",
+			Summary: "show the path of a command",
+			Run:     (*Terminal).WhichCommand,
+		},
+	}
+	for i, b := range builtins {
+		builtins[i].Usage = builtinUsage[b.Name]
+	}
+	return builtins
+}
+
+// RegisterBuiltin adds a builtin, replacing any with the same name. It is
+// offered by completion and listed by help like the standard ones.
+func (t *Terminal) RegisterBuiltin(b Builtin) {
+	t.builtinMu.Lock()
+	defer t.builtinMu.Unlock()
+	if t.builtins == nil {
+		t.builtins = make(map[string]Builtin)
+	}
+	t.builtins[b.Name] = b
+}
+
+// Builtin returns the builtin with the given name
+func (t *Terminal) Builtin(name string) (Builtin, bool) {
+	t.builtinMu.RLock()
+	defer t.builtinMu.RUnlock()
+	b, ok := t.builtins[name]
+	return b, ok
+}
+
+// Builtins returns the builtins, sorted by name
+func (t *Terminal) Builtins() []Builtin {
+	t.builtinMu.RLock()
+	defer t.builtinMu.RUnlock()
+	builtins := make([]Builtin, 0, len(t.builtins))
+	for _, b := range t.builtins {
+		builtins = append(builtins, b)
+	}
+	sort.Slice(builtins, func(i, j int) bool { return builtins[i].Name < builtins[j].Name })
+	return builtins
+}
+
+// builtinSummaries returns the summary of each builtin, by name
+func (t *Terminal) builtinSummaries() map[string]string {
+	t.builtinMu.RLock()
+	defer t.builtinMu.RUnlock()
+	summaries := make(map[string]string, len(t.builtins))
+	for name, b := range t.builtins {
+		summaries[name] = b.Summary
+	}
+	return summaries
+}
+
+// isBuiltin reports whether name is a command handled by go-term itself
+func (t *Terminal) isBuiltin(name string) bool {
+	_, ok := t.Builtin(name)
+	return ok
+}
+
+// runBuiltin runs the builtin command args[0] in go-term itself, writing
+// any output to out
+func (t *Terminal) runBuiltin(args []string, out io.Writer) error {
+	b, ok := t.Builtin(args[0])
+	if !ok {
+		return fmt.Errorf("%s: not a builtin", args[0])
+	}
+	return b.Run(t, args[1:], out)
+}
+
+// lookPath finds the executable a command name runs, searching the PATH of
+// the environment commands are run with. Names with a slash are used as
+// they are.
+func (t *Terminal) lookPath(name string) (string, bool) {
+	if strings.Contains(name, "/") {
+		return name, isExecutable(name)
+	}
+	for _, dir := range filepath.SplitList(t.Getenv("PATH")) {
+		if dir == "" {
+			dir = "."
+		}
+		path := filepath.Join(dir, name)
+		if isExecutable(path) {
+			if abs, err := filepath.Abs(path); err == nil {
+				path = abs
+			}
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// TypeCommand runs `type name...`, writing what each name runs as a command
+// to out: a builtin, an alias or the path of an executable
+func (t *Terminal) TypeCommand(args []string, out io.Writer) error {
+	return t.describeCommands("type", args, out, func(name, kind, detail string) string {
+		switch kind {
+		case "builtin":
+			return name + " is a shell builtin"
+		case "alias":
+			return name + " is aliased to `" + detail + "'"
+		}
+		return name + " is " + detail
+	})
+}
+
+// WhichCommand runs `which name...`, writing the path of each command to
+// out, or what it is if it isn't an executable
+func (t *Terminal) WhichCommand(args []string, out io.Writer) error {
+	return t.describeCommands("which", args, out, func(name, kind, detail string) string {
+		switch kind {
+		case "builtin":
+			return name + ": shell builtin"
+		case "alias":
+			return name + ": aliased to " + detail
+		}
+		return detail
+	})
+}
+
+// describeCommands writes a line for each command in args, made by format
+// from its kind, "builtin", "alias" or "file", and the alias value or path.
+// Aliases are looked for first, then builtins, then PATH, in the order a
+// command is run. Names that are none of these make it fail once the others
+// are written.
+func (t *Terminal) describeCommands(builtin string, args []string, out io.Writer, format func(name, kind, detail string) string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: %s name...", builtin)
+	}
+	var missing []string
+	for _, name := range args {
+		var line string
+		if value, ok := t.Alias(name); ok {
+			line = format(name, "alias", value)
+		} else if t.isBuiltin(name) {
+			line = format(name, "builtin", "")
+		} else if path, ok := t.lookPath(name); ok {
+			line = format(name, "file", path)
+		} else {
+			missing = append(missing, name)
+			continue
+		}
+		fmt.Fprintln(out, line)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s: %s: not found", builtin, strings.Join(missing, ", "))
+	}
+	return nil
+}
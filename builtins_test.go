@@ -0,0 +1,178 @@
+package goterm
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinRun(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+		check   func(t *testing.T, f *FakeTerm, out string)
+	}{
+		{
+			name: "cd",
+			args: []string{dir},
+			check: func(t *testing.T, f *FakeTerm, out string) {
+				if got, _ := os.Getwd(); got != dir {
+					t.Errorf("working directory = %q, want %q", got, dir)
+				}
+				if got := f.Getenv("PWD"); got != dir {
+					t.Errorf("PWD = %q, want %q", got, dir)
+				}
+				if got := f.Getenv("OLDPWD"); got != wd {
+					t.Errorf("OLDPWD = %q, want %q", got, wd)
+				}
+			},
+		},
+		{
+			name:    "cd",
+			args:    []string{filepath.Join(dir, "missing")},
+			wantErr: "no such file or directory",
+		},
+		{
+			name:    "cd",
+			args:    []string{"a", "b"},
+			wantErr: "cd: too many arguments",
+		},
+		{
+			name: "help",
+			check: func(t *testing.T, f *FakeTerm, out string) {
+				if !strings.HasPrefix(out, "Available commands:\n") {
+					t.Errorf("output = %q, want the list of commands", out)
+				}
+			},
+		},
+		{
+			name: "help",
+			args: []string{"cd"},
+			check: func(t *testing.T, f *FakeTerm, out string) {
+				if !strings.HasPrefix(out, "cd [dir | -]\n") {
+					t.Errorf("output = %q, want the usage of cd", out)
+				}
+			},
+		},
+		{
+			name:    "help",
+			args:    []string{"cd", "exit"},
+			wantErr: "usage: help [command]",
+		},
+		{
+			name: "exit",
+			check: func(t *testing.T, f *FakeTerm, out string) {
+				if !f.exitRequested || f.exitStatus != 0 {
+					t.Errorf("exit requested = %v with status %d, want true with 0", f.exitRequested, f.exitStatus)
+				}
+			},
+		},
+		{
+			name: "exit",
+			args: []string{"3"},
+			check: func(t *testing.T, f *FakeTerm, out string) {
+				if !f.exitRequested || f.exitStatus != 3 {
+					t.Errorf("exit requested = %v with status %d, want true with 3", f.exitRequested, f.exitStatus)
+				}
+			},
+		},
+		{
+			name:    "exit",
+			args:    []string{"x"},
+			wantErr: "exit: x: numeric argument required",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(append([]string{tt.name}, tt.args...), " "), func(t *testing.T) {
+			f := newFake(t)
+			b, ok := f.Builtin(tt.name)
+			if !ok {
+				t.Fatalf("%s isn't registered", tt.name)
+			}
+			var buf bytes.Buffer
+			err := b.Run(f.Terminal, tt.args, &buf)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.check != nil {
+				tt.check(t, f, buf.String())
+			}
+		})
+	}
+}
+
+func TestBuiltinNamesListed(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	f := newFake(t)
+	f.RegisterBuiltin(Builtin{
+		Name:    "greet",
+		Summary: "say hello",
+		Run: func(t *Terminal, args []string, out io.Writer) error {
+			_, err := io.WriteString(out, "hello\n")
+			return err
+		},
+	})
+
+	var want []string
+	for _, b := range f.Builtins() {
+		want = append(want, b.Name)
+	}
+	for _, name := range []string{"cd", "exit", "greet", "help"} {
+		if i := sort.SearchStrings(want, name); i == len(want) || want[i] != name {
+			t.Fatalf("registered builtins %v don't include %s", want, name)
+		}
+	}
+
+	// Every builtin starts with a letter, so completing each letter offers
+	// them all
+	seen := make(map[string]bool)
+	for c := 'a'; c <= 'z'; c++ {
+		for _, comp := range f.commands.Complete(string(c), 1) {
+			seen[comp.Display] = true
+		}
+	}
+	var completed []string
+	for name := range seen {
+		completed = append(completed, name)
+	}
+	sort.Strings(completed)
+	if strings.Join(completed, " ") != strings.Join(want, " ") {
+		t.Errorf("completed commands = %v, want %v", completed, want)
+	}
+
+	var buf bytes.Buffer
+	if err := f.HelpCommand(nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+	list, _, _ := strings.Cut(strings.TrimPrefix(buf.String(), "Available commands:\n"), "\n\n")
+	var listed []string
+	for _, line := range strings.Split(list, "\n") {
+		if strings.HasPrefix(line, "  ") && !strings.HasPrefix(line, "   ") {
+			listed = append(listed, strings.Fields(line)[0])
+		}
+	}
+	if strings.Join(listed, " ") != strings.Join(want, " ") {
+		t.Errorf("help lists %v, want %v", listed, want)
+	}
+}
@@ -0,0 +1,57 @@
+package goterm
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// clipboardReaders are the programs tried, in order, to read the system
+// clipboard, as few terminals let programs read it with OSC 52
+var clipboardReaders = [][]string{
+	{"wl-paste", "--no-newline"},
+	{"xclip", "-selection", "clipboard", "-o"},
+	{"xsel", "--clipboard", "--output"},
+	{"pbpaste"},
+	{"powershell", "-NoProfile", "-Command", "Get-Clipboard"},
+}
+
+// CopyToClipboard puts text on the system clipboard. It is sent to the
+// terminal in an OSC 52 escape sequence, which works over SSH as well, in
+// terminals that support it. Terminals that take no OSC sequences, such as
+// the Linux console, can't set the clipboard, which is an error.
+func (t *Terminal) CopyToClipboard(text string) error {
+	if !t.oscSupported() {
+		return fmt.Errorf("could not set the clipboard: the terminal takes no OSC 52 sequences")
+	}
+	seq := "\033]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+	if _, err := t.writer.WriteString(seq); err != nil {
+		return err
+	}
+	return t.flush()
+}
+
+// ReadClipboard returns the text on the system clipboard, read with the
+// first of wl-paste, xclip, xsel, pbpaste and PowerShell that is installed
+// and works
+func ReadClipboard() (string, error) {
+	var errs []string
+	for _, args := range clipboardReaders {
+		path, err := exec.LookPath(args[0])
+		if err != nil {
+			continue
+		}
+		out, err := exec.Command(path, args[1:]...).Output()
+		if err != nil {
+			// wl-paste fails outside Wayland, for example, where xclip works
+			errs = append(errs, fmt.Sprintf("%s: %v", args[0], err))
+			continue
+		}
+		return string(out), nil
+	}
+	if len(errs) > 0 {
+		return "", fmt.Errorf("could not read the clipboard: %s", strings.Join(errs, ", "))
+	}
+	return "", fmt.Errorf("could not read the clipboard: none of wl-paste, xclip, xsel or pbpaste is installed")
+}
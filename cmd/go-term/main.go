@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	goterm "github.com/pk/go-term"
+)
+
+func main() {
+	os.Exit(run())
+}
+
+// run runs the REPL, returning the status to exit with
+func run() int {
+	noRC := flag.Bool("norc", false, "don't run the startup file, ~/.config/go-term/rc")
+	printConfig := flag.Bool("print-default-config", false, "print a config file with every setting explained, and exit")
+	noColor := flag.Bool("no-color", false, "draw nothing in color")
+	noRestoreCwd := flag.Bool("no-restore-cwd", false, "stay in the directory go-term is started from, even with restore_cwd set")
+	debug := flag.Bool("debug", false, "log key presses and what is drawn to ~/.cache/go-term/trace.log, as GOTERM_DEBUG=1 does")
+	flag.Parse()
+
+	if *printConfig {
+		fmt.Print(goterm.DefaultConfigFile)
+		return 0
+	}
+
+	// Set up signal handling
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, signals...)
+
+	var opts []goterm.Option
+	if *noColor {
+		opts = append(opts, goterm.WithColorLevel(goterm.ColorNone))
+	}
+	if v := os.Getenv("GOTERM_DEBUG"); *debug || (v != "" && v != "0") {
+		path, err := goterm.TracePath()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		opts = append(opts, goterm.WithTrace(path))
+	}
+	term, err := goterm.NewTerminal(opts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating terminal: %v\n", err)
+		return 1
+	}
+
+	defer term.Close()
+
+	// Ctrl+C interrupts the running command or the line being typed, and
+	// handleSignal deals with the signals of the platform. Anything else
+	// ends the REPL once any command running is done, by cancelling ctx.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for sig := range sigChan {
+			switch {
+			case sig == os.Interrupt:
+				term.Interrupt()
+			case handleSignal(term, sig):
+			default:
+				cancel()
+			}
+		}
+	}()
+
+	if !term.LineMode() {
+		term.Clear()
+		term.WriteLine("Go Terminal REPL (type 'help' for commands, 'exit' to quit, or press Ctrl+D)")
+		term.WriteLine("")
+	}
+
+	// Go back to where the last session ended, before the startup file,
+	// which can still change directory itself. Scripts piped in stay where
+	// they are started.
+	if !*noRestoreCwd && !term.LineMode() {
+		term.RestoreWorkingDir()
+	}
+
+	// Run the startup file, which can set aliases, variables and options
+	var exitErr *goterm.ExitError
+	if path, err := goterm.RCFilePath(); err == nil && !*noRC {
+		if err := term.Source(path); errors.As(err, &exitErr) {
+			return exitErr.Code
+		} else if err != nil && !os.IsNotExist(err) {
+			term.WriteLine(fmt.Sprintf("Error: could not run %s: %v", path, err))
+		}
+	}
+
+	for {
+		prompt, err := term.GetPrompt()
+		if err != nil {
+			term.WriteLine(fmt.Sprintf("Error getting prompt: %v", err))
+			prompt = "> "
+		}
+		cmd, err := term.ReadLineContext(ctx, prompt)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, context.Canceled) {
+				fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
+				return 1
+			}
+			return term.LastStatus()
+		}
+
+		// Expand !! and friends, showing the command that will really run
+		expanded, changed, err := goterm.ExpandHistory(cmd, term.History())
+		if err != nil {
+			term.WriteLine(fmt.Sprintf("Error: %v", err))
+			expanded = ""
+		} else if changed {
+			term.WriteLine(expanded)
+		}
+		cmd = expanded
+
+		if cmd != "" {
+			start := time.Now()
+			runErr := term.Run(cmd)
+			if runErr != nil && !errors.As(runErr, &exitErr) {
+				term.WriteLine(fmt.Sprintf("Error: %v", runErr))
+			}
+
+			// Add command to history with how long it took
+			entry := goterm.HistoryEntry{Command: cmd, Time: start, Duration: time.Since(start)}
+			if err := term.AddHistoryEntry(entry); err != nil {
+				term.WriteLine(fmt.Sprintf("Error saving history: %v", err))
+			}
+			if errors.As(runErr, &exitErr) {
+				return exitErr.Code
+			}
+		}
+
+		// Report background jobs that have finished or stopped
+		for _, notice := range term.JobNotices() {
+			term.WriteLine(notice)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+
+	goterm "github.com/pk/go-term"
+)
+
+// signals are the signals the REPL handles
+var signals = []os.Signal{syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTSTP, syscall.SIGTERM, syscall.SIGWINCH}
+
+// handleSignal passes quit and stop signals on to the running command, if
+// any, and redraws the line when the window is resized, reporting whether
+// sig was one of those
+func handleSignal(term *goterm.Terminal, sig os.Signal) bool {
+	switch sig {
+	case syscall.SIGQUIT, syscall.SIGTSTP:
+		term.ForwardSignal(sig.(syscall.Signal))
+	case syscall.SIGWINCH:
+		term.Resize()
+	default:
+		return false
+	}
+	return true
+}
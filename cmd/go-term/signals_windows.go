@@ -0,0 +1,17 @@
+package main
+
+import (
+	"os"
+	"syscall"
+
+	goterm "github.com/pk/go-term"
+)
+
+// signals are the signals the REPL handles. Resizes are watched for by the
+// terminal itself, as Windows has no signal for them.
+var signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// handleSignal reports that sig is not one the platform handles itself
+func handleSignal(term *goterm.Terminal, sig os.Signal) bool {
+	return false
+}
@@ -0,0 +1,434 @@
+package goterm
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Colors are the ANSI styles go-term draws with, as escape sequences such
+// as "\033[32m". ParseColor makes them from names such as "bold green". A
+// theme is a named set of them.
+type Colors struct {
+	Prompt         string // The default prompt
+	Command        string // Commands that can be run
+	UnknownCommand string // Commands that can't
+	Quoted         string // Quoted strings
+	Path           string // Arguments naming existing files
+	Suggestion     string // Inline suggestions
+	PromptError    string // The prompt after a command fails, if status_color is set
+	Menu           string // Items of the completion menu
+	MenuSelected   string // The selected item of the completion menu
+	MenuHistory    string // Items of the completion menu from the history
+	Stderr         string // What commands write to standard error, if color_stderr is set
+}
+
+// DefaultColors returns the colors used unless configured otherwise
+func DefaultColors() Colors {
+	return Colors{
+		Command:        StyleCommand,
+		UnknownCommand: StyleUnknownCommand,
+		Quoted:         StyleQuoted,
+		Path:           StylePath,
+		Suggestion:     greenColor,
+		PromptError:    redColor,
+		Menu:           YellowBg + BlackFg,
+		MenuSelected:   GreenBg + BlackFg,
+		MenuHistory:    BlueBg + BlackFg,
+		Stderr:         redColor,
+	}
+}
+
+// themes are the built-in color themes, by name
+var themes = map[string]func() Colors{
+	"default":    DefaultColors,
+	"light":      lightColors,
+	"monochrome": monochromeColors,
+}
+
+// lightColors returns the colors of the light theme, for terminals with a
+// light background
+func lightColors() Colors {
+	return Colors{
+		Prompt:         mustParseColor("blue"),
+		Command:        mustParseColor("green"),
+		UnknownCommand: mustParseColor("red"),
+		Quoted:         mustParseColor("magenta"),
+		Path:           StylePath,
+		Suggestion:     mustParseColor("bright_black"),
+		PromptError:    mustParseColor("red"),
+		Menu:           mustParseColor("black on_253"),
+		MenuSelected:   mustParseColor("black on_152"),
+		MenuHistory:    mustParseColor("black on_189"),
+		Stderr:         mustParseColor("red"),
+	}
+}
+
+// monochromeColors returns the colors of the monochrome theme, which uses
+// bold, underlined and reverse text in place of colors
+func monochromeColors() Colors {
+	return Colors{
+		Command:      mustParseColor("bold"),
+		Path:         StylePath,
+		Suggestion:   mustParseColor("dim"),
+		PromptError:  mustParseColor("bold"),
+		MenuSelected: mustParseColor("reverse"),
+		Stderr:       mustParseColor("bold"),
+	}
+}
+
+// mustParseColor is ParseColor for colors known to be valid
+func mustParseColor(spec string) string {
+	style, err := ParseColor(spec)
+	if err != nil {
+		panic(err)
+	}
+	return style
+}
+
+// ThemeNames returns the names of the built-in color themes, in order
+func ThemeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ThemeColors returns the colors of the built-in theme called name: default,
+// light or monochrome
+func ThemeColors(name string) (Colors, error) {
+	colors, ok := themes[name]
+	if !ok {
+		return Colors{}, fmt.Errorf("unknown theme %q, not one of %s", name, strings.Join(ThemeNames(), ", "))
+	}
+	return colors(), nil
+}
+
+// SetTheme draws with the colors of the built-in theme called name
+func (t *Terminal) SetTheme(name string) error {
+	colors, err := ThemeColors(name)
+	if err != nil {
+		return err
+	}
+	t.SetColors(colors)
+	t.theme = name
+	return nil
+}
+
+// ThemeCommand runs `theme [name]`, switching to a built-in theme, or with
+// no name listing them to out with a preview of each. The current theme is
+// marked with *, unless the config file has changed some of its colors.
+func (t *Terminal) ThemeCommand(args []string, out io.Writer) error {
+	switch len(args) {
+	case 0:
+	case 1:
+		if err := t.SetTheme(args[0]); err != nil {
+			return fmt.Errorf("theme: %v", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("usage: theme [name]")
+	}
+
+	for _, name := range ThemeNames() {
+		c, _ := ThemeColors(name)
+		mark := " "
+		if name == t.theme && c == t.colors {
+			mark = "*"
+		}
+		preview := c.Prompt + "~> " + resetColor +
+			c.Command + "ls" + resetColor + " " + c.Quoted + "'a b'" + resetColor + " " +
+			c.Path + "src" + resetColor + c.Suggestion + "/main.go" + resetColor + "  " +
+			c.Menu + " item " + resetColor + c.MenuSelected + " selected " + resetColor +
+			c.MenuHistory + " history " + resetColor + "  " +
+			c.UnknownCommand + "nosuch" + resetColor + " " + c.PromptError + "~ [1]>" + resetColor
+		fmt.Fprintf(out, "%s %-10s  %s\n", mark, name, t.styled(preview))
+	}
+	return nil
+}
+
+// SetColors changes the colors the line, suggestions and prompt are drawn
+// in. The line's colors only apply while the default highlighter is used.
+func (t *Terminal) SetColors(c Colors) {
+	t.colors = c
+	if h, ok := t.highlighter.(DefaultHighlighter); ok {
+		h.Colors = &c
+		t.highlighter = h
+	}
+}
+
+// Codes of the attributes and colors ParseColor understands
+var (
+	colorAttributes = map[string]int{
+		"bold": 1, "dim": 2, "italic": 3, "underline": 4, "blink": 5, "reverse": 7,
+	}
+	colorNames = map[string]int{
+		"black": 0, "red": 1, "green": 2, "yellow": 3,
+		"blue": 4, "magenta": 5, "cyan": 6, "white": 7,
+	}
+)
+
+// ParseColor turns a description of a style into its escape sequence. The
+// description is a list of words: the attributes bold, dim, italic,
+// underline, blink and reverse, a color name such as green or bright_green,
+// a color from the 256 color palette by number, or #rrggbb. A color
+// prefixed with on_, such as on_blue, sets the background. "default" is the
+// terminal's normal style.
+func ParseColor(spec string) (string, error) {
+	var codes []string
+	for _, word := range strings.Fields(strings.ToLower(spec)) {
+		if word == "default" {
+			codes = append(codes, "0")
+			continue
+		}
+		if n, ok := colorAttributes[word]; ok {
+			codes = append(codes, strconv.Itoa(n))
+			continue
+		}
+
+		base := 30
+		if name, ok := strings.CutPrefix(word, "on_"); ok {
+			word, base = name, 40
+		}
+		code, err := colorCode(word, base)
+		if err != nil {
+			return "", fmt.Errorf("invalid color %q: %v", spec, err)
+		}
+		codes = append(codes, code)
+	}
+	if len(codes) == 0 {
+		return "", fmt.Errorf("empty color")
+	}
+	return "\033[" + strings.Join(codes, ";") + "m", nil
+}
+
+// colorCode returns the SGR parameters for a foreground color, with base
+// 30, or a background color, with base 40
+func colorCode(word string, base int) (string, error) {
+	if n, ok := colorNames[word]; ok {
+		return strconv.Itoa(base + n), nil
+	}
+	if name, ok := strings.CutPrefix(word, "bright_"); ok {
+		if n, ok := colorNames[name]; ok {
+			return strconv.Itoa(base + 60 + n), nil
+		}
+	}
+	if hex, ok := strings.CutPrefix(word, "#"); ok {
+		rgb, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil || len(hex) != 6 {
+			return "", fmt.Errorf("%q is not #rrggbb", word)
+		}
+		return fmt.Sprintf("%d;2;%d;%d;%d", base+8, rgb>>16, rgb>>8&0xff, rgb&0xff), nil
+	}
+	if n, err := strconv.Atoi(word); err == nil {
+		if n < 0 || n > 255 {
+			return "", fmt.Errorf("color number %d is not between 0 and 255", n)
+		}
+		return fmt.Sprintf("%d;5;%d", base+8, n), nil
+	}
+	return "", fmt.Errorf("unknown color or attribute %q", word)
+}
+
+// ColorLevel is how many colors a terminal can show
+type ColorLevel int
+
+const (
+	ColorNone ColorLevel = iota // No colors or other styles at all
+	Color16                     // The 16 basic colors
+	Color256                    // The 256 color palette
+	ColorTrue                   // Any #rrggbb color
+)
+
+// DetectColorLevel works out the colors the terminal can show from the
+// environment, read with getenv. NO_COLOR or TERM=dumb turn them off,
+// COLORTERM=truecolor or 24bit allows any color, a TERM ending in 256color
+// the 256 color palette, and anything else the basic 16.
+func DetectColorLevel(getenv func(string) string) ColorLevel {
+	term := getenv("TERM")
+	colorTerm := getenv("COLORTERM")
+	switch {
+	case getenv("NO_COLOR") != "" || term == "dumb":
+		return ColorNone
+	case colorTerm == "truecolor" || colorTerm == "24bit" || getenv("WT_SESSION") != "":
+		// Windows Terminal sets WT_SESSION rather than COLORTERM
+		return ColorTrue
+	case strings.HasSuffix(term, "256color"):
+		return Color256
+	}
+	return Color16
+}
+
+// ColorLevel returns the colors the terminal draws with, ColorNone in
+// monochrome mode
+func (t *Terminal) ColorLevel() ColorLevel {
+	if t.noColor {
+		return ColorNone
+	}
+	return t.colorLevel
+}
+
+// SetColorLevel sets the colors the terminal can show, in place of those
+// detected from the environment. Colors it can't show are drawn as the
+// nearest it can.
+func (t *Terminal) SetColorLevel(level ColorLevel) {
+	t.colorLevel = level
+}
+
+// SetNoColor turns monochrome mode on or off. In monochrome mode nothing is
+// drawn in color, or in any other style, whatever the color level.
+func (t *Terminal) SetNoColor(noColor bool) {
+	t.noColor = noColor
+}
+
+// styled returns s with its styles changed to suit the terminal's color
+// level
+func (t *Terminal) styled(s string) string {
+	level := t.ColorLevel()
+	if level == ColorTrue {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if s[i] != '\033' {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+		n := escapeLen(s[i:])
+		b.WriteString(adaptStyle(s[i:i+n], level))
+		i += n
+	}
+	return b.String()
+}
+
+// adaptStyle returns the escape sequence seq changed to suit a terminal
+// showing level colors. Colors it can't show become the nearest that it
+// can, and at ColorNone a style is dropped altogether. Sequences other than
+// styles are returned as they are.
+func adaptStyle(seq string, level ColorLevel) string {
+	if !strings.HasPrefix(seq, "\033[") || !strings.HasSuffix(seq, "m") || level == ColorTrue {
+		return seq
+	}
+	if level == ColorNone {
+		return ""
+	}
+
+	params := strings.Split(seq[2:len(seq)-1], ";")
+	codes := make([]string, 0, len(params))
+	for i := 0; i < len(params); i++ {
+		p := params[i]
+		if (p != "38" && p != "48") || i+1 >= len(params) {
+			codes = append(codes, p)
+			continue
+		}
+		base := 30
+		if p == "48" {
+			base = 40
+		}
+		var rgb [3]int
+		switch {
+		case params[i+1] == "5" && i+2 < len(params):
+			n, _ := strconv.Atoi(params[i+2])
+			i += 2
+			if level == Color256 {
+				codes = append(codes, p, "5", strconv.Itoa(n))
+				continue
+			}
+			rgb = paletteRGB(n)
+		case params[i+1] == "2" && i+4 < len(params):
+			for j := range rgb {
+				rgb[j], _ = strconv.Atoi(params[i+2+j])
+			}
+			i += 4
+			if level == Color256 {
+				codes = append(codes, p, "5", strconv.Itoa(nearestPaletteColor(rgb)))
+				continue
+			}
+		default:
+			codes = append(codes, p)
+			continue
+		}
+		codes = append(codes, strconv.Itoa(basicColorCode(nearestBasicColor(rgb), base)))
+	}
+	return "\033[" + strings.Join(codes, ";") + "m"
+}
+
+// basicColors are the 16 basic colors as xterm shows them by default
+var basicColors = [16][3]int{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// cubeLevels are the values each of red, green and blue takes in the 6x6x6
+// color cube of the 256 color palette
+var cubeLevels = [6]int{0, 95, 135, 175, 215, 255}
+
+// paletteRGB returns the red, green and blue of color n of the 256 color
+// palette
+func paletteRGB(n int) [3]int {
+	switch {
+	case n < 0 || n > 255:
+		return [3]int{}
+	case n < 16:
+		return basicColors[n]
+	case n < 232:
+		n -= 16
+		return [3]int{cubeLevels[n/36], cubeLevels[n/6%6], cubeLevels[n%6]}
+	}
+	gray := 8 + 10*(n-232)
+	return [3]int{gray, gray, gray}
+}
+
+// nearestPaletteColor returns the color of the 256 color palette, from the
+// color cube or the grays after it, nearest to rgb
+func nearestPaletteColor(rgb [3]int) int {
+	cube := 16
+	for i, scale := range []int{36, 6, 1} {
+		level := 0
+		for level < len(cubeLevels)-1 && rgb[i] >= (cubeLevels[level]+cubeLevels[level+1])/2 {
+			level++
+		}
+		cube += scale * level
+	}
+	gray := 232 + min(max((rgb[0]+rgb[1]+rgb[2])/3-3, 0)/10, 23)
+	if colorDistance(paletteRGB(gray), rgb) < colorDistance(paletteRGB(cube), rgb) {
+		return gray
+	}
+	return cube
+}
+
+// nearestBasicColor returns the one of the 16 basic colors nearest to rgb
+func nearestBasicColor(rgb [3]int) int {
+	nearest := 0
+	for i, c := range basicColors {
+		if colorDistance(c, rgb) < colorDistance(basicColors[nearest], rgb) {
+			nearest = i
+		}
+	}
+	return nearest
+}
+
+// basicColorCode returns the SGR parameter for basic color n, with base 30
+// for the foreground or 40 for the background
+func basicColorCode(n, base int) int {
+	if n >= 8 {
+		return base + 60 + n - 8
+	}
+	return base + n
+}
+
+// colorDistance returns how far apart two colors look, as the square of
+// the distance between them
+func colorDistance(a, b [3]int) int {
+	d := 0
+	for i := range a {
+		d += (a[i] - b[i]) * (a[i] - b[i])
+	}
+	return d
+}
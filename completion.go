@@ -0,0 +1,616 @@
+package goterm
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CompletionKind identifies where a completion came from
+type CompletionKind int
+
+const (
+	CompletionHistory CompletionKind = iota // A previous command line
+	CompletionCommand                       // A builtin or executable on PATH
+	CompletionFile                          // A file or directory
+	CompletionCustom                        // Anything provided by a registered completer
+)
+
+// Completion is a single candidate offered for the text before the cursor
+type Completion struct {
+	Text        string         // Replacement for the last Replace runes before the cursor
+	Display     string         // Label shown in the menu, Text if empty
+	Kind        CompletionKind // Source of the completion, used for coloring
+	Description string         // Optional extra information shown next to the label, never inserted
+	Replace     int            // Number of runes before the cursor that Text replaces
+	Score       int            // How well the completion matches, higher is better
+	Matched     []int          // Rune offsets in the label of the matched characters
+}
+
+// Label returns the text shown for the completion in the menu
+func (c Completion) Label() string {
+	if c.Display != "" {
+		return c.Display
+	}
+	return c.Text
+}
+
+// Apply returns line with the completion applied before rune offset pos
+func (c Completion) Apply(line string, pos int) string {
+	runes := []rune(line)
+	start := max(pos-c.Replace, 0)
+	return string(runes[:start]) + c.Text + string(runes[pos:])
+}
+
+// Completer provides completions for the input line with the cursor at pos,
+// given as a rune offset
+type Completer interface {
+	Complete(line string, pos int) []Completion
+}
+
+// ContextCompleter is implemented by completers that can stop early when
+// the completions are no longer wanted because the input has changed
+type ContextCompleter interface {
+	Completer
+	CompleteContext(ctx context.Context, line string, pos int) []Completion
+}
+
+// RegisterCompleter adds a completer after the existing ones. Completions
+// are offered in the order their completers were registered.
+func (t *Terminal) RegisterCompleter(c Completer) {
+	t.completers = append(t.completers, c)
+}
+
+// GetCompletions returns possible completions for the input line with the
+// cursor at pos, collected from every registered completer
+func (t *Terminal) GetCompletions(line string, pos int) []Completion {
+	return t.GetCompletionsContext(context.Background(), line, pos)
+}
+
+// GetCompletionsContext is like GetCompletions but gives up and returns nil
+// once ctx is canceled
+func (t *Terminal) GetCompletionsContext(ctx context.Context, line string, pos int) []Completion {
+	var completions []Completion
+	for _, c := range t.completers {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if cc, ok := c.(ContextCompleter); ok {
+			completions = append(completions, cc.CompleteContext(ctx, line, pos)...)
+		} else {
+			completions = append(completions, c.Complete(line, pos)...)
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	if t.matchMode == MatchFuzzy {
+		sortByScore(completions)
+	}
+	return completions
+}
+
+// RequestCompletions computes completions for the input in the background
+// so that typing never waits on slow completers. When they are ready, the
+// inline suggestion is shown if atEnd is set and the dropdown menu if
+// showMenu is set, unless the input has changed in the meantime. The caller
+// must hold t.mu, as the key handling loop does.
+func (t *Terminal) RequestCompletions(line string, pos int, atEnd, showMenu bool) {
+	t.CancelCompletions()
+	gen := t.completionGen
+	ctx, cancel := context.WithCancel(context.Background())
+	t.cancelCompletion = cancel
+
+	go func() {
+		defer cancel()
+		completions := t.GetCompletionsContext(ctx, line, pos)
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		// Discard results for input that has since changed, or that a
+		// history search has taken the place of
+		if ctx.Err() != nil || gen != t.completionGen || t.searchMode {
+			return
+		}
+		if showMenu {
+			t.currentSuggestions = completions
+			t.selectedIndex = 0
+			t.menuActive = false
+			t.ShowCompletions()
+		}
+		if atEnd {
+			if err := t.showInlineSuggestion(line, completions); err != nil {
+				return
+			}
+		}
+		t.writer.Flush()
+	}()
+}
+
+// CancelCompletions abandons any completions still being computed in the
+// background. The caller must hold t.mu.
+func (t *Terminal) CancelCompletions() {
+	t.completionGen++
+	if t.cancelCompletion != nil {
+		t.cancelCompletion()
+		t.cancelCompletion = nil
+	}
+}
+
+// SetTabAcceptsFirst selects whether Tab accepts the selected completion
+// straight away instead of first inserting the text all candidates share
+func (t *Terminal) SetTabAcceptsFirst(accept bool) {
+	t.tabAcceptsFirst = accept
+}
+
+// SetSuggestionHint turns on or off showing the whole inline suggestion in
+// brackets at the right edge of the terminal, as well as the rest of it
+// after the cursor. It is on by default, and left out whenever it would
+// come too close to the input.
+func (t *Terminal) SetSuggestionHint(on bool) {
+	t.suggestionHint = on
+}
+
+// TabAcceptsFirst reports whether Tab accepts the selected completion
+// straight away
+func (t *Terminal) TabAcceptsFirst() bool {
+	return t.tabAcceptsFirst
+}
+
+// CommonCompletionPrefix returns the text that every completion starts with,
+// to insert in place of the last replace runes before the cursor. History
+// entries are only considered when there is nothing else, since they
+// replace the whole line. ok is false when the shared text would add
+// nothing to the input.
+func CommonCompletionPrefix(completions []Completion) (text string, replace int, ok bool) {
+	var candidates []Completion
+	for _, c := range completions {
+		if c.Kind != CompletionHistory {
+			candidates = append(candidates, c)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = completions
+	}
+	if len(candidates) == 0 {
+		return "", 0, false
+	}
+
+	prefix := []rune(candidates[0].Text)
+	replace = candidates[0].Replace
+	for _, c := range candidates[1:] {
+		if c.Replace != replace {
+			return "", 0, false
+		}
+		runes := []rune(c.Text)
+		n := 0
+		for n < len(prefix) && n < len(runes) && prefix[n] == runes[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	if len(prefix) <= replace {
+		return "", 0, false
+	}
+	return string(prefix), replace, true
+}
+
+// SetShowHiddenFiles selects whether hidden files are offered as completions
+// even when the typed name doesn't start with a dot
+func (t *Terminal) SetShowHiddenFiles(show bool) {
+	t.files.ShowHidden = show
+}
+
+// RefreshCommandCache rescans PATH for the executables offered as command
+// completions, for example after installing a program
+func (t *Terminal) RefreshCommandCache() {
+	t.commands.Refresh()
+}
+
+// HistoryCompleter offers previous command lines that match the text before
+// the cursor, best match first and then most recent first
+type HistoryCompleter struct {
+	History func() []string // Returns the history, oldest first
+	Limit   int             // Maximum number of entries offered
+	Mode    MatchMode
+	Stats   *UsageStats // Ranks the most used entries first, if set
+}
+
+// SetMatchMode implements matchModeSetter
+func (h *HistoryCompleter) SetMatchMode(mode MatchMode) {
+	h.Mode = mode
+}
+
+// Complete implements Completer
+func (h *HistoryCompleter) Complete(line string, pos int) []Completion {
+	before := string([]rune(line)[:pos])
+
+	var completions []Completion
+	for _, cmd := range newestFirst(h.History()) {
+		score, matched, ok := match(h.Mode, before, cmd)
+		if !ok {
+			continue
+		}
+		completions = append(completions, Completion{
+			Text:    cmd,
+			Kind:    CompletionHistory,
+			Replace: pos,
+			Score:   score,
+			Matched: matched,
+		})
+		// Prefix matches all score the same, so without usage stats the most
+		// recent are the best
+		if h.Mode == MatchPrefix && h.Stats == nil && len(completions) == h.Limit {
+			break
+		}
+	}
+
+	h.Stats.sortByFrecency(completions)
+	if len(completions) > h.Limit {
+		completions = completions[:h.Limit]
+	}
+	return completions
+}
+
+// defaultCommandCacheTTL is how long the PATH scan is reused before it is
+// rebuilt to pick up newly installed programs
+const defaultCommandCacheTTL = time.Minute
+
+// CommandCompleter offers builtins and executables on PATH for the first
+// word. The executables are indexed once and the index is reused until PATH
+// changes or TTL has passed.
+type CommandCompleter struct {
+	TTL      time.Duration // How long the index stays valid, forever if zero
+	Mode     MatchMode
+	Stats    *UsageStats              // Ranks the most used commands first, if set
+	Aliases  func() map[string]string // Aliases offered alongside commands, if set
+	Builtins func() map[string]string // Builtins offered alongside commands, with their summaries, if set
+
+	mu    sync.Mutex
+	path  string    // PATH the index was built from
+	built time.Time // When the index was built
+	names []string  // Sorted, unique command names
+}
+
+// NewCommandCompleter returns a CommandCompleter with the default TTL
+func NewCommandCompleter() *CommandCompleter {
+	return &CommandCompleter{TTL: defaultCommandCacheTTL}
+}
+
+// Refresh rebuilds the index of executables on PATH
+func (c *CommandCompleter) Refresh() {
+	path := os.Getenv("PATH")
+
+	seen := make(map[string]bool)
+	for _, dir := range filepath.SplitList(path) {
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			if !seen[file.Name()] && isExecutable(filepath.Join(dir, file.Name())) {
+				seen[file.Name()] = true
+			}
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	c.mu.Lock()
+	c.path = path
+	c.built = time.Now()
+	c.names = names
+	c.mu.Unlock()
+}
+
+// commands returns the index, rebuilding it first if it is stale
+func (c *CommandCompleter) commands() []string {
+	c.mu.Lock()
+	stale := c.names == nil || c.path != os.Getenv("PATH") ||
+		(c.TTL > 0 && time.Since(c.built) > c.TTL)
+	c.mu.Unlock()
+	if stale {
+		c.Refresh()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.names
+}
+
+// Has reports whether name is in the index of executables on PATH. On
+// Windows the extension can be left out, as when the command is run, and
+// case is ignored.
+func (c *CommandCompleter) Has(name string) bool {
+	return indexHas(c.commands(), name)
+}
+
+// SetMatchMode implements matchModeSetter
+func (c *CommandCompleter) SetMatchMode(mode MatchMode) {
+	c.Mode = mode
+}
+
+// Complete implements Completer
+func (c *CommandCompleter) Complete(line string, pos int) []Completion {
+	w, isCommand := wordAt(line, pos)
+	word := w.text
+	if !isCommand || word == "" {
+		return nil
+	}
+
+	names, descriptions := c.candidates()
+	replace := pos - w.start
+	var completions []Completion
+
+	if c.Mode == MatchPrefix {
+		// The names are sorted, so the matches are the run starting at the
+		// first name not less than the prefix
+		for i := sort.SearchStrings(names, word); i < len(names) && strings.HasPrefix(names[i], word); i++ {
+			completions = append(completions, Completion{
+				Text:        quoteWord(names[i], w.openQuote, true),
+				Display:     names[i],
+				Kind:        CompletionCommand,
+				Description: descriptions[names[i]],
+				Replace:     replace,
+				Score:       scoreExactPrefix,
+				Matched:     prefixPositions(word),
+			})
+		}
+		c.Stats.sortByFrecency(completions)
+		return completions
+	}
+
+	for _, name := range names {
+		if score, matched, ok := match(c.Mode, word, name); ok {
+			completions = append(completions, Completion{
+				Text:        quoteWord(name, w.openQuote, true),
+				Display:     name,
+				Kind:        CompletionCommand,
+				Description: descriptions[name],
+				Replace:     replace,
+				Score:       score,
+				Matched:     matched,
+			})
+		}
+	}
+	c.Stats.sortByFrecency(completions)
+	return completions
+}
+
+// candidates returns the sorted names of the executables on PATH, builtins
+// and aliases, along with descriptions of the builtins and aliases. An alias
+// takes the place of a command with the same name, as when it is run.
+func (c *CommandCompleter) candidates() ([]string, map[string]string) {
+	names := c.commands()
+	descriptions := make(map[string]string)
+	if c.Builtins != nil {
+		descriptions = c.Builtins()
+	}
+	if c.Aliases != nil {
+		for name, value := range c.Aliases() {
+			descriptions[name] = "alias for " + value
+		}
+	}
+	if len(descriptions) == 0 {
+		return names, descriptions
+	}
+
+	merged := append([]string(nil), names...)
+	for name := range descriptions {
+		if !containsSorted(names, name) {
+			merged = append(merged, name)
+		}
+	}
+	sort.Strings(merged)
+	return merged, descriptions
+}
+
+// containsSorted reports whether the sorted names include name
+func containsSorted(names []string, name string) bool {
+	i := sort.SearchStrings(names, name)
+	return i < len(names) && names[i] == name
+}
+
+// prefixPositions returns the rune offsets matched by a prefix of s
+func prefixPositions(s string) []int {
+	positions := make([]int, len([]rune(s)))
+	for i := range positions {
+		positions[i] = i
+	}
+	return positions
+}
+
+// FileCompleter offers files and directories for arguments. Hidden entries,
+// whose names start with a dot, are only offered when the typed name starts
+// with a dot too, unless ShowHidden is set.
+type FileCompleter struct {
+	Mode       MatchMode
+	ShowHidden bool
+}
+
+// SetMatchMode implements matchModeSetter
+func (f *FileCompleter) SetMatchMode(mode MatchMode) {
+	f.Mode = mode
+}
+
+// Complete implements Completer
+func (f *FileCompleter) Complete(line string, pos int) []Completion {
+	return f.CompleteContext(context.Background(), line, pos)
+}
+
+// CompleteContext implements ContextCompleter. Reading a large or slow
+// directory stops as soon as ctx is canceled.
+func (f *FileCompleter) CompleteContext(ctx context.Context, line string, pos int) []Completion {
+	return f.complete(ctx, line, pos, false)
+}
+
+// complete returns the files and directories matching the word before the
+// cursor, or only the directories if dirsOnly is set
+func (f *FileCompleter) complete(ctx context.Context, line string, pos int, dirsOnly bool) []Completion {
+	w, isCommand := wordAt(line, pos)
+	if isCommand {
+		return nil
+	}
+	word := w.text
+
+	// A lone ~name completes to a user's home directory. The shell doesn't
+	// expand ~ inside quotes, so neither do we.
+	sep := lastSeparator(word)
+	if strings.HasPrefix(word, "~") && sep < 0 && w.openQuote == 0 {
+		return f.completeUsers(word[1:], pos-w.start)
+	}
+
+	// Split the argument into the directory typed so far and the name
+	// prefix. On Windows the directory may be a drive, as in C:name.
+	typedDir := filepath.VolumeName(word)
+	prefix := word[len(typedDir):]
+	if sep >= 0 {
+		typedDir, prefix = word[:sep+1], word[sep+1:]
+	}
+	// Directories are completed with the separator already typed, which on
+	// Windows may be a backslash
+	dirSuffix := "/"
+	if sep >= 0 {
+		dirSuffix = word[sep : sep+1]
+	}
+
+	// Expand ~ and ~user in the directory to search, keeping the typed form
+	// for the inserted text
+	searchDir := typedDir
+	if w.openQuote == 0 {
+		searchDir = expandTilde(searchDir)
+	}
+	if searchDir == "" {
+		searchDir = "."
+	}
+
+	files, err := readDirContext(ctx, searchDir)
+	if err != nil {
+		return nil
+	}
+
+	var completions []Completion
+	for _, file := range files {
+		name := file.Name()
+
+		// Links to directories count as directories
+		isDir := file.IsDir()
+		if file.Type()&os.ModeSymlink != 0 {
+			if info, err := os.Stat(filepath.Join(searchDir, name)); err == nil {
+				isDir = info.IsDir()
+			}
+		}
+		if dirsOnly && !isDir {
+			continue
+		}
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(prefix, ".") && !f.ShowHidden {
+			continue
+		}
+
+		score, matched, ok := 0, []int(nil), strings.HasPrefix(name, prefix)
+		if ok {
+			score, matched = scoreExactPrefix, prefixPositions(prefix)
+		} else if f.Mode == MatchFuzzy {
+			score, matched, ok = fuzzyMatch(prefix, name)
+		}
+		if !ok {
+			continue
+		}
+		description := ""
+		if isDir {
+			name += dirSuffix
+			description = "dir"
+		}
+		completions = append(completions, Completion{
+			Text:        quoteWord(typedDir+name, w.openQuote, !isDir),
+			Display:     name,
+			Kind:        CompletionFile,
+			Description: description,
+			Replace:     pos - w.start,
+			Score:       score,
+			Matched:     matched,
+		})
+	}
+	sortByScore(completions)
+	return completions
+}
+
+// lastSeparator returns the index of the last path separator in path, or
+// -1 if there is none. Windows accepts backslashes as well as slashes.
+func lastSeparator(path string) int {
+	for i := len(path) - 1; i >= 0; i-- {
+		if os.IsPathSeparator(path[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// completeUsers completes ~prefix to the home directories of the users
+// whose names match prefix
+func (f *FileCompleter) completeUsers(prefix string, replace int) []Completion {
+	users, err := systemUsers()
+	if err != nil {
+		return nil
+	}
+
+	var completions []Completion
+	for _, u := range users {
+		score, matched, ok := match(f.Mode, prefix, u.Username)
+		if !ok {
+			continue
+		}
+		// Offsets are shifted past the ~
+		for i := range matched {
+			matched[i]++
+		}
+		completions = append(completions, Completion{
+			Text:        "~" + u.Username + "/",
+			Kind:        CompletionFile,
+			Description: u.HomeDir,
+			Replace:     replace,
+			Score:       score,
+			Matched:     matched,
+		})
+	}
+	sortByScore(completions)
+	return completions
+}
+
+// readDirContext reads the entries of dir sorted by name, like os.ReadDir,
+// checking between batches of entries whether ctx has been canceled
+func readDirContext(ctx context.Context, dir string) ([]os.DirEntry, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []os.DirEntry
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		batch, err := f.ReadDir(256)
+		entries = append(entries, batch...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
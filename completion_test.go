@@ -0,0 +1,177 @@
+package goterm
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeExecutables creates an executable file in dir for each of names
+func writeExecutables(tb testing.TB, dir string, names ...string) {
+	tb.Helper()
+	if runtime.GOOS == "windows" {
+		tb.Skip("executables are told apart by extension on Windows")
+	}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), 0755); err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
+
+// completionTexts returns the text of each completion
+func completionTexts(completions []Completion) []string {
+	texts := []string{}
+	for _, c := range completions {
+		texts = append(texts, c.Text)
+	}
+	return texts
+}
+
+func TestCommandCompleterCache(t *testing.T) {
+	dir1, dir2 := t.TempDir(), t.TempDir()
+	writeExecutables(t, dir1, "alpha", "beta")
+	writeExecutables(t, dir2, "alps")
+	t.Setenv("PATH", dir1)
+
+	c := NewCommandCompleter()
+	complete := func() []string {
+		return completionTexts(c.Complete("al", 2))
+	}
+	if got, want := complete(), []string{"alpha"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("completions = %q, want %q", got, want)
+	}
+
+	// A program installed since the scan isn't seen until the index is
+	// rebuilt
+	writeExecutables(t, dir1, "alto")
+	if got, want := complete(), []string{"alpha"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("completions with a fresh index = %q, want %q", got, want)
+	}
+
+	// Changing PATH rebuilds it
+	t.Setenv("PATH", dir1+string(os.PathListSeparator)+dir2)
+	if got, want := complete(), []string{"alpha", "alps", "alto"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("completions after PATH changed = %q, want %q", got, want)
+	}
+
+	// So does the TTL passing
+	writeExecutables(t, dir2, "altar")
+	if got, want := complete(), []string{"alpha", "alps", "alto"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("completions within the TTL = %q, want %q", got, want)
+	}
+	c.built = time.Now().Add(-c.TTL - time.Second)
+	if got, want := complete(), []string{"alpha", "alps", "altar", "alto"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("completions after the TTL = %q, want %q", got, want)
+	}
+
+	// A TTL of zero keeps the index until PATH changes
+	c.TTL = 0
+	writeExecutables(t, dir1, "alder")
+	c.built = time.Now().Add(-time.Hour)
+	if got, want := complete(), []string{"alpha", "alps", "altar", "alto"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("completions with no TTL = %q, want %q", got, want)
+	}
+}
+
+// BenchmarkCommandCompleter measures completing a command on each key
+// press, scanning PATH every time against reusing the index
+func BenchmarkCommandCompleter(b *testing.B) {
+	dir := b.TempDir()
+	for i := 0; i < 2000; i++ {
+		writeExecutables(b, dir, fmt.Sprintf("cmd%04d", i))
+	}
+	b.Setenv("PATH", dir)
+
+	b.Run("cold", func(b *testing.B) {
+		c := NewCommandCompleter()
+		for i := 0; i < b.N; i++ {
+			c.Refresh()
+			c.Complete("cmd1", 4)
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		c := NewCommandCompleter()
+		c.Refresh()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			c.Complete("cmd1", 4)
+		}
+	})
+}
+
+func TestFileCompleterHidden(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{".bashrc", ".config/", ".hidden.txt", "notes.txt", "src/", "..weird"} {
+		path := filepath.Join(dir, name)
+		var err error
+		if strings.HasSuffix(name, "/") {
+			err = os.Mkdir(path, 0755)
+		} else {
+			err = os.WriteFile(path, nil, 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		typed      string
+		showHidden bool
+		want       []string
+	}{
+		// Hidden entries are left out unless a dot is typed, and . and ..
+		// are never offered
+		{typed: "", want: []string{"notes.txt", "src/"}},
+		{typed: "n", want: []string{"notes.txt"}},
+		{typed: ".", want: []string{"..weird", ".bashrc", ".config/", ".hidden.txt"}},
+		{typed: ".h", want: []string{".hidden.txt"}},
+		{typed: "..", want: []string{"..weird"}},
+		// or they are asked for
+		{typed: "", showHidden: true, want: []string{"..weird", ".bashrc", ".config/", ".hidden.txt", "notes.txt", "src/"}},
+		{typed: "h", showHidden: true, want: nil},
+	}
+	for _, tt := range tests {
+		f := &FileCompleter{ShowHidden: tt.showHidden}
+		line := "cat " + dir + "/" + tt.typed
+		var got []string
+		for _, c := range f.Complete(line, len([]rune(line))) {
+			got = append(got, strings.TrimPrefix(c.Text, dir+"/"))
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("completions of %q with ShowHidden %v = %q, want %q", tt.typed, tt.showHidden, got, tt.want)
+		}
+	}
+
+	// SetShowHiddenFiles reaches the terminal's file completer
+	term := newTerminal(nil, io.Discard)
+	line := "cat " + dir + "/.b"
+	if got := completionTexts(term.GetCompletions(line, len([]rune(line)))); len(got) != 1 {
+		t.Errorf("completions of .b = %q, want .bashrc only", got)
+	}
+	line = "cat " + dir + "/"
+	count := func() int {
+		n := 0
+		for _, c := range term.GetCompletions(line, len([]rune(line))) {
+			if c.Kind == CompletionFile {
+				n++
+			}
+		}
+		return n
+	}
+	if n := count(); n != 2 {
+		t.Errorf("terminal offers %d files, want 2", n)
+	}
+	term.SetShowHiddenFiles(true)
+	if n := count(); n != 6 {
+		t.Errorf("terminal showing hidden files offers %d files, want 6", n)
+	}
+}
@@ -0,0 +1,788 @@
+package goterm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// configFilePath returns the path of the user's config file
+func configFilePath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("could not get config directory: %v", err)
+	}
+	return filepath.Join(configDir, "go-term", "config"), nil
+}
+
+// Config holds the settings read from a config file by LoadConfig. Settings
+// the file leaves out are nil or empty, and keep their current values when
+// the config is applied.
+type Config struct {
+	Prompt      PromptConfig
+	Colors      Colors // Colors left empty are the defaults
+	NoColor     *bool  // no_color in [colors]: draw nothing in color
+	Theme       string // theme in [colors]: the theme the colors change, if not the default
+	KeyBindings []KeyBinding
+	History     HistoryConfig
+	Completion  CompletionConfig
+	Shell       ShellConfig
+	Notify      NotifyConfig
+	Aliases     map[string]string
+
+	// Warnings describes parts of the file that were ignored, such as
+	// unknown settings
+	Warnings []string
+}
+
+// PromptConfig is the [prompt] section of a config file
+type PromptConfig struct {
+	Template          *PromptTemplate // template: the prompt's format, in place of the default prompt
+	StatusColor       *bool           // status_color: show the prompt in red after a command fails
+	StatusCode        *bool           // status_code: show a failed command's exit status in the prompt
+	DurationThreshold *time.Duration  // duration_threshold: how long a command runs before its time is shown
+	Title             *bool           // title: set the window title to the working directory and running command
+	ReportCwd         *bool           // report_cwd: tell the terminal the working directory, for new tabs to open in
+	Transient         *string         // transient: the prompt lines entered are redrawn with, "" for none
+}
+
+// KeyBinding is a line of the [keybindings] section of a config file,
+// binding a key or two key sequence to an action
+type KeyBinding struct {
+	Keys   []KeyEvent
+	Action Action
+}
+
+// HistoryConfig is the [history] section of a config file. The settings
+// are described by HistoryOptions.
+type HistoryConfig struct {
+	Ignore               []string // ignore
+	IgnoreSpace          *bool    // ignore_space
+	EraseDups            *bool    // erase_dups
+	Dedup                *bool    // dedup
+	Share                *bool    // share
+	SearchEscapeRestores *bool    // search_escape_restores
+}
+
+// CompletionConfig is the [completion] section of a config file
+type CompletionConfig struct {
+	Match           *MatchMode // match: "prefix" or "fuzzy"
+	MenuRows        *int       // menu_rows: rows of the completion menu
+	ShowHidden      *bool      // show_hidden: always offer hidden files
+	TabAcceptsFirst *bool      // tab_accepts_first: Tab accepts the selected completion
+	SuggestionHint  *bool      // suggestion_hint: show the whole inline suggestion at the right edge
+}
+
+// ShellConfig is the [shell] section of a config file
+type ShellConfig struct {
+	Path         string // path: the shell commands are run with in place of $SHELL
+	NoMatchError *bool  // nomatch_error: a glob matching nothing is an error
+	PageOutput   *bool  // page_output: page command output that doesn't fit on the screen
+	ColorStderr  *bool  // color_stderr: draw what commands write to standard error in color
+	RestoreCwd   *bool  // restore_cwd: start in the directory the last session ended in
+	// progress_after: how long a command is silent before a spinner and its
+	// running time are shown, 0 for never
+	ProgressAfter *time.Duration
+}
+
+// NotifyConfig is the [notify] section of a config file. The settings are
+// described by NotifyOptions.
+type NotifyConfig struct {
+	After   *time.Duration // after
+	Escape  *bool          // escape
+	Exclude []string       // exclude
+}
+
+// LoadConfig reads a config file, normally ~/.config/go-term/config. It is
+// TOML, with a table for each part of go-term, as written by
+// `go-term --print-default-config`. Values that can't be used are errors,
+// while unknown settings are only noted in Config.Warnings.
+func LoadConfig(path string) (*Config, error) {
+	sections, err := readConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	l := &configLoader{path: path, cfg: &Config{}}
+	for _, s := range sections {
+		var load func(configEntry) error
+		switch s.name {
+		case "":
+			load = func(e configEntry) error {
+				l.warn(e.line, "%s is not in a section", e.key)
+				return nil
+			}
+		case "prompt":
+			load = l.prompt
+		case "colors":
+			load = l.color
+		case "keybindings":
+			load = l.keyBinding
+		case "history":
+			load = l.history
+		case "completion":
+			load = l.completion
+		case "shell":
+			load = l.shell
+		case "notify":
+			load = l.notify
+		case "aliases":
+			load = l.alias
+		default:
+			l.warn(s.line, "unknown section [%s]", s.name)
+			continue
+		}
+		for _, e := range s.entries {
+			if err := load(e); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return l.cfg, nil
+}
+
+// Apply applies the settings to t. The prompt template and any colors not
+// set go back to their defaults, so that removing them from the file and
+// reloading it undoes the change.
+func (c *Config) Apply(t *Terminal) error {
+	p := c.Prompt
+	t.SetPromptTemplate(p.Template)
+	color, code := t.statusColor, t.statusCode
+	setBool(&color, p.StatusColor)
+	setBool(&code, p.StatusCode)
+	t.SetPromptStatus(color, code)
+	if p.DurationThreshold != nil {
+		t.SetDurationThreshold(*p.DurationThreshold)
+	}
+	titles := t.windowTitles
+	setBool(&titles, p.Title)
+	t.SetWindowTitles(titles)
+	reportCwd := t.reportCwd
+	setBool(&reportCwd, p.ReportCwd)
+	t.SetReportCwd(reportCwd)
+	transient := t.transientPrompt
+	if p.Transient != nil {
+		transient = *p.Transient
+	}
+	t.SetTransientPrompt(transient)
+
+	theme := "default"
+	if c.Theme != "" {
+		theme = c.Theme
+	}
+	colors, err := ThemeColors(theme)
+	if err != nil {
+		return err
+	}
+	setColor(&colors.Prompt, c.Colors.Prompt)
+	setColor(&colors.Command, c.Colors.Command)
+	setColor(&colors.UnknownCommand, c.Colors.UnknownCommand)
+	setColor(&colors.Quoted, c.Colors.Quoted)
+	setColor(&colors.Path, c.Colors.Path)
+	setColor(&colors.Suggestion, c.Colors.Suggestion)
+	setColor(&colors.PromptError, c.Colors.PromptError)
+	setColor(&colors.Menu, c.Colors.Menu)
+	setColor(&colors.MenuSelected, c.Colors.MenuSelected)
+	setColor(&colors.MenuHistory, c.Colors.MenuHistory)
+	setColor(&colors.Stderr, c.Colors.Stderr)
+	t.SetColors(colors)
+	t.theme = theme
+	noColor := false
+	setBool(&noColor, c.NoColor)
+	t.SetNoColor(noColor)
+
+	for _, b := range c.KeyBindings {
+		if err := t.SetKeySequence(b.Keys, b.Action); err != nil {
+			return err
+		}
+	}
+
+	h := c.History
+	opts := t.HistoryOptions()
+	if h.Ignore != nil {
+		opts.Ignore = h.Ignore
+	}
+	setBool(&opts.IgnoreSpace, h.IgnoreSpace)
+	setBool(&opts.EraseDups, h.EraseDups)
+	setBool(&opts.Dedup, h.Dedup)
+	setBool(&opts.Share, h.Share)
+	setBool(&opts.SearchEscapeRestores, h.SearchEscapeRestores)
+	if err := t.SetHistoryOptions(opts); err != nil {
+		return err
+	}
+
+	comp := c.Completion
+	if comp.Match != nil {
+		t.SetMatchMode(*comp.Match)
+	}
+	if comp.MenuRows != nil {
+		t.SetMenuRows(*comp.MenuRows)
+	}
+	if comp.ShowHidden != nil {
+		t.SetShowHiddenFiles(*comp.ShowHidden)
+	}
+	if comp.TabAcceptsFirst != nil {
+		t.SetTabAcceptsFirst(*comp.TabAcceptsFirst)
+	}
+	if comp.SuggestionHint != nil {
+		t.SetSuggestionHint(*comp.SuggestionHint)
+	}
+
+	if c.Shell.Path != "" {
+		if err := t.SetShell(c.Shell.Path); err != nil {
+			return err
+		}
+	}
+	if c.Shell.NoMatchError != nil {
+		t.SetNoMatchError(*c.Shell.NoMatchError)
+	}
+	if c.Shell.PageOutput != nil {
+		t.SetOutputPaging(*c.Shell.PageOutput)
+	}
+	if c.Shell.ColorStderr != nil {
+		t.SetColorStderr(*c.Shell.ColorStderr)
+	}
+	if c.Shell.RestoreCwd != nil {
+		t.SetRestoreCwd(*c.Shell.RestoreCwd)
+	}
+	if c.Shell.ProgressAfter != nil {
+		t.SetProgressIndicator(*c.Shell.ProgressAfter)
+	}
+
+	n := c.Notify
+	notify := t.NotifyOptions()
+	if n.After != nil {
+		notify.After = *n.After
+	}
+	setBool(&notify.Escape, n.Escape)
+	if n.Exclude != nil {
+		notify.Exclude = n.Exclude
+	}
+	t.SetNotifyOptions(notify)
+
+	for name, value := range c.Aliases {
+		if err := t.setAlias(name, value, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setBool sets *to to *from, if from is set
+func setBool(to *bool, from *bool) {
+	if from != nil {
+		*to = *from
+	}
+}
+
+// setColor sets *to to from, if from is set
+func setColor(to *string, from string) {
+	if from != "" {
+		*to = from
+	}
+}
+
+// loadConfigFile loads and applies the user's config file, if there is one,
+// writing any warnings to w
+func (t *Terminal) loadConfigFile(w io.Writer) error {
+	path, err := configFilePath()
+	if err != nil {
+		return err
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return err
+	}
+	for _, warning := range cfg.Warnings {
+		fmt.Fprintf(w, "Warning: %s\n", warning)
+	}
+	return cfg.Apply(t)
+}
+
+// ReloadConfigCommand runs `reload-config`, reading the config file again
+// so that changes to it, such as to the prompt or colors, take effect
+// straight away
+func (t *Terminal) ReloadConfigCommand(args []string, out io.Writer) error {
+	if len(args) > 0 {
+		return fmt.Errorf("reload-config: too many arguments")
+	}
+	if err := t.loadConfigFile(out); err != nil {
+		return fmt.Errorf("reload-config: %v", err)
+	}
+	return nil
+}
+
+// configLoader reads the entries of a config file into a Config
+type configLoader struct {
+	path string
+	cfg  *Config
+}
+
+// errorf returns an error about the entry e
+func (l *configLoader) errorf(e configEntry, format string, args ...any) error {
+	return fmt.Errorf("%s:%d: %s", l.path, e.line, fmt.Sprintf(format, args...))
+}
+
+// warn notes a problem with a line that is ignored
+func (l *configLoader) warn(line int, format string, args ...any) {
+	l.cfg.Warnings = append(l.cfg.Warnings, fmt.Sprintf("%s:%d: %s", l.path, line, fmt.Sprintf(format, args...)))
+}
+
+// unknown notes a setting that isn't known in its section
+func (l *configLoader) unknown(e configEntry) error {
+	l.warn(e.line, "unknown setting %q", e.key)
+	return nil
+}
+
+// boolValue returns the value of e, which must be true or false
+func (l *configLoader) boolValue(e configEntry) (*bool, error) {
+	value, err := strconv.ParseBool(e.value)
+	if err != nil || e.list != nil {
+		return nil, l.errorf(e, "%s must be true or false", e.key)
+	}
+	return &value, nil
+}
+
+// stringValue returns the value of e, which mustn't be an array
+func (l *configLoader) stringValue(e configEntry) (string, error) {
+	if e.list != nil {
+		return "", l.errorf(e, "%s must be a string", e.key)
+	}
+	return e.value, nil
+}
+
+// durationValue reads a duration such as 5s, or a plain number of seconds
+func (l *configLoader) durationValue(e configEntry) (*time.Duration, error) {
+	d, err := time.ParseDuration(e.value)
+	if seconds, ferr := strconv.ParseFloat(e.value, 64); err != nil && ferr == nil {
+		d, err = time.Duration(seconds*float64(time.Second)), nil
+	}
+	if err != nil || d < 0 || e.list != nil {
+		return nil, l.errorf(e, "%s must be a duration such as 5s", e.key)
+	}
+	return &d, nil
+}
+
+// prompt reads a setting from the [prompt] section
+func (l *configLoader) prompt(e configEntry) error {
+	p := &l.cfg.Prompt
+	var err error
+	switch e.key {
+	case "template":
+		format, err := l.stringValue(e)
+		if err != nil {
+			return err
+		}
+		template, unknown := ParsePromptTemplate(format)
+		for _, name := range unknown {
+			l.warn(e.line, "unknown prompt variable {%s}", name)
+		}
+		p.Template = template
+	case "status_color":
+		p.StatusColor, err = l.boolValue(e)
+	case "status_code":
+		p.StatusCode, err = l.boolValue(e)
+	case "title":
+		p.Title, err = l.boolValue(e)
+	case "report_cwd":
+		p.ReportCwd, err = l.boolValue(e)
+	case "transient":
+		var transient string
+		transient, err = l.stringValue(e)
+		p.Transient = &transient
+	case "duration_threshold":
+		p.DurationThreshold, err = l.durationValue(e)
+	default:
+		return l.unknown(e)
+	}
+	return err
+}
+
+// color reads a setting from the [colors] section
+func (l *configLoader) color(e configEntry) error {
+	c := &l.cfg.Colors
+	var to *string
+	switch e.key {
+	case "no_color":
+		var err error
+		l.cfg.NoColor, err = l.boolValue(e)
+		return err
+	case "theme":
+		theme, err := l.stringValue(e)
+		if err != nil {
+			return err
+		}
+		if _, err := ThemeColors(theme); err != nil {
+			return l.errorf(e, "%v", err)
+		}
+		l.cfg.Theme = theme
+		return nil
+	case "prompt":
+		to = &c.Prompt
+	case "command":
+		to = &c.Command
+	case "unknown_command":
+		to = &c.UnknownCommand
+	case "quoted":
+		to = &c.Quoted
+	case "path":
+		to = &c.Path
+	case "suggestion":
+		to = &c.Suggestion
+	case "prompt_error":
+		to = &c.PromptError
+	case "menu":
+		to = &c.Menu
+	case "menu_selected":
+		to = &c.MenuSelected
+	case "menu_history":
+		to = &c.MenuHistory
+	case "stderr":
+		to = &c.Stderr
+	default:
+		return l.unknown(e)
+	}
+	spec, err := l.stringValue(e)
+	if err != nil {
+		return err
+	}
+	if *to, err = ParseColor(spec); err != nil {
+		return l.errorf(e, "%v", err)
+	}
+	return nil
+}
+
+// keyBinding reads a line of the [keybindings] section, of the form
+// `key = action`, for example `ctrl-p = history-prev` or
+// `"ctrl-x u" = undo`. An empty action removes a binding.
+func (l *configLoader) keyBinding(e configEntry) error {
+	keys, err := ParseKeySequence(e.key)
+	if err != nil {
+		return l.errorf(e, "%v", err)
+	}
+	value, err := l.stringValue(e)
+	if err != nil {
+		return err
+	}
+	action := Action(value)
+	if !knownActions[action] {
+		return l.errorf(e, "unknown action %q", action)
+	}
+	l.cfg.KeyBindings = append(l.cfg.KeyBindings, KeyBinding{Keys: keys, Action: action})
+	return nil
+}
+
+// history reads a setting from the [history] section. ignore is an array
+// of glob patterns, or a colon separated list such as `ls:pwd:cd *`.
+func (l *configLoader) history(e configEntry) error {
+	h := &l.cfg.History
+	var err error
+	switch e.key {
+	case "ignore":
+		h.Ignore = []string{}
+		patterns := e.list
+		if patterns == nil {
+			patterns = strings.Split(e.value, ":")
+		}
+		for _, pattern := range patterns {
+			if pattern == "" {
+				continue
+			}
+			if _, err := globRegexp(pattern); err != nil {
+				return l.errorf(e, "invalid ignore pattern %q: %v", pattern, err)
+			}
+			h.Ignore = append(h.Ignore, pattern)
+		}
+	case "ignore_space":
+		h.IgnoreSpace, err = l.boolValue(e)
+	case "erase_dups":
+		h.EraseDups, err = l.boolValue(e)
+	case "dedup":
+		h.Dedup, err = l.boolValue(e)
+	case "share":
+		h.Share, err = l.boolValue(e)
+	case "search_escape_restores":
+		h.SearchEscapeRestores, err = l.boolValue(e)
+	default:
+		return l.unknown(e)
+	}
+	return err
+}
+
+// completion reads a setting from the [completion] section
+func (l *configLoader) completion(e configEntry) error {
+	c := &l.cfg.Completion
+	var err error
+	switch e.key {
+	case "match":
+		mode, err := ParseMatchMode(e.value)
+		if err != nil || e.list != nil {
+			return l.errorf(e, "match must be prefix or fuzzy")
+		}
+		c.Match = &mode
+	case "menu_rows":
+		rows, err := strconv.Atoi(e.value)
+		if err != nil || rows < 1 || e.list != nil {
+			return l.errorf(e, "menu_rows must be a positive number")
+		}
+		c.MenuRows = &rows
+	case "show_hidden":
+		c.ShowHidden, err = l.boolValue(e)
+	case "tab_accepts_first":
+		c.TabAcceptsFirst, err = l.boolValue(e)
+	case "suggestion_hint":
+		c.SuggestionHint, err = l.boolValue(e)
+	default:
+		return l.unknown(e)
+	}
+	return err
+}
+
+// shell reads a setting from the [shell] section
+func (l *configLoader) shell(e configEntry) error {
+	s := &l.cfg.Shell
+	var err error
+	switch e.key {
+	case "path":
+		path, err := l.stringValue(e)
+		if err != nil {
+			return err
+		}
+		path = expandTilde(path)
+		if _, err := exec.LookPath(path); err != nil {
+			return l.errorf(e, "shell %q not found", path)
+		}
+		s.Path = path
+	case "nomatch_error":
+		s.NoMatchError, err = l.boolValue(e)
+	case "page_output":
+		s.PageOutput, err = l.boolValue(e)
+	case "color_stderr":
+		s.ColorStderr, err = l.boolValue(e)
+	case "restore_cwd":
+		s.RestoreCwd, err = l.boolValue(e)
+	case "progress_after":
+		s.ProgressAfter, err = l.durationValue(e)
+	default:
+		return l.unknown(e)
+	}
+	return err
+}
+
+// notify reads a setting from the [notify] section. exclude is an array of
+// command names, or a colon separated list such as `vim:less`.
+func (l *configLoader) notify(e configEntry) error {
+	n := &l.cfg.Notify
+	var err error
+	switch e.key {
+	case "after":
+		n.After, err = l.durationValue(e)
+	case "escape":
+		n.Escape, err = l.boolValue(e)
+	case "exclude":
+		n.Exclude = []string{}
+		names := e.list
+		if names == nil {
+			names = strings.Split(e.value, ":")
+		}
+		for _, name := range names {
+			if name != "" {
+				n.Exclude = append(n.Exclude, name)
+			}
+		}
+	default:
+		return l.unknown(e)
+	}
+	return err
+}
+
+// alias reads a line of the [aliases] section, of the form
+// `name = "command line"`
+func (l *configLoader) alias(e configEntry) error {
+	value, err := l.stringValue(e)
+	if err != nil {
+		return err
+	}
+	if !validAliasName(e.key) {
+		return l.errorf(e, "%q: invalid alias name", e.key)
+	}
+	if l.cfg.Aliases == nil {
+		l.cfg.Aliases = make(map[string]string)
+	}
+	l.cfg.Aliases[e.key] = value
+	return nil
+}
+
+// configSection is a [section] of a config file. Entries before the first
+// section header are in a section with no name.
+type configSection struct {
+	name    string
+	line    int
+	entries []configEntry
+}
+
+// configEntry is a `key = value` line from a config file section
+type configEntry struct {
+	line  int
+	key   string
+	value string
+	list  []string // The items of an array value, nil for any other value
+}
+
+// readConfig reads the sections of a config file. It is TOML, as far as
+// go-term needs: [section] headers and `key = value` lines, where the key is
+// bare or quoted and the value is a string in double or single quotes, a
+// number, true or false, or an array of strings on one line. Blank lines
+// and comments starting with # are skipped. Unquoted text, as written by
+// older versions, is read as a string.
+func readConfig(path string) ([]configSection, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	sections := []configSection{{}}
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// Section header
+		if strings.HasPrefix(line, "[") {
+			name, rest, ok := strings.Cut(line[1:], "]")
+			if !ok || !isConfigComment(rest) {
+				return nil, fmt.Errorf("%s:%d: invalid section header", path, lineNum)
+			}
+			sections = append(sections, configSection{name: strings.TrimSpace(name), line: lineNum})
+			continue
+		}
+
+		e, err := parseConfigEntry(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNum, err)
+		}
+		e.line = lineNum
+		s := &sections[len(sections)-1]
+		s.entries = append(s.entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return sections, nil
+}
+
+// parseConfigEntry parses a `key = value` line
+func parseConfigEntry(line string) (configEntry, error) {
+	var e configEntry
+	rest := line
+	if line[0] == '"' || line[0] == '\'' {
+		var err error
+		if e.key, rest, err = parseConfigString(line); err != nil {
+			return e, err
+		}
+		rest = strings.TrimSpace(rest)
+		if !strings.HasPrefix(rest, "=") {
+			return e, fmt.Errorf("expected key = value")
+		}
+		rest = rest[1:]
+	} else {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return e, fmt.Errorf("expected key = value")
+		}
+		e.key, rest = strings.TrimSpace(key), value
+	}
+
+	rest = strings.TrimSpace(rest)
+	switch {
+	case strings.HasPrefix(rest, `"`), strings.HasPrefix(rest, "'"):
+		value, after, err := parseConfigString(rest)
+		if err != nil {
+			return e, err
+		}
+		if !isConfigComment(after) {
+			return e, fmt.Errorf("unexpected text after string")
+		}
+		e.value = value
+	case strings.HasPrefix(rest, "["):
+		list, err := parseConfigArray(rest)
+		if err != nil {
+			return e, err
+		}
+		e.list = list
+	default:
+		value, _, _ := strings.Cut(rest, "#")
+		e.value = strings.TrimSpace(value)
+	}
+	return e, nil
+}
+
+// parseConfigArray parses an array of strings, such as ["ls", "cd *"]
+func parseConfigArray(s string) ([]string, error) {
+	list := []string{}
+	rest := strings.TrimSpace(s[1:])
+	for !strings.HasPrefix(rest, "]") {
+		if rest == "" {
+			return nil, fmt.Errorf("unterminated array")
+		}
+		item, after, err := parseConfigString(rest)
+		if err != nil {
+			return nil, fmt.Errorf("arrays can only hold strings")
+		}
+		list = append(list, item)
+		rest = strings.TrimSpace(after)
+		if strings.HasPrefix(rest, ",") {
+			rest = strings.TrimSpace(rest[1:])
+		} else if !strings.HasPrefix(rest, "]") {
+			return nil, fmt.Errorf("expected , or ] in array")
+		}
+	}
+	if !isConfigComment(rest[1:]) {
+		return nil, fmt.Errorf("unexpected text after array")
+	}
+	return list, nil
+}
+
+// parseConfigString parses the string s starts with, in double quotes with
+// escapes such as \" and \n, or in single quotes taken literally. The text
+// after it is returned too.
+func parseConfigString(s string) (string, string, error) {
+	if s == "" || s[0] != '"' && s[0] != '\'' {
+		return "", "", fmt.Errorf("expected a string")
+	}
+	if s[0] == '\'' {
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated string")
+		}
+		return s[1 : end+1], s[end+2:], nil
+	}
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			value, err := strconv.Unquote(s[:i+1])
+			if err != nil {
+				return "", "", fmt.Errorf("invalid string %s", s[:i+1])
+			}
+			return value, s[i+1:], nil
+		}
+	}
+	return "", "", fmt.Errorf("unterminated string")
+}
+
+// isConfigComment reports whether s, the rest of a line, is blank or a
+// comment
+func isConfigComment(s string) bool {
+	s = strings.TrimSpace(s)
+	return s == "" || strings.HasPrefix(s, "#")
+}
@@ -0,0 +1,43 @@
+package goterm
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SetReportCwd turns on or off telling the terminal the working directory,
+// at startup and after each change of directory, so that it can open new
+// tabs and windows in the same directory. It is on by default.
+func (t *Terminal) SetReportCwd(on bool) {
+	t.reportCwd = on
+}
+
+// cwdSequence returns the OSC 7 escape sequence reporting dir as the
+// working directory on host, as a file URL with the path percent-encoded
+func cwdSequence(host, dir string) string {
+	path := filepath.ToSlash(dir)
+	if !strings.HasPrefix(path, "/") {
+		// A Windows path, C:/Users, is given as /C:/Users
+		path = "/" + path
+	}
+	u := url.URL{Scheme: "file", Host: host, Path: path}
+	return "\033]7;" + u.String() + "\a"
+}
+
+// reportWorkingDir tells the terminal the working directory, if it has
+// changed since it was last told
+func (t *Terminal) reportWorkingDir() {
+	if !t.reportCwd || !t.oscSupported() {
+		return
+	}
+	cwd, err := os.Getwd()
+	if err != nil || cwd == t.reportedCwd {
+		return
+	}
+	host, _ := os.Hostname()
+	t.reportedCwd = cwd
+	t.writer.WriteString(cwdSequence(host, cwd))
+	t.flush()
+}
@@ -0,0 +1,26 @@
+package goterm
+
+import "testing"
+
+func TestCwdSequence(t *testing.T) {
+	tests := []struct {
+		host, dir string
+		want      string
+	}{
+		{host: "box", dir: "/home/me", want: "\033]7;file://box/home/me\a"},
+		{host: "box", dir: "/", want: "\033]7;file://box/\a"},
+		{host: "box", dir: "/home/me/My Documents", want: "\033]7;file://box/home/me/My%20Documents\a"},
+		{host: "box", dir: "/tmp/日本", want: "\033]7;file://box/tmp/%E6%97%A5%E6%9C%AC\a"},
+		{host: "box", dir: "/tmp/café au lait", want: "\033]7;file://box/tmp/caf%C3%A9%20au%20lait\a"},
+		// Characters that would end the path or the sequence are encoded
+		{host: "box", dir: "/tmp/a#b?c%d", want: "\033]7;file://box/tmp/a%23b%3Fc%25d\a"},
+		{host: "box", dir: "/tmp/bell\a", want: "\033]7;file://box/tmp/bell%07\a"},
+		{host: "", dir: "/home/me", want: "\033]7;file:///home/me\a"},
+		{host: "box", dir: "C:/Users/me", want: "\033]7;file://box/C:/Users/me\a"},
+	}
+	for _, tt := range tests {
+		if got := cwdSequence(tt.host, tt.dir); got != tt.want {
+			t.Errorf("cwdSequence(%q, %q) = %q, want %q", tt.host, tt.dir, got, tt.want)
+		}
+	}
+}
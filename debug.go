@@ -0,0 +1,215 @@
+package goterm
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// traceQueue is how many events can wait to be written to the trace log.
+// Any more are dropped rather than holding up the editor.
+const traceQueue = 1024
+
+// TracePath returns the path of the trace log, ~/.cache/go-term/trace.log
+func TracePath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("could not get cache directory: %v", err)
+	}
+	return filepath.Join(cacheDir, "go-term", "trace.log"), nil
+}
+
+// WithTrace logs the bytes read from the terminal for each key, and what
+// the editor writes to it, to the file at path, for finding out what a
+// terminal sends and is sent. Commands' output isn't logged. The log is
+// written in the background, so tracing doesn't slow the editor down.
+func WithTrace(path string) Option {
+	return func(t *Terminal) error {
+		tr, err := openTracer(path)
+		if err != nil {
+			return err
+		}
+		t.trace = tr
+		t.writer.setTrace(tr)
+		return nil
+	}
+}
+
+// traceEvent is input read or output written, as logged
+type traceEvent struct {
+	time time.Time
+	out  bool   // Written to the terminal rather than read from it
+	data []byte // The bytes, or nil for command output
+	size int    // Number of bytes of command output left out
+	key  string // Name of the key the bytes read were decoded as
+}
+
+// tracer writes trace events to a file from a goroutine of its own
+type tracer struct {
+	mu      sync.Mutex // Guards closed and dropped
+	closed  bool
+	dropped int // Events dropped since the last one logged
+	events  chan traceEvent
+	done    chan struct{} // Closed once every event is written
+	f       *os.File
+}
+
+// openTracer starts logging trace events to the file at path, appending
+// to what is already there
+func openTracer(path string) (*tracer, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("could not create trace log: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("could not create trace log: %w", err)
+	}
+	tr := &tracer{events: make(chan traceEvent, traceQueue), done: make(chan struct{}), f: f}
+	fmt.Fprintf(f, "--- go-term trace started %s, TERM=%s\n", time.Now().Format(time.RFC3339), os.Getenv("TERM"))
+	go tr.run()
+	return tr, nil
+}
+
+// log queues e to be written, dropping it if the queue is full
+func (tr *tracer) log(e traceEvent) {
+	if tr == nil {
+		return
+	}
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if tr.closed {
+		return
+	}
+	e.time = time.Now()
+	select {
+	case tr.events <- e:
+	default:
+		tr.dropped++
+	}
+}
+
+// input logs data, read from the terminal and decoded as key
+func (tr *tracer) input(data []byte, key KeyEvent) {
+	tr.log(traceEvent{data: data, key: KeyName(key)})
+}
+
+// output logs data, written to the terminal. It is copied, so the caller
+// can reuse it.
+func (tr *tracer) output(data []byte) {
+	if len(data) > 0 {
+		tr.log(traceEvent{out: true, data: append([]byte(nil), data...)})
+	}
+}
+
+// run writes events as they come, flushing whenever there are no more
+// waiting
+func (tr *tracer) run() {
+	defer close(tr.done)
+	w := bufio.NewWriter(tr.f)
+	for e := range tr.events {
+		tr.mu.Lock()
+		dropped := tr.dropped
+		tr.dropped = 0
+		tr.mu.Unlock()
+		if dropped > 0 {
+			fmt.Fprintf(w, "%s [%d events dropped]\n", e.time.Format("15:04:05.000000"), dropped)
+		}
+
+		fmt.Fprint(w, e.time.Format("15:04:05.000000"))
+		switch {
+		case e.out && e.data == nil:
+			fmt.Fprintf(w, " out [%d bytes of command output]\n", e.size)
+		case e.out:
+			fmt.Fprintf(w, " out %s\n", strconv.Quote(string(e.data)))
+		default:
+			fmt.Fprintf(w, " in  % x %s %s\n", e.data, strconv.Quote(string(e.data)), e.key)
+		}
+		if len(tr.events) == 0 {
+			w.Flush()
+		}
+	}
+	w.Flush()
+}
+
+// close writes out the events still waiting and closes the log
+func (tr *tracer) close() {
+	if tr == nil {
+		return
+	}
+	tr.mu.Lock()
+	if tr.closed {
+		tr.mu.Unlock()
+		return
+	}
+	tr.closed = true
+	close(tr.events)
+	tr.mu.Unlock()
+	<-tr.done
+	tr.f.Close()
+}
+
+// keyRecorder is a KeySource keeping the bytes read through it
+type keyRecorder struct {
+	src  KeySource
+	data []byte
+}
+
+func (r *keyRecorder) ReadChar() (byte, error) {
+	b, err := r.src.ReadChar()
+	if err == nil {
+		r.data = append(r.data, b)
+	}
+	return b, err
+}
+
+func (r *keyRecorder) WaitForInput(d time.Duration) bool {
+	return r.src.WaitForInput(d)
+}
+
+// readKeyBytes reads and decodes the next key press, returning the bytes
+// it was sent as too, and logs them if tracing is on
+func (t *Terminal) readKeyBytes() (KeyEvent, []byte, error) {
+	rec := &keyRecorder{src: t}
+	key, err := DecodeKey(rec)
+	if len(rec.data) > 0 {
+		t.trace.input(rec.data, key)
+	}
+	return key, rec.data, err
+}
+
+// DebugKeysCommand runs `debug-keys`, which shows each key as it is
+// pressed: the bytes the terminal sent, the name it is bound by in the
+// config file and the action it is bound to. Ctrl+C or Ctrl+D ends it.
+func (t *Terminal) DebugKeysCommand(args []string, out io.Writer) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: debug-keys")
+	}
+	if t.LineMode() {
+		return fmt.Errorf("debug-keys: %w", ErrNotATTY)
+	}
+	fmt.Fprintln(out, "Press keys to see how they are read, Ctrl+C or Ctrl+D to stop")
+	for {
+		key, data, err := t.readKeyBytes()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name, action := KeyName(key), string(t.KeyAction(key))
+		if key.Key == KeyPaste {
+			name = fmt.Sprintf("paste of %d bytes", len(key.Text))
+			data = nil
+		}
+		fmt.Fprintf(out, "%-14s %-16s %-16s %s\n", name, strconv.Quote(string(data)), fmt.Sprintf("% x", data), action)
+		if key == KeyCtrl('C') || key == KeyCtrl('D') {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package goterm
+
+// DefaultConfigFile is a config file setting everything to its default,
+// with each setting explained. It is printed by
+// `go-term --print-default-config` as a starting point for
+// ~/.config/go-term/config.
+const DefaultConfigFile = `# go-term config file, read at startup and by the reload-config builtin.
+# Settings left out keep their defaults, shown here.
+
+[prompt]
+# The prompt's format, in place of the default of the last directories and
+# "> ". The variables are {cwd}, {cwd_short}, {user}, {host}, {time},
+# {status}, {status_symbol}, {jobs} and {git_branch}, and colors such as
+# {green} or {bold} last until {reset}.
+# template = "{cwd_short} {green}{git_branch}{reset} {red}{status_symbol}{reset}> "
+# Show the default prompt in red after a command fails
+status_color = false
+# Show a failed command's exit status in the default prompt, as in ~/src [1]>
+status_code = false
+# Show how long a command took once it runs for this long, such as "5s".
+# 0 never shows it.
+duration_threshold = "0s"
+# Set the terminal window's title to the working directory at the prompt and
+# to the command while it runs
+title = false
+# Tell the terminal the working directory, so that new tabs and windows open
+# in it
+report_cwd = true
+# Redraw each line once entered with this in place of the prompt, such as
+# "❯ ", so that long prompts don't fill the scrollback. "" keeps the prompt.
+transient = ""
+
+[colors]
+# Draw nothing in color, as when NO_COLOR is set or with --no-color
+no_color = false
+# Colors are a color name, such as green or bright_green, a number from the
+# 256 color palette or #rrggbb, along with any of bold, dim, italic,
+# underline, blink and reverse. on_ before a color sets the background.
+# Colors the terminal can't show, as told by TERM and COLORTERM, are drawn
+# as the nearest it can.
+# The colors below are changes to a theme: default, light, for a light
+# background, or monochrome. The theme builtin previews them.
+theme = "default"
+# prompt = "blue"
+command = "green"
+unknown_command = "red"
+quoted = "yellow"
+path = "underline"
+suggestion = "green"
+prompt_error = "red"
+menu = "black on_yellow"
+menu_selected = "black on_green"
+menu_history = "black on_blue"
+# What commands write to standard error, if color_stderr is set in [shell]
+stderr = "red"
+
+[keybindings]
+# A key, or two keys pressed one after the other, and the action it runs.
+# An empty action removes a binding.
+# ctrl-p = "history-prev"
+# "ctrl-x u" = "undo"
+# Ctrl+X Ctrl+C copies the line to the clipboard and Ctrl+X Ctrl+V pastes
+# "ctrl-x ctrl-c" = "copy-line"
+# "ctrl-x ctrl-v" = "paste-clipboard"
+
+[history]
+# Glob patterns for commands that are never stored
+ignore = []
+# Keep commands typed with a leading space out of the history
+ignore_space = true
+# Remove earlier copies of a command from the history file when it is run
+erase_dups = false
+# Keep only the most recent copy of each command
+dedup = true
+# Merge commands run in other sessions into the history as they are added
+share = true
+# Make Escape leave history search with the line from before the search
+search_escape_restores = false
+
+[completion]
+# "prefix" or "fuzzy"
+match = "prefix"
+# Rows of the completion menu
+menu_rows = 6
+# Always offer hidden files, not only once a . is typed
+show_hidden = false
+# Tab accepts the selected completion instead of inserting the text the
+# candidates share
+tab_accepts_first = false
+# Show the whole inline suggestion in brackets at the right edge, when there
+# is room for it after the input
+suggestion_hint = true
+
+[shell]
+# The shell lines go-term can't run itself are run with, in place of $SHELL
+# path = "/bin/bash"
+# A glob matching nothing is an error, rather than being left as it is
+nomatch_error = false
+# Once a command's output fills the screen, keep the rest and page it when
+# the command exits, with $PAGER if set. Commands then write to a pipe rather
+# than the terminal, and full-screen programs such as vim are left alone.
+page_output = false
+# Draw what commands write to standard error in the stderr color of [colors].
+# Commands then write errors to a pipe rather than the terminal, which some
+# tools don't expect, and lines with colors of their own are left alone.
+color_stderr = false
+# Start in the directory the last session ended in, if it still exists,
+# rather than the one go-term is started from. --no-restore-cwd turns this
+# off for a session.
+restore_cwd = false
+# Once a command has written nothing for this long, show a spinner and how
+# long it has run for until it writes again or exits, such as 10s. Commands
+# then write to a pipe rather than the terminal. 0 turns this off.
+progress_after = 0
+
+[notify]
+# Once a command has run for this long, such as 30s, show a desktop
+# notification when it finishes, with its exit status. 0 turns this off.
+# It is shown even if the terminal has focus, as go-term can't tell whether
+# it was left while the command ran.
+after = 0
+# Notify with an escape sequence, which terminals such as iTerm2, kitty and
+# WezTerm show as a notification. Otherwise notify-send is run.
+escape = true
+# Commands never notified of, such as interactive programs
+exclude = ["btop", "emacs", "fzf", "htop", "less", "man", "mc", "more", "most", "nano", "nvim", "screen", "ssh", "tmux", "top", "vi", "view", "vim", "watch"]
+
+[aliases]
+# Aliases defined here aren't saved to the aliases file
+# ll = "ls -l"
+`
@@ -0,0 +1,283 @@
+package goterm
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// chdir makes dir the working directory, keeping PWD and OLDPWD up to date.
+// PWD keeps the path as given, through any symlinks, as in other shells.
+// Errors are prefixed with the name of the builtin.
+func (t *Terminal) chdir(builtin, dir string) error {
+	info, err := os.Stat(dir)
+	switch {
+	case os.IsNotExist(err):
+		return fmt.Errorf("%s: %s: no such file or directory", builtin, dir)
+	case err == nil && !info.IsDir():
+		return fmt.Errorf("%s: %s: not a directory", builtin, dir)
+	}
+
+	old, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		if pathErr, ok := err.(*os.PathError); ok {
+			err = pathErr.Err
+		}
+		return fmt.Errorf("%s: %s: %v", builtin, dir, err)
+	}
+	pwd := dir
+	if !filepath.IsAbs(pwd) {
+		pwd = filepath.Join(old, pwd)
+	}
+	if old != "" {
+		t.Setenv("OLDPWD", old)
+	}
+	t.Setenv("PWD", filepath.Clean(pwd))
+	t.reportWorkingDir()
+	t.saveLastDir(filepath.Clean(pwd))
+	t.stats.RecordDir(filepath.Clean(pwd))
+	return nil
+}
+
+// searchCDPath looks for a relative directory that isn't in the working
+// directory in each directory of CDPATH, returning the first one found
+func (t *Terminal) searchCDPath(dir string) (string, bool) {
+	if filepath.IsAbs(dir) || dir == "." || dir == ".." ||
+		strings.HasPrefix(dir, "./") || strings.HasPrefix(dir, "../") {
+		return "", false
+	}
+	if _, err := os.Stat(dir); err == nil {
+		return "", false
+	}
+	cdpath := t.Getenv("CDPATH")
+	if cdpath == "" {
+		return "", false
+	}
+	for _, base := range strings.Split(cdpath, ":") {
+		if base == "" {
+			continue
+		}
+		candidate := filepath.Join(base, dir)
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// ChangeDirectory runs the cd builtin. With no directory it goes home, `cd -`
+// goes back to the previous directory, and a relative directory that isn't
+// in the working directory is looked for in CDPATH. Where the directory
+// isn't the one typed, it is written to out.
+func (t *Terminal) ChangeDirectory(args []string, out io.Writer) error {
+	if len(args) > 1 {
+		return fmt.Errorf("cd: too many arguments")
+	}
+	var dir string
+	if len(args) == 1 {
+		dir = args[0]
+	}
+
+	show := false
+	switch dir {
+	case "":
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("could not get home directory: %v", err)
+		}
+		dir = homeDir
+	case "-":
+		dir = t.Getenv("OLDPWD")
+		if dir == "" {
+			return fmt.Errorf("cd: OLDPWD not set")
+		}
+		show = true
+	default:
+		if found, ok := t.searchCDPath(dir); ok {
+			dir = found
+			show = true
+		}
+	}
+
+	if err := t.chdir("cd", dir); err != nil {
+		return err
+	}
+	if show {
+		fmt.Fprintln(out, t.Getenv("PWD"))
+	}
+	return nil
+}
+
+// directoryStack returns the working directory followed by the directories
+// saved by pushd, most recent first
+func (t *Terminal) directoryStack() []string {
+	cwd, err := os.Getwd()
+	if err != nil {
+		cwd = "."
+	}
+	return append([]string{cwd}, t.dirStack...)
+}
+
+// stackIndex parses a +N or -N argument to pushd or popd, counting from the
+// top of the directory stack or from the bottom
+func stackIndex(builtin, arg string, size int) (int, bool, error) {
+	if len(arg) < 2 || arg[0] != '+' && arg[0] != '-' {
+		return 0, false, nil
+	}
+	n, err := strconv.Atoi(arg[1:])
+	if err != nil {
+		return 0, false, nil
+	}
+	if n < 0 || n >= size {
+		return 0, true, fmt.Errorf("%s: %s: directory stack index out of range", builtin, arg)
+	}
+	if arg[0] == '-' {
+		n = size - 1 - n
+	}
+	return n, true, nil
+}
+
+// PushdCommand runs `pushd dir`, saving the working directory on the
+// directory stack before going to dir. With no directory it swaps the top
+// two directories, and `pushd +N` rotates the stack to bring the Nth to the
+// top. The stack is then written to out.
+func (t *Terminal) PushdCommand(args []string, out io.Writer) error {
+	if len(args) > 1 {
+		return fmt.Errorf("pushd: too many arguments")
+	}
+	stack := t.directoryStack()
+
+	if len(args) == 0 {
+		if len(stack) < 2 {
+			return fmt.Errorf("pushd: no other directory")
+		}
+		if err := t.chdir("pushd", stack[1]); err != nil {
+			return err
+		}
+		t.dirStack[0] = stack[0]
+		return t.writeDirs(out, false, false)
+	}
+
+	n, ok, err := stackIndex("pushd", args[0], len(stack))
+	if err != nil {
+		return err
+	}
+	if ok {
+		rotated := append(stack[n:len(stack):len(stack)], stack[:n]...)
+		if err := t.chdir("pushd", rotated[0]); err != nil {
+			return err
+		}
+		t.dirStack = rotated[1:]
+		return t.writeDirs(out, false, false)
+	}
+
+	dir := args[0]
+	if found, ok := t.searchCDPath(dir); ok {
+		dir = found
+	}
+	if err := t.chdir("pushd", dir); err != nil {
+		return err
+	}
+	t.dirStack = stack
+	return t.writeDirs(out, false, false)
+}
+
+// PopdCommand runs `popd`, going back to the directory on top of the
+// directory stack and removing it, or `popd +N` to remove the Nth directory
+// without changing directory. The stack is then written to out.
+func (t *Terminal) PopdCommand(args []string, out io.Writer) error {
+	if len(args) > 1 {
+		return fmt.Errorf("popd: too many arguments")
+	}
+	if len(t.dirStack) == 0 {
+		return fmt.Errorf("popd: directory stack empty")
+	}
+
+	n := 0
+	if len(args) == 1 {
+		var ok bool
+		var err error
+		n, ok, err = stackIndex("popd", args[0], len(t.dirStack)+1)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("popd: %s: invalid argument", args[0])
+		}
+	}
+
+	if n == 0 {
+		if err := t.chdir("popd", t.dirStack[0]); err != nil {
+			return err
+		}
+		t.dirStack = t.dirStack[1:]
+	} else {
+		t.dirStack = append(t.dirStack[:n-1:n-1], t.dirStack[n:]...)
+	}
+	return t.writeDirs(out, false, false)
+}
+
+// DirsCommand runs the dirs builtin, writing the directory stack to out.
+// -c clears the stack, -l shows full paths rather than using ~ and -v puts
+// each directory on its own line, numbered.
+func (t *Terminal) DirsCommand(args []string, out io.Writer) error {
+	long, verbose := false, false
+	for _, arg := range args {
+		if len(arg) < 2 || arg[0] != '-' {
+			return fmt.Errorf("usage: dirs [-clv]")
+		}
+		for _, opt := range arg[1:] {
+			switch opt {
+			case 'c':
+				t.dirStack = nil
+				return nil
+			case 'l':
+				long = true
+			case 'v':
+				verbose = true
+			default:
+				return fmt.Errorf("dirs: -%c: invalid option", opt)
+			}
+		}
+	}
+	return t.writeDirs(out, long, verbose)
+}
+
+// writeDirs writes the directory stack to out, on one line unless verbose
+func (t *Terminal) writeDirs(out io.Writer, long, verbose bool) error {
+	stack := t.directoryStack()
+	for i, dir := range stack {
+		if !long {
+			dir = abbreviateHome(dir)
+		}
+		if verbose {
+			stack[i] = fmt.Sprintf("%2d  %s", i, dir)
+		} else {
+			stack[i] = dir
+		}
+	}
+	sep := " "
+	if verbose {
+		sep = "\n"
+	}
+	_, err := fmt.Fprintln(out, strings.Join(stack, sep))
+	return err
+}
+
+// abbreviateHome writes a path in the home directory starting with ~
+func abbreviateHome(path string) string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" || home == "/" {
+		return path
+	}
+	if path == home {
+		return "~"
+	}
+	if strings.HasPrefix(path, home+string(filepath.Separator)) {
+		return "~" + path[len(home):]
+	}
+	return path
+}
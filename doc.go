@@ -0,0 +1,47 @@
+// Package goterm is an interactive line editor and command runner for
+// terminal applications. It provides the pieces go-term, in cmd/go-term, is
+// built from: a Terminal that reads and decodes key presses, a LineEditor
+// holding the line being typed, syntax highlighting, history with search,
+// completion from pluggable Completers and a runner for command lines with
+// pipes, redirections, builtins and job control.
+//
+// A minimal REPL reads lines with ReadLine, which handles all of the
+// editing, and runs them:
+//
+//	term, err := goterm.NewTerminal()
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer term.Close()
+//
+//	for {
+//		line, err := term.ReadLine("> ")
+//		if err != nil {
+//			return // io.EOF after Ctrl+D
+//		}
+//		term.AddToHistory(line)
+//		var exitErr *goterm.ExitError
+//		if err := term.Run(line); errors.As(err, &exitErr) {
+//			term.Close()
+//			os.Exit(exitErr.Code)
+//		} else if err != nil {
+//			term.WriteLine(err.Error())
+//		}
+//	}
+//
+// Errors returned wrap ErrNotATTY, ErrHistoryIO or ErrCommandNotFound
+// where those apply, to be told apart with errors.Is.
+//
+// Programs wanting their own key handling can read key actions with
+// ReadInputAction instead and keep the line in a LineEditor, drawing it
+// with RedrawLine.
+//
+// A Terminal is used from one goroutine, the one reading keys and running
+// commands, apart from the methods meant for signal handlers and
+// background work: Interrupt, Resize, ForwardSignal, WriteLine, History
+// and Close are safe to call from any goroutine. Close ends a wait for a
+// key with io.EOF.
+//
+// NewTerminalWithIO runs a Terminal over other input and output, such as a
+// network connection, and FakeTerm scripts one for tests.
+package goterm
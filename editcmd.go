@@ -0,0 +1,67 @@
+package goterm
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// defaultEditor is used when $EDITOR is not set
+const defaultEditor = "vi"
+
+// EditInEditor opens text in the user's $EDITOR and returns the edited text.
+// The terminal is suspended while the editor runs. ok is false when the
+// editor exits with an error, in which case the text should be left as is.
+func (t *Terminal) EditInEditor(text string) (edited string, ok bool, err error) {
+	file, err := os.CreateTemp("", "go-term-*.sh")
+	if err != nil {
+		return "", false, fmt.Errorf("could not create temp file: %v", err)
+	}
+	path := file.Name()
+	defer os.Remove(path)
+
+	_, err = file.WriteString(text + "\n")
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("could not write temp file: %v", err)
+	}
+
+	// $EDITOR may include arguments, such as "code --wait"
+	editor := strings.Fields(os.Getenv("EDITOR"))
+	if len(editor) == 0 {
+		editor = []string{defaultEditor}
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", false, fmt.Errorf("%w: %w", ErrNotATTY, err)
+	}
+	defer tty.Close()
+
+	cmd := exec.Command(editor[0], append(editor[1:], path)...)
+	cmd.Stdin = tty
+	cmd.Stdout = tty
+	cmd.Stderr = tty
+
+	if err := t.Suspend(); err != nil {
+		return "", false, err
+	}
+	runErr := cmd.Run()
+	if err := t.Resume(); err != nil {
+		return "", false, err
+	}
+	if runErr != nil {
+		// A failed or aborted edit leaves the command unchanged
+		return "", false, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("could not read temp file: %v", err)
+	}
+	// Editors add a final newline that isn't part of the command
+	return strings.TrimRight(string(data), "\r\n"), true, nil
+}
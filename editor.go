@@ -0,0 +1,307 @@
+package goterm
+
+import (
+	"strings"
+	"unicode"
+)
+
+// maxUndo is the number of edits that can be undone
+const maxUndo = 100
+
+// editState is a snapshot of the line and cursor used for undo and redo
+type editState struct {
+	buf []rune
+	pos int
+}
+
+// LineEditor holds the text of the line being edited and the cursor position within it
+type LineEditor struct {
+	buf  []rune
+	pos  int
+	undo []editState
+	redo []editState
+}
+
+// NewLineEditor creates an empty line editor
+func NewLineEditor() *LineEditor {
+	return &LineEditor{}
+}
+
+// String returns the current contents of the line
+func (e *LineEditor) String() string {
+	return string(e.buf)
+}
+
+// Len returns the number of characters in the line
+func (e *LineEditor) Len() int {
+	return len(e.buf)
+}
+
+// Cursor returns the cursor position as a character index
+func (e *LineEditor) Cursor() int {
+	return e.pos
+}
+
+// Width returns the display width of the characters between indices from and to
+func (e *LineEditor) Width(from, to int) int {
+	if from > to {
+		from, to = to, from
+	}
+	return stringWidth(string(e.buf[from:to]))
+}
+
+// AtEnd reports whether the cursor is at the end of the line
+func (e *LineEditor) AtEnd() bool {
+	return e.pos == len(e.buf)
+}
+
+// Reset clears the line, moves the cursor to the start and forgets the undo history
+func (e *LineEditor) Reset() {
+	e.buf = e.buf[:0]
+	e.pos = 0
+	e.undo = nil
+	e.redo = nil
+}
+
+// Set replaces the line contents and moves the cursor to the end
+func (e *LineEditor) Set(s string) {
+	if s == string(e.buf) && e.pos == len(e.buf) {
+		return
+	}
+	e.saveUndo()
+	e.buf = []rune(s)
+	e.pos = len(e.buf)
+}
+
+// Insert inserts a character at the cursor and advances the cursor
+func (e *LineEditor) Insert(r rune) {
+	e.saveUndo()
+	e.buf = append(e.buf, 0)
+	copy(e.buf[e.pos+1:], e.buf[e.pos:])
+	e.buf[e.pos] = r
+	e.pos++
+}
+
+// InsertString inserts text at the cursor and moves the cursor past it
+func (e *LineEditor) InsertString(s string) {
+	if s == "" {
+		return
+	}
+	e.saveUndo()
+	text := []rune(s)
+	e.buf = append(e.buf[:e.pos], append(text, e.buf[e.pos:]...)...)
+	e.pos += len(text)
+}
+
+// ReplaceBeforeCursor replaces the n characters before the cursor with s,
+// leaving the cursor after the inserted text
+func (e *LineEditor) ReplaceBeforeCursor(n int, s string) {
+	if n > e.pos {
+		n = e.pos
+	}
+	e.saveUndo()
+	text := []rune(s)
+	e.buf = append(e.buf[:e.pos-n], append(text, e.buf[e.pos:]...)...)
+	e.pos += len(text) - n
+}
+
+// Backspace removes the character before the cursor
+func (e *LineEditor) Backspace() bool {
+	if e.pos == 0 {
+		return false
+	}
+	e.saveUndo()
+	e.buf = append(e.buf[:e.pos-1], e.buf[e.pos:]...)
+	e.pos--
+	return true
+}
+
+// Delete removes the character under the cursor
+func (e *LineEditor) Delete() bool {
+	if e.pos == len(e.buf) {
+		return false
+	}
+	e.saveUndo()
+	e.buf = append(e.buf[:e.pos], e.buf[e.pos+1:]...)
+	return true
+}
+
+// MoveLeft moves the cursor one character to the left
+func (e *LineEditor) MoveLeft() bool {
+	if e.pos == 0 {
+		return false
+	}
+	e.pos--
+	return true
+}
+
+// MoveRight moves the cursor one character to the right
+func (e *LineEditor) MoveRight() bool {
+	if e.pos == len(e.buf) {
+		return false
+	}
+	e.pos++
+	return true
+}
+
+// MoveCursorToStart moves the cursor to the beginning of the line
+func (e *LineEditor) MoveCursorToStart() {
+	e.pos = 0
+}
+
+// MoveCursorToEnd moves the cursor to the end of the line
+func (e *LineEditor) MoveCursorToEnd() {
+	e.pos = len(e.buf)
+}
+
+// isWordSeparator reports whether r separates words for word-wise movement,
+// so that whitespace and punctuation such as '/' both act as boundaries
+func isWordSeparator(r rune) bool {
+	return unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
+
+// MoveWordLeft moves the cursor to the start of the current or previous word
+func (e *LineEditor) MoveWordLeft() bool {
+	start := e.pos
+	// Skip separators directly before the cursor, then the word itself
+	for e.pos > 0 && isWordSeparator(e.buf[e.pos-1]) {
+		e.pos--
+	}
+	for e.pos > 0 && !isWordSeparator(e.buf[e.pos-1]) {
+		e.pos--
+	}
+	return e.pos != start
+}
+
+// MoveWordRight moves the cursor to the end of the current or next word
+func (e *LineEditor) MoveWordRight() bool {
+	start := e.pos
+	// Skip separators under the cursor, then the word itself
+	for e.pos < len(e.buf) && isWordSeparator(e.buf[e.pos]) {
+		e.pos++
+	}
+	for e.pos < len(e.buf) && !isWordSeparator(e.buf[e.pos]) {
+		e.pos++
+	}
+	return e.pos != start
+}
+
+// NextWord returns the start of s up to the end of its first word, using the
+// same word boundaries as MoveWordRight
+func NextWord(s string) string {
+	runes := []rune(s)
+	i := 0
+	for i < len(runes) && isWordSeparator(runes[i]) {
+		i++
+	}
+	for i < len(runes) && !isWordSeparator(runes[i]) {
+		i++
+	}
+	return string(runes[:i])
+}
+
+// snapshot returns a copy of the current line and cursor
+func (e *LineEditor) snapshot() editState {
+	return editState{buf: append([]rune(nil), e.buf...), pos: e.pos}
+}
+
+// saveUndo records the current state before a change so it can be undone.
+// Making a new change discards anything that could have been redone.
+func (e *LineEditor) saveUndo() {
+	e.undo = append(e.undo, e.snapshot())
+	if len(e.undo) > maxUndo {
+		e.undo = e.undo[len(e.undo)-maxUndo:]
+	}
+	e.redo = nil
+}
+
+// Undo reverts the most recent change to the line
+func (e *LineEditor) Undo() bool {
+	if len(e.undo) == 0 {
+		return false
+	}
+	e.redo = append(e.redo, e.snapshot())
+	state := e.undo[len(e.undo)-1]
+	e.undo = e.undo[:len(e.undo)-1]
+	e.buf, e.pos = state.buf, state.pos
+	return true
+}
+
+// Redo reapplies the most recently undone change
+func (e *LineEditor) Redo() bool {
+	if len(e.redo) == 0 {
+		return false
+	}
+	e.undo = append(e.undo, e.snapshot())
+	state := e.redo[len(e.redo)-1]
+	e.redo = e.redo[:len(e.redo)-1]
+	e.buf, e.pos = state.buf, state.pos
+	return true
+}
+
+// kill removes the characters between indices from and to, leaves the cursor
+// at from and returns the removed text
+func (e *LineEditor) kill(from, to int) string {
+	if from >= to {
+		return ""
+	}
+	e.saveUndo()
+	killed := string(e.buf[from:to])
+	e.buf = append(e.buf[:from], e.buf[to:]...)
+	e.pos = from
+	return killed
+}
+
+// KillToEnd removes and returns the text from the cursor to the end of the line
+func (e *LineEditor) KillToEnd() string {
+	return e.kill(e.pos, len(e.buf))
+}
+
+// KillToStart removes and returns the text from the start of the line to the cursor
+func (e *LineEditor) KillToStart() string {
+	return e.kill(0, e.pos)
+}
+
+// KillWordBackward removes and returns the whitespace delimited word before
+// the cursor, like Ctrl+W in a Unix terminal
+func (e *LineEditor) KillWordBackward() string {
+	start := e.pos
+	for start > 0 && unicode.IsSpace(e.buf[start-1]) {
+		start--
+	}
+	for start > 0 && !unicode.IsSpace(e.buf[start-1]) {
+		start--
+	}
+	return e.kill(start, e.pos)
+}
+
+// KillWordForward removes and returns the text from the cursor to the end of
+// the current or next word
+func (e *LineEditor) KillWordForward() string {
+	end := e.pos
+	for end < len(e.buf) && isWordSeparator(e.buf[end]) {
+		end++
+	}
+	for end < len(e.buf) && !isWordSeparator(e.buf[end]) {
+		end++
+	}
+	return e.kill(e.pos, end)
+}
+
+// SanitizeInput prepares text that did not come from individual key presses,
+// such as pasted text, for insertion: tabs become spaces and other control
+// characters are dropped
+func SanitizeInput(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\t':
+			b.WriteRune(' ')
+		case unicode.IsControl(r):
+			continue
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
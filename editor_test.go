@@ -0,0 +1,139 @@
+package goterm
+
+import "testing"
+
+// editorAt returns an editor holding s with the cursor before its pos'th
+// character
+func editorAt(s string, pos int) *LineEditor {
+	e := NewLineEditor()
+	e.Set(s)
+	e.pos = pos
+	return e
+}
+
+func TestMoveWordLeft(t *testing.T) {
+	tests := []struct {
+		line  string
+		pos   int
+		want  int
+		moved bool
+	}{
+		{line: "", pos: 0, want: 0},
+		{line: "git status", pos: 0, want: 0},
+		{line: "git status", pos: 10, want: 4, moved: true},
+		{line: "git status", pos: 6, want: 4, moved: true},
+		{line: "git status", pos: 4, want: 0, moved: true},
+		{line: "git   status", pos: 6, want: 0, moved: true},
+		{line: "cd /usr/local/bin", pos: 17, want: 14, moved: true},
+		{line: "cd /usr/local/bin", pos: 14, want: 8, moved: true},
+		{line: "cd //usr", pos: 5, want: 0, moved: true},
+		{line: "  ls", pos: 2, want: 0, moved: true},
+		{line: "echo héllo", pos: 10, want: 5, moved: true},
+	}
+	for _, tt := range tests {
+		e := editorAt(tt.line, tt.pos)
+		moved := e.MoveWordLeft()
+		if e.Cursor() != tt.want || moved != tt.moved {
+			t.Errorf("MoveWordLeft in %q from %d = %d, %v, want %d, %v", tt.line, tt.pos, e.Cursor(), moved, tt.want, tt.moved)
+		}
+	}
+}
+
+func TestMoveWordRight(t *testing.T) {
+	tests := []struct {
+		line  string
+		pos   int
+		want  int
+		moved bool
+	}{
+		{line: "", pos: 0, want: 0},
+		{line: "git status", pos: 10, want: 10},
+		{line: "git status", pos: 0, want: 3, moved: true},
+		{line: "git status", pos: 3, want: 10, moved: true},
+		{line: "git   status", pos: 3, want: 12, moved: true},
+		{line: "cd /usr/local/bin", pos: 2, want: 7, moved: true},
+		{line: "cd /usr/local/bin", pos: 7, want: 13, moved: true},
+		{line: "cd //usr", pos: 2, want: 8, moved: true},
+		{line: "ls  ", pos: 2, want: 4, moved: true},
+		{line: "héllo wörld", pos: 0, want: 5, moved: true},
+	}
+	for _, tt := range tests {
+		e := editorAt(tt.line, tt.pos)
+		moved := e.MoveWordRight()
+		if e.Cursor() != tt.want || moved != tt.moved {
+			t.Errorf("MoveWordRight in %q from %d = %d, %v, want %d, %v", tt.line, tt.pos, e.Cursor(), moved, tt.want, tt.moved)
+		}
+	}
+}
+
+func TestNextWord(t *testing.T) {
+	tests := []struct{ s, want string }{
+		{"", ""},
+		{"status --short", "status"},
+		{" --short", " --short"},
+		{"/local/bin", "/local"},
+	}
+	for _, tt := range tests {
+		if got := NextWord(tt.s); got != tt.want {
+			t.Errorf("NextWord(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestUndo(t *testing.T) {
+	e := NewLineEditor()
+	for _, r := range "ls -l" {
+		e.Insert(r)
+	}
+	e.Backspace()
+	e.MoveCursorToStart()
+	e.KillToEnd()
+	e.InsertString("git status")
+	e.ReplaceBeforeCursor(6, "stash")
+
+	want := []string{"git status", "", "ls -", "ls -l", "ls -", "ls ", "ls", "l", ""}
+	for _, w := range want {
+		if !e.Undo() {
+			t.Fatalf("Undo failed with %q left to undo to", w)
+		}
+		if e.String() != w {
+			t.Fatalf("Undo gave %q, want %q", e.String(), w)
+		}
+	}
+	if e.Undo() {
+		t.Errorf("Undo past the empty line gave %q", e.String())
+	}
+	if e.String() != "" || e.Cursor() != 0 {
+		t.Errorf("line after undoing everything = %q at %d, want empty", e.String(), e.Cursor())
+	}
+
+	// Everything undone can be redone, up to where it was
+	for e.Redo() {
+	}
+	if e.String() != "git stash" {
+		t.Errorf("line after redoing everything = %q, want %q", e.String(), "git stash")
+	}
+	// A new change discards what could be redone
+	e.Undo()
+	e.Insert('x')
+	if e.Redo() {
+		t.Errorf("Redo after a new change gave %q", e.String())
+	}
+}
+
+func TestUndoLimit(t *testing.T) {
+	e := NewLineEditor()
+	for i := 0; i < maxUndo+10; i++ {
+		e.Insert('a')
+	}
+	n := 0
+	for e.Undo() {
+		n++
+	}
+	if n != maxUndo {
+		t.Errorf("undid %d changes, want %d", n, maxUndo)
+	}
+	if e.Len() != 10 {
+		t.Errorf("line after undoing as far as possible has %d characters, want 10", e.Len())
+	}
+}
@@ -0,0 +1,229 @@
+package goterm
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// variableName matches a valid environment variable name
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Getenv returns the value of an environment variable, or "" if it isn't
+// set
+func (t *Terminal) Getenv(name string) string {
+	t.envMu.RLock()
+	defer t.envMu.RUnlock()
+	return t.env[name]
+}
+
+// Setenv sets an environment variable for the commands run from now on. The
+// process environment is kept in step, so that completion and the
+// highlighter see the same PATH as the commands.
+func (t *Terminal) Setenv(name, value string) error {
+	if !variableName.MatchString(name) {
+		return fmt.Errorf("%q: not a valid variable name", name)
+	}
+	t.envMu.Lock()
+	defer t.envMu.Unlock()
+	t.env[name] = value
+	return os.Setenv(name, value)
+}
+
+// Unsetenv removes an environment variable
+func (t *Terminal) Unsetenv(name string) error {
+	t.envMu.Lock()
+	defer t.envMu.Unlock()
+	delete(t.env, name)
+	return os.Unsetenv(name)
+}
+
+// Environ returns the environment commands are run with, as NAME=value
+// strings sorted by name
+func (t *Terminal) Environ() []string {
+	t.envMu.RLock()
+	defer t.envMu.RUnlock()
+	env := make([]string, 0, len(t.env))
+	for name, value := range t.env {
+		env = append(env, name+"="+value)
+	}
+	sort.Strings(env)
+	return env
+}
+
+// environMap returns the environment of environ, as returned by os.Environ,
+// as a map
+func environMap(environ []string) map[string]string {
+	env := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		if name, value, ok := strings.Cut(kv, "="); ok {
+			env[name] = value
+		}
+	}
+	return env
+}
+
+// variableAt parses a $NAME or ${NAME} reference, or $? for the exit
+// status, starting at runes[i], returning the name and the offset just past
+// the reference
+func variableAt(runes []rune, i int) (name string, end int, ok bool) {
+	j := i + 1
+	braced := j < len(runes) && runes[j] == '{'
+	if braced {
+		j++
+	}
+	start := j
+	if j < len(runes) && runes[j] == '?' {
+		j++
+	} else {
+		for ; j < len(runes); j++ {
+			r := runes[j]
+			if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || j > start && r >= '0' && r <= '9') {
+				break
+			}
+		}
+	}
+	if j == start {
+		return "", 0, false
+	}
+	name = string(runes[start:j])
+	if braced {
+		if j >= len(runes) || runes[j] != '}' {
+			return "", 0, false
+		}
+		j++
+	}
+	return name, j, true
+}
+
+// variable returns the value a $NAME reference expands to. $? and $status
+// give the exit status of the last command, and anything else comes from
+// the environment.
+func (t *Terminal) variable(name string) string {
+	if name == "?" || name == "status" {
+		return strconv.Itoa(t.lastStatus)
+	}
+	return t.Getenv(name)
+}
+
+// doubleQuoteEscaper escapes the characters that are special inside double
+// quotes
+var doubleQuoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "`", "\\`")
+
+// expandVariables replaces the $NAME and ${NAME} references in a word, as
+// typed, with the values getenv gives them, except in single quotes or
+// after a backslash. The values are quoted, so that they aren't split or
+// taken as globs, and an empty value outside double quotes leaves nothing
+// behind.
+func expandVariables(raw string, getenv func(string) string) string {
+	if !strings.Contains(raw, "$") {
+		return raw
+	}
+	runes := []rune(raw)
+	var b strings.Builder
+	var quote rune
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			}
+			b.WriteRune(r)
+		case r == '\\' && i+1 < len(runes):
+			b.WriteString(string(runes[i : i+2]))
+			i++
+		case r == '\'' && quote == 0, r == '"' && quote == 0:
+			quote = r
+			b.WriteRune(r)
+		case r == '"':
+			quote = 0
+			b.WriteRune(r)
+		case r == '$':
+			name, end, ok := variableAt(runes, i)
+			if !ok {
+				b.WriteRune(r)
+				continue
+			}
+			value := getenv(name)
+			if quote == '"' {
+				b.WriteString(doubleQuoteEscaper.Replace(value))
+			} else if value != "" {
+				b.WriteString("'" + strings.ReplaceAll(value, "'", `'\''`) + "'")
+			}
+			i = end - 1
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// writeEnviron lists the environment to out
+func (t *Terminal) writeEnviron(out io.Writer) error {
+	for _, kv := range t.Environ() {
+		if _, err := fmt.Fprintln(out, kv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportCommand runs `export NAME=value...`, setting environment variables,
+// or lists the environment to out when given no arguments. Naming a
+// variable without a value leaves it as it is, as all variables are
+// exported.
+func (t *Terminal) ExportCommand(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return t.writeEnviron(out)
+	}
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			if !variableName.MatchString(name) {
+				return fmt.Errorf("export: %q: not a valid variable name", name)
+			}
+			continue
+		}
+		if err := t.Setenv(name, value); err != nil {
+			return fmt.Errorf("export: %v", err)
+		}
+	}
+	return nil
+}
+
+// SetCommand runs `set NAME value...`, setting a variable to its values
+// joined with spaces, or lists the environment to out when given no
+// arguments
+func (t *Terminal) SetCommand(args []string, out io.Writer) error {
+	switch {
+	case len(args) == 0:
+		return t.writeEnviron(out)
+	case strings.HasPrefix(args[0], "-"):
+		return fmt.Errorf("set: options are not supported")
+	}
+	if err := t.Setenv(args[0], strings.Join(args[1:], " ")); err != nil {
+		return fmt.Errorf("set: %v", err)
+	}
+	return nil
+}
+
+// UnsetCommand runs `unset NAME...`, removing environment variables
+func (t *Terminal) UnsetCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: unset NAME...")
+	}
+	for _, name := range args {
+		if !variableName.MatchString(name) {
+			return fmt.Errorf("unset: %q: not a valid variable name", name)
+		}
+		if err := t.Unsetenv(name); err != nil {
+			return fmt.Errorf("unset: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package goterm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors that those returned by go-term wrap, to be told apart with
+// errors.Is
+var (
+	// ErrNotATTY is returned when a terminal is needed and there isn't one
+	ErrNotATTY = errors.New("not a terminal")
+	// ErrHistoryIO is returned when the history file can't be read or
+	// written, wrapping the reason
+	ErrHistoryIO = errors.New("history file")
+	// ErrCommandNotFound is returned for a command that is neither a
+	// builtin nor on PATH, wrapping the *exec.Error saying so
+	ErrCommandNotFound = errors.New("command not found")
+)
+
+// ExitError is returned by Run once the exit builtin has run, with the
+// status the program should exit with
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit %d", e.Code)
+}
+
+// historyError wraps err, from reading or writing the history file, in
+// ErrHistoryIO
+func historyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", ErrHistoryIO, err)
+}
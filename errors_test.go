@@ -0,0 +1,62 @@
+package goterm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestHistoryError(t *testing.T) {
+	if err := historyError(nil); err != nil {
+		t.Errorf("historyError(nil) = %v, want nil", err)
+	}
+
+	// The history file is in a directory that doesn't exist
+	term := newTerminal(nil, io.Discard)
+	term.historyFile = filepath.Join(t.TempDir(), "missing", "history")
+	err := term.AddToHistory("ls")
+	if !errors.Is(err, ErrHistoryIO) {
+		t.Errorf("AddToHistory error %v doesn't wrap ErrHistoryIO", err)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("AddToHistory error %v doesn't wrap its cause", err)
+	}
+}
+
+func TestCommandNotFoundError(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	term := newTerminal(nil, io.Discard)
+	status, err := term.runJob([]stage{{args: []string{"no-such-command"}}}, "no-such-command", false)
+	if status != 127 {
+		t.Errorf("status = %d, want 127", status)
+	}
+	if !errors.Is(err, ErrCommandNotFound) {
+		t.Errorf("error %v doesn't wrap ErrCommandNotFound", err)
+	}
+	if !errors.Is(err, exec.ErrNotFound) {
+		t.Errorf("error %v doesn't wrap exec.ErrNotFound", err)
+	}
+	var execErr *exec.Error
+	if !errors.As(err, &execErr) || execErr.Name != "no-such-command" {
+		t.Errorf("error %v doesn't wrap the *exec.Error for no-such-command", err)
+	}
+}
+
+func TestExitError(t *testing.T) {
+	f := newFake(t)
+	err := f.Run("exit 3")
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 3 {
+		t.Fatalf("Run(%q) = %v, want an *ExitError with code 3", "exit 3", err)
+	}
+
+	// Still found once wrapped by the caller
+	wrapped := fmt.Errorf("session: %w", err)
+	if !errors.As(wrapped, &exitErr) || exitErr.Code != 3 {
+		t.Errorf("%v doesn't unwrap to an *ExitError with code 3", wrapped)
+	}
+}
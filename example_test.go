@@ -0,0 +1,62 @@
+package goterm_test
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	goterm "github.com/pk/go-term"
+)
+
+func ExampleTerminal_ReadLine() {
+	term, err := goterm.NewFakeTerm()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer term.Close()
+
+	// Type a command, go back to the start of the line with Ctrl+A to add
+	// to it, then press Enter
+	go term.Type("ls -l\x01sudo \r")
+
+	line, err := term.ReadLine("$ ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(line)
+	// Output: sudo ls -l
+}
+
+func ExampleNewTerminalWithIO() {
+	dir, err := os.MkdirTemp("", "example")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Read lines typed into a pipe rather than a terminal, keeping the
+	// history apart from the user's
+	in := strings.NewReader("echo one\recho two\r")
+	term, err := goterm.NewTerminalWithIO(in, io.Discard, goterm.WithHistoryFile(filepath.Join(dir, "history")))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer term.Close()
+
+	for {
+		line, err := term.ReadLine("> ")
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(line)
+	}
+	// Output:
+	// echo one
+	// echo two
+}
@@ -0,0 +1,514 @@
+package goterm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Run runs a command line. Commands can be joined into pipelines with |,
+// run depending on whether the one before succeeded with && and ||, and
+// separated by ; or line breaks. Aliases are expanded first. Builtins run
+// in go-term itself, so cd
+// affects the commands after it, and other commands are run directly. A
+// line using syntax go-term doesn't handle itself, such as a loop, is run by
+// the shell as a whole.
+//
+// Errors from individual commands are written to the terminal as they
+// happen, since the commands after them still run. Run only returns an error
+// if the line couldn't be run at all, or an *ExitError once the exit builtin
+// has run.
+//
+// The functions given to OnPreExec and OnPostExec are called around each
+// line, though not around those of a file being sourced.
+func (t *Terminal) Run(line string) error {
+	if t.sourcing > 0 {
+		return t.run(line)
+	}
+	t.showCommandTitle(line)
+	for _, hook := range t.preExec {
+		hook(line)
+	}
+	start := time.Now()
+	t.outputMidLine.Store(false)
+	defer func() {
+		t.afterRun(line, time.Since(start))
+		t.showPromptTitle()
+	}()
+	return t.run(line)
+}
+
+// run runs a command line for Run
+func (t *Terminal) run(line string) error {
+	t.writer.Flush()
+	line = expandAliases(line, t.Alias, nil)
+	lists, err := parseCommandLine(line)
+	if errors.Is(err, errUnsupportedSyntax) {
+		t.report(t.runShell(line))
+		return nil
+	}
+	if err != nil {
+		t.lastStatus = 2
+		return err
+	}
+
+	for _, list := range lists {
+		if list.background && len(list.pipelines) > 1 {
+			// Only a single pipeline can be run as one job
+			t.report(t.runShell(list.text + " &"))
+			continue
+		}
+		for i, p := range list.pipelines {
+			if i > 0 && (list.ops[i-1] == tokenAnd) != (t.lastStatus == 0) {
+				continue
+			}
+			t.report(t.runPipeline(p, list.background))
+			if t.exitRequested {
+				return &ExitError{Code: t.exitStatus}
+			}
+		}
+	}
+	return nil
+}
+
+// report records status as the exit status of the last command and writes
+// err, if any, to the terminal
+func (t *Terminal) report(status int, err error) {
+	t.lastStatus = status
+	if name, ok := notFoundCommand(err); ok {
+		t.commandNotFound(name)
+	} else if err != nil {
+		t.writeError(err)
+	}
+}
+
+// writeError writes an error to the terminal, saying where it came from
+// when a file is being sourced
+func (t *Terminal) writeError(err error) {
+	if t.sourceLine != "" {
+		t.WriteLine(fmt.Sprintf("Error: %s: %v", t.sourceLine, err))
+		return
+	}
+	t.WriteLine(fmt.Sprintf("Error: %v", err))
+}
+
+// runShell runs line with the shell, in the background if it ends with &
+func (t *Terminal) runShell(line string) (int, error) {
+	shell, err := t.Shell()
+	if err != nil {
+		return 127, err
+	}
+	line, background := backgroundCommand(line)
+	return t.runJob([]stage{{args: shellArgs(shell, line)}}, line, background)
+}
+
+// expandWords turns the words of a command, as typed, into its arguments.
+// Variables, including $?, are expanded, quotes and escapes are removed, an unquoted ~ at
+// the start of a word is expanded to a home directory, and a word with
+// unquoted glob characters is replaced by the paths it matches. One matching
+// nothing is kept as typed, or is an error if SetNoMatchError is on.
+func (t *Terminal) expandWords(words []string) ([]string, error) {
+	var args []string
+	for _, raw := range words {
+		raw = expandVariables(raw, t.variable)
+		if raw == "" {
+			// Only unset or empty variables
+			continue
+		}
+		fields, err := tokenize(raw)
+		if err != nil {
+			return nil, err
+		}
+		// Words from the parser never split further
+		arg := strings.Join(fields, " ")
+		pattern, hasMeta := globPattern(raw)
+		if strings.HasPrefix(raw, "~") {
+			arg = expandTilde(arg)
+			pattern = expandTilde(pattern)
+		}
+		if hasMeta {
+			matches, valid := expandGlob(pattern)
+			if len(matches) > 0 {
+				args = append(args, matches...)
+				continue
+			}
+			if valid && t.noMatchError {
+				return nil, fmt.Errorf("no matches found: %s", arg)
+			}
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// stage is a command of a pipeline, expanded and ready to run
+type stage struct {
+	args      []string
+	redirects []redirect // With their targets expanded
+}
+
+// runPipeline runs the commands of a pipeline, returning the exit status of
+// the last
+func (t *Terminal) runPipeline(p *pipeline, background bool) (int, error) {
+	stages := make([]stage, len(p.commands))
+	for i, cmd := range p.commands {
+		args, err := t.expandWords(cmd.words)
+		if err != nil {
+			return 1, err
+		}
+		if len(args) == 0 {
+			return 0, nil
+		}
+		stages[i].args = args
+		for _, r := range cmd.redirects {
+			target, err := t.expandWords([]string{r.target})
+			if err != nil {
+				return 1, err
+			}
+			if len(target) != 1 {
+				return 1, fmt.Errorf("%s: ambiguous redirect", r.target)
+			}
+			r.target = target[0]
+			stages[i].redirects = append(stages[i].redirects, r)
+		}
+	}
+	return t.runJob(stages, p.text, background)
+}
+
+// builtinStatus returns the exit status of a builtin that returned err
+func builtinStatus(err error) (int, error) {
+	if err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
+
+// stdio holds a command's standard input, output and error, each an
+// io.Reader or io.Writer, or nil for the null device
+type stdio [3]any
+
+// redirect applies redirections to std in order, so that in `> log 2>&1`
+// both outputs go to the log. Files opened are added to files, for the
+// caller to close.
+func (std *stdio) redirect(redirects []redirect, files *[]*os.File) error {
+	for _, r := range redirects {
+		if r.fd > 2 {
+			return fmt.Errorf("%d: only descriptors 0, 1 and 2 can be redirected", r.fd)
+		}
+		if strings.HasSuffix(r.op, "&") {
+			from, err := strconv.Atoi(r.target)
+			if err != nil || from > 2 {
+				return fmt.Errorf("%s: bad file descriptor", r.target)
+			}
+			std[r.fd] = std[from]
+			continue
+		}
+
+		var f *os.File
+		var err error
+		switch r.op {
+		case "<":
+			f, err = os.Open(r.target)
+		case ">":
+			f, err = os.OpenFile(r.target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+		case ">>":
+			f, err = os.OpenFile(r.target, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+		default:
+			err = fmt.Errorf("unknown redirection %s", r.op)
+		}
+		if err != nil {
+			var pathErr *os.PathError
+			if errors.As(err, &pathErr) {
+				return fmt.Errorf("%s: %v", r.target, pathErr.Err)
+			}
+			return err
+		}
+		*files = append(*files, f)
+		std[r.fd] = f
+	}
+	return nil
+}
+
+// runJob runs a pipeline as a job described by command. Builtins in the
+// pipeline run first, in go-term, and what they write is passed on to the
+// next command. As they don't read their input, what the command before one
+// writes is discarded. If a redirection can't be opened, nothing is run. A
+// foreground job is given the terminal, in its normal mode, so that
+// interactive programs such as vim, less and top work and see the window
+// being resized. Raw mode is only restored once the job exits or is
+// stopped.
+func (t *Terminal) runJob(stages []stage, command string, background bool) (int, error) {
+	last := len(stages) - 1
+	cmds := make([]*exec.Cmd, len(stages))
+	for i, s := range stages {
+		if !t.isBuiltin(s.args[0]) {
+			cmds[i] = exec.Command(s.args[0], s.args[1:]...)
+			if _, ok := notFoundCommand(cmds[i].Err); ok {
+				return 127, fmt.Errorf("%w: %w", ErrCommandNotFound, cmds[i].Err)
+			}
+			if cmds[i].Err != nil {
+				return 127, cmds[i].Err
+			}
+			cmds[i].Env = t.Environ()
+		}
+	}
+
+	// Commands are waited for directly rather than through exec, so output
+	// going somewhere other than a file is copied through pipes here. The
+	// copying is waited for once the commands are done, unless they carry
+	// on in the background.
+	var copies sync.WaitGroup
+	detached := background
+	defer func() {
+		if !detached {
+			copies.Wait()
+		}
+	}()
+
+	// Files the commands are given, which they have their own copies of once
+	// started
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	// Background jobs share the terminal with go-term's raw mode, so their
+	// output needs line endings fixed
+	stdout, stderr := t.cmdOut, t.cmdErr
+	if background {
+		stdout = t.newLineWriter(t.cmdOut)
+		stderr = stdout
+	}
+	sameOutput := stderr == stdout
+	// Output that doesn't fit on the screen is kept to be paged once the
+	// job is done
+	var pager *outputPager
+	if !background && t.pageable(stages, command) {
+		pager = t.newOutputPager()
+		stdout = pager
+	}
+	// Standard error is colored on its way to the terminal, with line
+	// endings fixed as it may still be copied once raw mode is back
+	if style := t.stderrStyle(); style != "" && !sameOutput {
+		stderr = &stderrWriter{w: t.newLineWriter(stderr), style: style}
+	}
+	// Output is watched for the job going silent, to show its progress
+	var progress *progressLine
+	if !background && t.showsProgress(stages, command) {
+		progress = t.newProgressLine()
+		stdout = t.watchProgress(progress, stdout)
+		if !sameOutput {
+			stderr = t.watchProgress(progress, stderr)
+		}
+	}
+	stdout, err := outputPipe(stdout, &files, &copies)
+	if err != nil {
+		return 1, err
+	}
+	if sameOutput {
+		stderr = stdout
+	} else if stderr, err = outputPipe(stderr, &files, &copies); err != nil {
+		return 1, err
+	}
+
+	// Connect the stages, then apply their redirections on top
+	stdios := make([]stdio, len(stages))
+	buffers := make([]*bytes.Buffer, len(stages))
+	builtinPipes := make([]*os.File, len(stages))
+	stdin := t.cmdIn
+	for i := range stages {
+		stdios[i] = stdio{stdin, stdout, stderr}
+		stdin = nil
+		if cmds[i] == nil {
+			stdios[i][1] = t.stdout
+			if pager != nil {
+				stdios[i][1] = t.newLineWriter(pager)
+			}
+			if i < last {
+				buffers[i] = new(bytes.Buffer)
+				stdios[i][1] = buffers[i]
+			}
+		}
+		if i < last && cmds[i+1] == nil {
+			stdios[i][1] = nil
+		} else if i < last {
+			r, w, err := os.Pipe()
+			if err != nil {
+				return 1, err
+			}
+			files = append(files, r)
+			if cmds[i] == nil {
+				// Written once the builtin has run
+				builtinPipes[i] = w
+			} else {
+				files = append(files, w)
+				stdios[i][1] = w
+			}
+			stdin = r
+		}
+		if err := stdios[i].redirect(stages[i].redirects, &files); err != nil {
+			for _, w := range builtinPipes {
+				if w != nil {
+					w.Close()
+				}
+			}
+			return 1, err
+		}
+	}
+
+	status := 0
+	for i, s := range stages {
+		if cmds[i] != nil {
+			continue
+		}
+		out, ok := stdios[i][1].(io.Writer)
+		if !ok {
+			out = io.Discard
+		}
+		code, err := builtinStatus(t.runBuiltin(s.args, out))
+		if i == last {
+			status = code
+		}
+		if err != nil {
+			t.writeError(err)
+		}
+		if w := builtinPipes[i]; w != nil {
+			go func(output []byte) {
+				w.Write(output)
+				w.Close()
+			}(buffers[i].Bytes())
+		}
+	}
+
+	var started []*exec.Cmd
+	for i, cmd := range cmds {
+		if cmd != nil {
+			cmd.Stdin, _ = stdios[i][0].(io.Reader)
+			cmd.Stdout, _ = stdios[i][1].(io.Writer)
+			cmd.Stderr, _ = stdios[i][2].(io.Writer)
+			started = append(started, cmd)
+		}
+	}
+	if len(started) == 0 {
+		return status, t.pageOutput(pager)
+	}
+
+	if background {
+		job, err := startJob(started, command, 0, false)
+		if err != nil {
+			return startStatus(err), err
+		}
+		t.addJob(job)
+		return 0, t.WriteLine(fmt.Sprintf("[%d] %d", job.ID, job.procs[len(job.procs)-1].pid))
+	}
+
+	t.beginExecuting()
+	defer t.endExecuting()
+	if err := t.Suspend(); err != nil {
+		return 1, err
+	}
+	tty, hasTTY := t.controllingTerminal()
+	job, err := startJob(started, command, tty, hasTTY)
+	// The commands must have the only copies of the pipes left to see the
+	// end of their input
+	for _, f := range files {
+		f.Close()
+	}
+	files = nil
+	jobStatus := startStatus(err)
+	if err == nil {
+		t.progress = progress
+		progress.begin()
+		jobStatus, err = t.waitForeground(job, tty, hasTTY)
+		progress.end()
+		t.progress = nil
+		detached = job.State == JobStopped
+	}
+	if !detached {
+		// What the job wrote is all passed on before the screen is taken back
+		copies.Wait()
+	}
+	if cmds[last] != nil {
+		status = jobStatus
+	}
+	if resumeErr := t.Resume(); err == nil {
+		err = resumeErr
+	}
+	if pager != nil && detached {
+		// A stopped job's output goes on to the terminal when it's resumed
+		pager.release()
+	} else if pager != nil {
+		copies.Wait()
+		if pageErr := t.pageOutput(pager); err == nil {
+			err = pageErr
+		}
+	}
+	return status, err
+}
+
+// beginExecuting hands the screen over to a foreground command. Completions
+// still being computed are abandoned, a menu left below the input is
+// cleared, and nothing is drawn until endExecuting, so that the command's
+// output, such as a progress bar redrawn with \r, isn't cut into.
+func (t *Terminal) beginExecuting() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.CancelCompletions()
+	if t.menu != nil {
+		// The cursor is on the row below the input, where the menu starts
+		t.menu = nil
+		t.writer.WriteString("\r" + clearToEndScreen)
+	}
+	t.executing = true
+	t.writer.Flush()
+}
+
+// endExecuting takes the screen back once the foreground command is done
+func (t *Terminal) endExecuting() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.executing = false
+}
+
+// outputPipe returns a pipe for commands to write to in place of w if w
+// isn't a file, copying what they write to w until they have all exited.
+// The write end is added to files, for the caller to close once the
+// commands have started.
+func outputPipe(w io.Writer, files *[]*os.File, copies *sync.WaitGroup) (io.Writer, error) {
+	if _, ok := w.(*os.File); ok {
+		return w, nil
+	}
+	r, pw, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	*files = append(*files, pw)
+	copies.Add(1)
+	go func() {
+		defer copies.Done()
+		io.Copy(w, r)
+		r.Close()
+	}()
+	return pw, nil
+}
+
+// startStatus returns the exit status the shell gives a command that
+// couldn't be started because of err
+func startStatus(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, exec.ErrNotFound), errors.Is(err, os.ErrNotExist):
+		return 127
+	}
+	return 126
+}
@@ -0,0 +1,86 @@
+package goterm
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FakeTerm is a Terminal driven by a script rather than a person, for
+// testing code built on go-term. Keys given to Type are read by the
+// terminal as if typed, and everything it writes is recorded.
+//
+// The fake starts with the default settings and an empty history kept in a
+// temporary directory, ignoring the user's files.
+type FakeTerm struct {
+	*Terminal
+	input *io.PipeWriter
+	dir   string
+
+	mu     sync.Mutex
+	output bytes.Buffer
+}
+
+// NewFakeTerm creates a fake terminal configured by opts
+func NewFakeTerm(opts ...Option) (*FakeTerm, error) {
+	dir, err := os.MkdirTemp("", "go-term")
+	if err != nil {
+		return nil, err
+	}
+	r, w := io.Pipe()
+	f := &FakeTerm{input: w, dir: dir}
+	f.Terminal = newTerminal(newReaderInput(r), f)
+	f.historyFile = filepath.Join(dir, "history")
+	f.cmdOut = f
+	f.cmdErr = f
+	if err := f.apply(opts); err != nil {
+		w.Close()
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write records output from the terminal
+func (f *FakeTerm) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.output.Write(p)
+}
+
+// Type sends keys to the terminal. Special keys are given as the bytes a
+// terminal would send, such as "\r" for Enter or "\x1b[A" for Up.
+func (f *FakeTerm) Type(keys string) {
+	f.input.Write([]byte(keys))
+}
+
+// CloseInput ends the input, as if the terminal had been closed. Reading
+// then returns io.EOF once the keys already typed have been read.
+func (f *FakeTerm) CloseInput() {
+	f.input.Close()
+}
+
+// Output returns everything written to the terminal so far, including
+// escape sequences
+func (f *FakeTerm) Output() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.output.String()
+}
+
+// Reset forgets the output recorded so far
+func (f *FakeTerm) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.output.Reset()
+}
+
+// Close closes the terminal and its input and removes its temporary files
+func (f *FakeTerm) Close() error {
+	f.input.Close()
+	err := f.Terminal.Close()
+	os.RemoveAll(f.dir)
+	return err
+}
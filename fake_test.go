@@ -0,0 +1,445 @@
+package goterm
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newFake returns a fake terminal closed when the test ends
+func newFake(t *testing.T, opts ...Option) *FakeTerm {
+	t.Helper()
+	f, err := NewFakeTerm(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+// readLine types keys into f while ReadLine waits for them, returning the
+// line read. The keys must end the line.
+func readLine(t *testing.T, f *FakeTerm, keys string) string {
+	t.Helper()
+	type result struct {
+		line string
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		line, err := f.ReadLine("> ")
+		done <- result{line, err}
+	}()
+	// Type blocks until the keys are read, so it can't hold up the test
+	// if ReadLine returns early
+	go f.Type(keys)
+	select {
+	case r := <-done:
+		if r.err != nil {
+			t.Fatalf("ReadLine with %q failed: %v", keys, r.err)
+		}
+		return r.line
+	case <-time.After(5 * time.Second):
+		t.Fatalf("ReadLine with %q didn't return", keys)
+	}
+	return ""
+}
+
+func TestFakeDeleteKey(t *testing.T) {
+	f := newFake(t)
+	tests := []struct {
+		keys string
+		want string
+	}{
+		{keys: "ab\x1b[D\x1b[3~\r", want: "a"},
+		{keys: "abc\x1b[H\x1b[3~\x1b[3~\r", want: "c"},
+		{keys: "ab\x1b[3~\r", want: "ab"},
+		{keys: "ab\x1b[D\x1b[3;5~x\r", want: "ax"},
+	}
+	for _, tt := range tests {
+		if got := readLine(t, f, tt.keys); got != tt.want {
+			t.Errorf("ReadLine with %q = %q, want %q", tt.keys, got, tt.want)
+		}
+	}
+}
+
+func TestFakeWordMovement(t *testing.T) {
+	f := newFake(t)
+	tests := []struct {
+		keys string
+		want string
+	}{
+		{keys: "cd /usr/bin\x1b[1;5DX\r", want: "cd /usr/Xbin"},
+		{keys: "cd /usr/bin\x1bb\x1bbX\r", want: "cd /Xusr/bin"},
+		{keys: "cd /usr/bin\x1b[H\x1b[1;5CX\r", want: "cdX /usr/bin"},
+		{keys: "cd /usr/bin\x01\x1bf\x1bfX\r", want: "cd /usrX/bin"},
+		{keys: "ls\x01\x1b[1;5DX\r", want: "Xls"},
+	}
+	for _, tt := range tests {
+		if got := readLine(t, f, tt.keys); got != tt.want {
+			t.Errorf("ReadLine with %q = %q, want %q", tt.keys, got, tt.want)
+		}
+	}
+}
+
+func TestFakeUndo(t *testing.T) {
+	f := newFake(t)
+	tests := []struct {
+		keys string
+		want string
+	}{
+		{keys: "ls\x1f\x1f\r", want: ""},
+		{keys: "ls\x1f\x1f\x1f\x1f\r", want: ""},
+		{keys: "ls -l\x15\x1f\r", want: "ls -l"},
+		{keys: "ls\x1f\x1f\x19\r", want: "l"},
+		{keys: "ls\x18u\r", want: "l"},
+	}
+	for _, tt := range tests {
+		if got := readLine(t, f, tt.keys); got != tt.want {
+			t.Errorf("ReadLine with %q = %q, want %q", tt.keys, got, tt.want)
+		}
+	}
+}
+
+func TestFakeCursorMovement(t *testing.T) {
+	f := newFake(t)
+	tests := []struct {
+		keys string
+		want string
+	}{
+		{keys: "ac\x1b[Db\r", want: "abc"},
+		{keys: "bc\x1b[Ha\x1b[Fd\r", want: "abcd"},
+		{keys: "bc\x01a\x05d\r", want: "abcd"},
+		{keys: "ab\x1b[D\x1b[D\x1b[D\x1b[C_\r", want: "a_b"},
+		{keys: "ab\x1b[C\x1b[Cc\r", want: "abc"},
+		{keys: "héllo\x1b[D\x1b[D\x1b[D\x1b[D_\r", want: "h_éllo"},
+		{keys: "ls -l\x1b[D\x1b[D\x0b\r", want: "ls "},
+		{keys: "ls -l\x1b[D\x1b[D\x15\r", want: "-l"},
+	}
+	for _, tt := range tests {
+		if got := readLine(t, f, tt.keys); got != tt.want {
+			t.Errorf("ReadLine with %q = %q, want %q", tt.keys, got, tt.want)
+		}
+	}
+}
+
+func TestFakeCompletion(t *testing.T) {
+	dir := t.TempDir()
+	writeExecutables(t, dir, "zqalpha", "zqalps", "zqbeta")
+	t.Setenv("PATH", dir)
+
+	f := newFake(t)
+	tests := []struct {
+		keys string
+		want string
+	}{
+		// A single match is inserted with a space after it
+		{keys: "zqb\t\r", want: "zqbeta "},
+		{keys: "zqb\tx\r", want: "zqbeta x"},
+		// The text every match shares is inserted first
+		{keys: "zqa\t\r", want: "zqalp"},
+		// With nothing to insert, Tab opens the menu and Enter takes the
+		// selection, leaving a second Enter to finish the line
+		{keys: "zqalp\t\r\r", want: "zqalpha "},
+		{keys: "zqalp\t\t\r\r", want: "zqalps "},
+		{keys: "zqalp\t\x1b[Z\r\r", want: "zqalps "},
+		// Editing closes the menu without taking anything
+		{keys: "zqalp\t\x7f\r", want: "zqal"},
+		// Nothing matches
+		{keys: "zqx\t\r", want: "zqx"},
+	}
+	for _, tt := range tests {
+		if got := readLine(t, f, tt.keys); got != tt.want {
+			t.Errorf("ReadLine with %q = %q, want %q", tt.keys, got, tt.want)
+		}
+	}
+}
+
+func TestFakeHistorySearch(t *testing.T) {
+	f := newFake(t)
+	for _, cmd := range []string{"echo ȺȺȺȺȺȺx", "cd İstanbul", "git status", "ls -l", "git log"} {
+		if err := f.AddToHistory(cmd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		keys string
+		want string
+	}{
+		{keys: "\x12git\r", want: "git log"},
+		{keys: "\x12git\x12\r", want: "git status"},
+		{keys: "\x12stat\r", want: "git status"},
+		{keys: "\x12git\x12\x13\r", want: "git log"},
+		{keys: "\x12ls -x\x7f\r", want: "ls -l"},
+		// Leaving the search keeps the command found to edit
+		{keys: "\x12ls\x05 -a\r", want: "ls -l -a"},
+		{keys: "\x12ls\x01sudo \r", want: "sudo ls -l"},
+		{keys: "\x12ls\x1b[D\x1b[Dn\r", want: "ls n-l"},
+		// Ctrl+G puts back the line typed before the search
+		{keys: "echo\x12git\x07\r", want: "echo"},
+		// With no match the line is left as typed
+		{keys: "\x12qqq\r", want: ""},
+		// Matches in entries whose case folding changes byte lengths
+		{keys: "\x12x\r", want: "echo ȺȺȺȺȺȺx"},
+		{keys: "\x12ⱥⱥ\r", want: "echo ȺȺȺȺȺȺx"},
+		{keys: "\x12tan\r", want: "cd İstanbul"},
+	}
+	for _, tt := range tests {
+		if got := readLine(t, f, tt.keys); got != tt.want {
+			t.Errorf("ReadLine with %q = %q, want %q", tt.keys, got, tt.want)
+		}
+	}
+}
+
+func TestFakeSharedHistory(t *testing.T) {
+	f := newFake(t)
+	other := newTerminal(nil, io.Discard)
+	other.historyFile = f.historyFile
+
+	for _, cmd := range []string{"ls", "pwd"} {
+		if err := f.AddToHistory(cmd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Another session appends between key presses, repeating commands
+	// already in the history
+	for _, cmd := range []string{"pwd", "make", "make", "ls"} {
+		if err := other.appendHistory(HistoryEntry{Command: cmd}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := readLine(t, f, "\x1b[A\r"); got != "ls" {
+		t.Errorf("Up after another session ran ls = %q, want %q", got, "ls")
+	}
+	want := []string{"pwd", "make", "ls"}
+	if got := f.History(); !reflect.DeepEqual(got, want) {
+		t.Errorf("history after merging = %q, want %q", got, want)
+	}
+
+	// Searching merges too, and what was merged isn't merged again
+	if err := other.appendHistory(HistoryEntry{Command: "git status"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readLine(t, f, "\x12stat\r"); got != "git status" {
+		t.Errorf("search after another session ran git status = %q, want %q", got, "git status")
+	}
+	if got := readLine(t, f, "\x1b[A\x1b[A\x1b[A\x1b[A\x1b[A\r"); got != "pwd" {
+		t.Errorf("Up to the oldest command = %q, want %q", got, "pwd")
+	}
+	want = append(want, "git status")
+	if got := f.History(); !reflect.DeepEqual(got, want) {
+		t.Errorf("history after merging again = %q, want %q", got, want)
+	}
+
+	// With sharing off, each session keeps to its own commands
+	opts := f.HistoryOptions()
+	opts.Share = false
+	if err := f.SetHistoryOptions(opts); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.appendHistory(HistoryEntry{Command: "exit"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readLine(t, f, "\x1b[A\r"); got != "git status" {
+		t.Errorf("Up with sharing off = %q, want %q", got, "git status")
+	}
+	if got := f.History(); !reflect.DeepEqual(got, want) {
+		t.Errorf("history with sharing off = %q, want %q", got, want)
+	}
+}
+
+func TestConcurrentWriteLine(t *testing.T) {
+	const writers, lines = 16, 200
+	f := newFake(t)
+	f.Reset()
+
+	var wg sync.WaitGroup
+	for g := 0; g < writers; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < lines; i++ {
+				if err := f.WriteLine(fmt.Sprintf("writer %d line %d", g, i)); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	newline := "\r\n"
+	if f.LineMode() {
+		newline = "\n"
+	}
+	seen := make(map[string]bool)
+	for _, line := range strings.SplitAfter(f.Output(), newline) {
+		if line == "" {
+			continue
+		}
+		if !strings.HasSuffix(line, newline) || seen[line] {
+			t.Fatalf("output has %q, which is cut short or repeated", line)
+		}
+		seen[line] = true
+	}
+	for g := 0; g < writers; g++ {
+		for i := 0; i < lines; i++ {
+			if line := fmt.Sprintf("writer %d line %d%s", g, i, newline); !seen[line] {
+				t.Fatalf("output is missing %q", line)
+			}
+		}
+	}
+}
+
+func TestCloseUnblocksReadChar(t *testing.T) {
+	f := newFake(t)
+	done := make(chan error, 1)
+	go func() {
+		_, err := f.ReadChar()
+		done <- err
+	}()
+
+	// Let the read start waiting, then close the terminal but not its
+	// input, which is still open
+	time.Sleep(50 * time.Millisecond)
+	if err := f.Terminal.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != io.EOF {
+			t.Errorf("ReadChar after Close = %v, want EOF", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ReadChar didn't return after Close")
+	}
+}
+
+func TestFakeSearchClearsMenu(t *testing.T) {
+	dir := t.TempDir()
+	writeExecutables(t, dir, "zqalpha", "zqalps", "zqbeta")
+	t.Setenv("PATH", dir)
+
+	f := newFake(t)
+	if err := f.AddToHistory("zqbeta --all"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		keys string
+		want string
+	}{
+		// The menu is open when the search starts
+		{keys: "zq\t\x12beta\r", want: "zqbeta --all"},
+		{keys: "zq\t\t\x12beta\r", want: "zqbeta --all"},
+		// Leaving the search to edit first
+		{keys: "zq\t\x12beta\x1b[C\r", want: "zqbeta --all"},
+		{keys: "zq\t\x12beta\x07\r", want: "zq"},
+	}
+	for _, tt := range tests {
+		f.Reset()
+		if got := readLine(t, f, tt.keys); got != tt.want {
+			t.Errorf("ReadLine with %q = %q, want %q", tt.keys, got, tt.want)
+		}
+		if f.menuShown() || f.menu != nil || f.MenuActive() || len(f.currentSuggestions) > 0 {
+			t.Errorf("ReadLine with %q left the menu open", tt.keys)
+		}
+		// The menu was erased after it was last drawn
+		out := f.Output()
+		if drawn := strings.LastIndex(out, "►"); drawn < 0 || strings.LastIndex(out, "\033[J") < drawn {
+			t.Errorf("ReadLine with %q left the menu on the screen: %q", tt.keys, out)
+		}
+	}
+}
+
+func TestFakeCopyLine(t *testing.T) {
+	tests := []struct {
+		term string
+		want string // Written once the line is copied
+	}{
+		{term: "xterm-256color", want: "\033]52;c;" + base64.StdEncoding.EncodeToString([]byte("echo hi")) + "\a"},
+		// The clipboard can't be set, which is reported before the input
+		// is shown again
+		{term: "dumb", want: "Error: could not set the clipboard: the terminal takes no OSC 52 sequences\r\n\r\033[J> echo hi"},
+	}
+	for _, tt := range tests {
+		t.Setenv("TERM", tt.term)
+		f := newFake(t)
+		if got := readLine(t, f, "echo hi\x18\x03\r"); got != "echo hi" {
+			t.Errorf("TERM=%s: ReadLine = %q, want %q", tt.term, got, "echo hi")
+		}
+		if out := f.Output(); !strings.Contains(out, tt.want) {
+			t.Errorf("TERM=%s: output %q doesn't have %q", tt.term, out, tt.want)
+		}
+	}
+}
+
+func TestFakeNoDrawingWhileExecuting(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the command is a shell script")
+	}
+	dir := t.TempDir()
+	writeExecutables(t, dir, "zqalpha", "zqalps")
+	script := "#!/bin/sh\nprintf '\\r10%%'\nsleep 0.3\nprintf '\\r50%%'\nsleep 0.3\nprintf '\\r100%%\\n'\n"
+	if err := os.WriteFile(filepath.Join(dir, "progress"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	f := newFake(t)
+	if !f.OpenCompletions("zq", 2) {
+		t.Fatal("no completions")
+	}
+	f.Reset()
+
+	done := make(chan error, 1)
+	go func() { done <- f.Run("progress") }()
+
+	// Once the command has started writing, completions computed in the
+	// background come back and ask to be drawn, as does clearing them
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(f.Output(), "10%") {
+		if time.Now().After(deadline) {
+			t.Fatal("the command wrote nothing")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	f.Terminal.mu.Lock()
+	f.RequestCompletions("zq", 2, true, true)
+	f.Terminal.mu.Unlock()
+	time.Sleep(100 * time.Millisecond)
+	f.Terminal.mu.Lock()
+	f.ShowCompletions()
+	f.ClearCompletions()
+	f.Terminal.mu.Unlock()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the command didn't finish")
+	}
+
+	out := f.Output()
+	start, end := strings.Index(out, "\r10%"), strings.Index(out, "\r100%\n")
+	if start < 0 || end < start {
+		t.Fatalf("output %q doesn't have the command's progress", out)
+	}
+	// The menu was cleared before the command started
+	if !strings.Contains(out[:start], "\r\033[J") {
+		t.Errorf("menu wasn't cleared before the command started: %q", out[:start])
+	}
+	if running := out[start:end]; running != "\r10%\r50%" {
+		t.Errorf("output while the command ran = %q, want only its own", running)
+	}
+}
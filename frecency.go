@@ -0,0 +1,287 @@
+package goterm
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Usage records how often and how recently something was run
+type Usage struct {
+	Count    int       `json:"count"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+// Frecency scores the usage by how often it happened, weighting recent use
+// more heavily, in the style of browser location bars
+func (u Usage) Frecency(now time.Time) float64 {
+	weight := 0.25
+	switch age := now.Sub(u.LastUsed); {
+	case age < time.Hour:
+		weight = 4
+	case age < 24*time.Hour:
+		weight = 2
+	case age < 7*24*time.Hour:
+		weight = 1
+	case age < 30*24*time.Hour:
+		weight = 0.5
+	}
+	return float64(u.Count) * weight
+}
+
+// Limits on the usage stats kept and how often they are written
+const (
+	// maxUsageLines is how many command lines are kept. Beyond it, those
+	// with the lowest frecency are dropped.
+	maxUsageLines = 1000
+	// usageSaveEvery is how many uses are recorded before the stats are
+	// written, rather than after every one. Save writes the rest.
+	usageSaveEvery = 10
+)
+
+// UsageStats tracks the usage of command names and whole command lines so
+// completions can be ranked by frecency, and the directories changed to for
+// the j builtin. It is safe for concurrent use.
+type UsageStats struct {
+	mu       sync.Mutex
+	path     string
+	commands map[string]Usage
+	lines    map[string]Usage
+	dirs     map[string]Usage
+	unsaved  int // Uses recorded since the stats were last written
+}
+
+// usageFile is the on-disk form of UsageStats
+type usageFile struct {
+	Commands map[string]Usage `json:"commands"`
+	Lines    map[string]Usage `json:"lines"`
+	Dirs     map[string]Usage `json:"dirs,omitempty"`
+}
+
+// statsFilePath returns the path of the usage stats file, next to the
+// history file
+func statsFilePath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".go_term_stats"), nil
+}
+
+// Load reads the stats from path, which is also where they are saved
+// afterwards. A missing file is not an error.
+func (s *UsageStats) Load(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.path = path
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var f usageFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return err
+	}
+	s.commands, s.lines, s.dirs = f.Commands, f.Lines, f.Dirs
+	return nil
+}
+
+// Save writes the stats to the file they were loaded from, along with the
+// uses recorded since they were last written
+func (s *UsageStats) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.unsaved == 0 {
+		return nil
+	}
+	return s.save()
+}
+
+// saveBatch writes the stats once enough uses have been recorded since
+// they were last written. The caller must hold s.mu.
+func (s *UsageStats) saveBatch() error {
+	s.unsaved++
+	if s.unsaved < usageSaveEvery {
+		return nil
+	}
+	return s.save()
+}
+
+// save writes the stats to the file they were loaded from. The caller must
+// hold s.mu.
+func (s *UsageStats) save() error {
+	if s.path == "" {
+		s.unsaved = 0
+		return nil
+	}
+	data, err := json.Marshal(usageFile{Commands: s.commands, Lines: s.lines, Dirs: s.dirs})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.path, data, 0600); err != nil {
+		return err
+	}
+	s.unsaved = 0
+	return nil
+}
+
+// Record counts a run of the command line and of the command it starts with.
+// The stats are written every few uses rather than each time.
+func (s *UsageStats) Record(line string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.commands == nil {
+		s.commands = make(map[string]Usage)
+		s.lines = make(map[string]Usage)
+	}
+
+	now := time.Now()
+	bump(s.lines, line, now)
+	if fields := shellFields(line); len(fields) > 0 {
+		bump(s.commands, fields[0], now)
+	}
+	// A tenth more are let in before pruning, so that it isn't done for
+	// every line
+	if len(s.lines) > maxUsageLines+maxUsageLines/10 {
+		prune(s.lines, maxUsageLines, now)
+	}
+	return s.saveBatch()
+}
+
+// RecordDir counts a change of directory to dir, an absolute path. The
+// stats are written every few uses rather than each time.
+func (s *UsageStats) RecordDir(dir string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dirs == nil {
+		s.dirs = make(map[string]Usage)
+	}
+	bump(s.dirs, dir, time.Now())
+	return s.saveBatch()
+}
+
+// bump counts a use of key at now in m
+func bump(m map[string]Usage, key string, now time.Time) {
+	u := m[key]
+	u.Count++
+	u.LastUsed = now
+	m[key] = u
+}
+
+// prune drops all but the n keys of m with the highest frecency at now,
+// keeping the most recently used of those that score the same
+func prune(m map[string]Usage, n int, now time.Time) {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := m[keys[i]], m[keys[j]]
+		if fa, fb := a.Frecency(now), b.Frecency(now); fa != fb {
+			return fa > fb
+		}
+		return a.LastUsed.After(b.LastUsed)
+	})
+	for _, key := range keys[min(n, len(keys)):] {
+		delete(m, key)
+	}
+}
+
+// Commands returns a copy of the usage of each command name
+func (s *UsageStats) Commands() map[string]Usage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	commands := make(map[string]Usage, len(s.commands))
+	for name, u := range s.commands {
+		commands[name] = u
+	}
+	return commands
+}
+
+// Dirs returns a copy of the usage of each directory changed to
+func (s *UsageStats) Dirs() map[string]Usage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dirs := make(map[string]Usage, len(s.dirs))
+	for dir, u := range s.dirs {
+		dirs[dir] = u
+	}
+	return dirs
+}
+
+// ForgetDirs removes directories from the usage recorded
+func (s *UsageStats) ForgetDirs(dirs ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, dir := range dirs {
+		delete(s.dirs, dir)
+	}
+	return s.save()
+}
+
+// Reset forgets the usage recorded of commands and command lines, leaving
+// that of directories, and saves the stats
+func (s *UsageStats) Reset() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands, s.lines = nil, nil
+	return s.save()
+}
+
+// sortByFrecency orders completions from best to worst match like
+// sortByScore, then by the frecency of the command name, or of the whole
+// line for history completions, keeping the existing order otherwise. A nil
+// UsageStats orders by score alone.
+func (s *UsageStats) sortByFrecency(completions []Completion) {
+	if s == nil {
+		sortByScore(completions)
+		return
+	}
+	now := time.Now()
+	s.mu.Lock()
+	frecency := make([]float64, len(completions))
+	for i, c := range completions {
+		if c.Kind == CompletionHistory {
+			frecency[i] = s.lines[c.Text].Frecency(now)
+		} else {
+			frecency[i] = s.commands[c.Label()].Frecency(now)
+		}
+	}
+	s.mu.Unlock()
+
+	// Sort indexes so the frecency values stay paired with their completions
+	order := make([]int, len(completions))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		if completions[a].Score != completions[b].Score {
+			return completions[a].Score > completions[b].Score
+		}
+		return frecency[a] > frecency[b]
+	})
+	sorted := make([]Completion, len(completions))
+	for i, k := range order {
+		sorted[i] = completions[k]
+	}
+	copy(completions, sorted)
+}
+
+// CompletionStats returns the usage recorded for each command name, which
+// ranks command and history completions
+func (t *Terminal) CompletionStats() map[string]Usage {
+	return t.stats.Commands()
+}
+
+// ResetCompletionStats forgets all recorded command usage
+func (t *Terminal) ResetCompletionStats() error {
+	return t.stats.Reset()
+}
@@ -0,0 +1,99 @@
+package goterm
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// savedLines returns the use counts of the command lines in the stats file
+// at path
+func savedLines(t *testing.T, path string) map[string]int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f usageFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	for line, u := range f.Lines {
+		counts[line] = u.Count
+	}
+	return counts
+}
+
+func TestUsageStatsSavedInBatches(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats")
+	var s UsageStats
+	if err := s.Load(path); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i < usageSaveEvery; i++ {
+		if err := s.Record("ls"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := savedLines(t, path); got != nil {
+		t.Fatalf("stats written after %d uses: %v", usageSaveEvery-1, got)
+	}
+	if err := s.Record("ls"); err != nil {
+		t.Fatal(err)
+	}
+	if got := savedLines(t, path)["ls"]; got != usageSaveEvery {
+		t.Errorf("saved count after %d uses = %d, want %d", usageSaveEvery, got, usageSaveEvery)
+	}
+
+	// The rest are written by Save
+	if err := s.RecordDir("/tmp"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Record("pwd"); err != nil {
+		t.Fatal(err)
+	}
+	if got := savedLines(t, path)["pwd"]; got != 0 {
+		t.Errorf("saved count of a use still to be written = %d, want 0", got)
+	}
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if got := savedLines(t, path)["pwd"]; got != 1 {
+		t.Errorf("saved count once saved = %d, want 1", got)
+	}
+
+	var loaded UsageStats
+	if err := loaded.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.Dirs()["/tmp"].Count; got != 1 {
+		t.Errorf("loaded count of /tmp = %d, want 1", got)
+	}
+}
+
+func TestUsageStatsPruned(t *testing.T) {
+	var s UsageStats
+	for i := 0; i < 5; i++ {
+		s.Record("make test")
+	}
+	// The line that takes the stats past the limit and a tenth prunes them
+	for i := 0; i < maxUsageLines+maxUsageLines/10; i++ {
+		s.Record(fmt.Sprintf("echo %d", i))
+	}
+	if got := len(s.lines); got != maxUsageLines {
+		t.Errorf("lines kept = %d, want %d", got, maxUsageLines)
+	}
+	if got := s.lines["make test"].Count; got != 5 {
+		t.Errorf("count of the most used line = %d, want 5", got)
+	}
+	if got := s.Commands()["echo"].Count; got != maxUsageLines+maxUsageLines/10 {
+		t.Errorf("count of echo = %d, want every use", got)
+	}
+}
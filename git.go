@@ -0,0 +1,202 @@
+package goterm
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// gitTimeout bounds each git invocation made while completing
+	gitTimeout = 500 * time.Millisecond
+
+	// gitRefsTTL is how long the refs of a repository are reused before
+	// git is run again
+	gitRefsTTL = 3 * time.Second
+)
+
+// gitCommands lists the common git subcommands with a short description of
+// each. Others reported by git itself are offered without one.
+var gitCommands = map[string]string{
+	"add":         "add file contents to the index",
+	"bisect":      "find the commit that introduced a bug",
+	"blame":       "show who last changed each line",
+	"branch":      "list, create or delete branches",
+	"checkout":    "switch branches or restore files",
+	"cherry-pick": "apply the changes of existing commits",
+	"clean":       "remove untracked files",
+	"clone":       "clone a repository",
+	"commit":      "record changes to the repository",
+	"diff":        "show changes",
+	"fetch":       "download objects and refs",
+	"grep":        "print lines matching a pattern",
+	"init":        "create an empty repository",
+	"log":         "show commit logs",
+	"merge":       "join histories together",
+	"mv":          "move or rename a file",
+	"pull":        "fetch and integrate changes",
+	"push":        "update remote refs",
+	"rebase":      "reapply commits on another base",
+	"reflog":      "show the reference log",
+	"remote":      "manage tracked repositories",
+	"reset":       "reset HEAD to a given state",
+	"restore":     "restore working tree files",
+	"revert":      "revert existing commits",
+	"rm":          "remove files from the index",
+	"show":        "show objects",
+	"stash":       "stash away changes",
+	"status":      "show the working tree status",
+	"switch":      "switch branches",
+	"tag":         "create, list or delete tags",
+}
+
+// gitRefCommands lists the subcommands whose arguments are refs
+var gitRefCommands = map[string]bool{
+	"checkout": true,
+	"switch":   true,
+	"merge":    true,
+	"rebase":   true,
+}
+
+// GitCompleter completes git subcommands, and branch and tag names for the
+// subcommands that take them. Other arguments are completed by Files.
+// Outside a git repository no refs are offered.
+type GitCompleter struct {
+	Files *FileCompleter
+	Mode  MatchMode
+
+	commandsOnce sync.Once
+	commands     []string
+
+	mu   sync.Mutex
+	refs map[string]gitRefs // By working directory
+}
+
+// gitRefs is the cached output of git for-each-ref in one directory
+type gitRefs struct {
+	names []string
+	at    time.Time
+}
+
+// Complete implements Completer
+func (g *GitCompleter) Complete(line string, pos int) []Completion {
+	return g.CompleteContext(context.Background(), line, pos)
+}
+
+// CompleteContext implements ContextCompleter
+func (g *GitCompleter) CompleteContext(ctx context.Context, line string, pos int) []Completion {
+	w, _ := wordAt(line, pos)
+	if strings.HasPrefix(w.text, "-") {
+		return nil
+	}
+
+	// The subcommand is the first word after git that isn't an option
+	subcommand := ""
+	for _, field := range shellFields(string([]rune(line)[:w.start]))[1:] {
+		if !strings.HasPrefix(field, "-") {
+			subcommand = field
+			break
+		}
+	}
+
+	switch {
+	case subcommand == "":
+		return g.complete(w, pos, g.subcommands(), gitCommands)
+	case gitRefCommands[subcommand]:
+		return g.complete(w, pos, g.refNames(ctx), nil)
+	case g.Files != nil:
+		return g.Files.CompleteContext(ctx, line, pos)
+	}
+	return nil
+}
+
+// complete offers the names matching the word being typed, described from
+// descriptions when it has an entry for them
+func (g *GitCompleter) complete(w word, pos int, names []string, descriptions map[string]string) []Completion {
+	var completions []Completion
+	for _, name := range names {
+		score, matched, ok := match(g.Mode, w.text, name)
+		if !ok {
+			continue
+		}
+		completions = append(completions, Completion{
+			Text:        quoteWord(name, w.openQuote, true),
+			Display:     name,
+			Kind:        CompletionCustom,
+			Description: descriptions[name],
+			Replace:     pos - w.start,
+			Score:       score,
+			Matched:     matched,
+		})
+	}
+	sortByScore(completions)
+	return completions
+}
+
+// subcommands returns the known subcommands merged with those reported by
+// git --list-cmds=main, which is only run once
+func (g *GitCompleter) subcommands() []string {
+	g.commandsOnce.Do(func() {
+		seen := make(map[string]bool)
+		for name := range gitCommands {
+			seen[name] = true
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), gitTimeout)
+		defer cancel()
+		if out, err := exec.CommandContext(ctx, "git", "--list-cmds=main").Output(); err == nil {
+			for _, name := range strings.Fields(string(out)) {
+				seen[name] = true
+			}
+		}
+		for name := range seen {
+			g.commands = append(g.commands, name)
+		}
+		sort.Strings(g.commands)
+	})
+	return g.commands
+}
+
+// refNames returns the branches and tags of the repository in the current
+// directory, running git only when the cached list has expired
+func (g *GitCompleter) refNames(ctx context.Context) []string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+
+	g.mu.Lock()
+	cached, ok := g.refs[dir]
+	g.mu.Unlock()
+	if ok && time.Since(cached.at) < gitRefsTTL {
+		return cached.names
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, gitTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "git", "for-each-ref", "--format=%(refname:short)").Output()
+	if ctx.Err() == context.Canceled {
+		// Abandoned for newer input, so don't cache the partial result
+		return nil
+	}
+	var names []string
+	if err == nil {
+		names = strings.Fields(string(out))
+	}
+
+	g.mu.Lock()
+	if g.refs == nil {
+		g.refs = make(map[string]gitRefs)
+	}
+	g.refs[dir] = gitRefs{names: names, at: time.Now()}
+	g.mu.Unlock()
+	return names
+}
+
+// SetMatchMode implements matchModeSetter
+func (g *GitCompleter) SetMatchMode(mode MatchMode) {
+	g.Mode = mode
+}
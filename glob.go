@@ -0,0 +1,92 @@
+package goterm
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// globChars are the characters filepath.Match treats specially, which must
+// be escaped to match themselves
+const globChars = `*?[]\`
+
+// globPattern turns a word, as typed, into a pattern for filepath.Glob.
+// Unquoted *, ? and [ keep their meaning, while quoted or escaped ones, and
+// anything else filepath.Match treats specially, are escaped so that they
+// only match themselves. hasMeta reports whether the word has any unquoted
+// glob characters.
+func globPattern(raw string) (pattern string, hasMeta bool) {
+	var b strings.Builder
+	literal := func(r rune) {
+		if strings.ContainsRune(globChars, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+
+	runes := []rune(raw)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; {
+		case r == '\\' && i+1 < len(runes):
+			i++
+			literal(runes[i])
+		case r == '\'' || r == '"':
+			for i++; i < len(runes) && runes[i] != r; i++ {
+				if r == '"' && runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune(doubleQuoteEscapes, runes[i+1]) {
+					i++
+				}
+				literal(runes[i])
+			}
+		case r == '*' || r == '?' || r == '[':
+			hasMeta = true
+			b.WriteRune(r)
+		case r == ']':
+			// Only special after an unquoted [
+			b.WriteRune(r)
+		default:
+			literal(r)
+		}
+	}
+	return b.String(), hasMeta
+}
+
+// expandGlob returns the paths matching a word with unquoted glob
+// characters, sorted. As in other shells, names starting with a dot are only
+// matched by a pattern that starts with one too. valid is false if the word
+// isn't a pattern after all, such as the [ command.
+func expandGlob(pattern string) (matches []string, valid bool) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, false
+	}
+	patternParts := strings.Split(pattern, "/")
+	var kept []string
+	for _, m := range matches {
+		if !hidesDotFiles(patternParts, strings.Split(m, "/")) {
+			kept = append(kept, m)
+		}
+	}
+	sort.Strings(kept)
+	return kept, true
+}
+
+// hidesDotFiles reports whether a match, split into path elements, has a
+// name starting with a dot where the pattern doesn't ask for one
+func hidesDotFiles(patternParts, matchParts []string) bool {
+	if len(patternParts) != len(matchParts) {
+		return false
+	}
+	for i, name := range matchParts {
+		p := patternParts[i]
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(p, ".") && !strings.HasPrefix(p, `\.`) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetNoMatchError sets whether a glob that matches nothing is an error, as
+// in zsh and fish, rather than passed to the command as typed, as bash does
+func (t *Terminal) SetNoMatchError(on bool) {
+	t.noMatchError = on
+}
@@ -2,6 +2,8 @@ module github.com/pk/go-term
 
 go 1.21
 
-require github.com/pkg/term v1.1.0
-
-require golang.org/x/sys v0.15.0 // indirect
+require (
+	github.com/pkg/term v1.1.0
+	golang.org/x/sys v0.15.0
+	golang.org/x/term v0.15.0
+)
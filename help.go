@@ -0,0 +1,261 @@
+package goterm
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// HelpCommand runs `help [name]`, listing the builtins and aliases to out,
+// or showing the usage of the builtin or alias name. Text is wrapped to the
+// width of the terminal.
+func (t *Terminal) HelpCommand(args []string, out io.Writer) error {
+	cols, _, err := t.Size()
+	if err != nil || cols <= 0 {
+		cols = defaultCols
+	}
+	switch {
+	case len(args) > 1:
+		return fmt.Errorf("usage: help [command]")
+	case len(args) == 1:
+		return t.commandHelp(args[0], cols, out)
+	}
+
+	var help strings.Builder
+	help.WriteString("Available commands:\n")
+	var rows [][2]string
+	for _, b := range t.Builtins() {
+		rows = append(rows, [2]string{b.Name, capitalize(b.Summary)})
+	}
+	writeColumns(&help, rows, " - ", cols)
+
+	if aliases := t.Aliases(); len(aliases) > 0 {
+		help.WriteString("\nAliases:\n")
+		names := make([]string, 0, len(aliases))
+		for name := range aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		rows = rows[:0]
+		for _, name := range names {
+			rows = append(rows, [2]string{name, aliases[name]})
+		}
+		writeColumns(&help, rows, " = ", cols)
+	}
+
+	help.WriteString("\n")
+	for _, line := range wrapText("Any other input is run as a command. Commands can be joined with |, && and || or separated by ;, and one ending in & runs in the background. Anything more involved is run by your shell. help followed by a command shows how to use it.", cols-1) {
+		help.WriteString(line + "\n")
+	}
+	help.WriteString("\n")
+	_, err = io.WriteString(out, help.String())
+	return err
+}
+
+// commandHelp writes the usage of the builtin or alias name to out
+func (t *Terminal) commandHelp(name string, cols int, out io.Writer) error {
+	var text string
+	if b, ok := t.Builtin(name); ok {
+		text = b.Usage
+		if text == "" {
+			text = name + "\n\n" + capitalize(b.Summary)
+		}
+	} else if value, ok := t.Alias(name); ok {
+		text = fmt.Sprintf("%s is an alias for %s", name, value)
+	} else {
+		return fmt.Errorf("help: %s: not a builtin or an alias", name)
+	}
+
+	var help strings.Builder
+	for i, paragraph := range strings.Split(text, "\n\n") {
+		if i > 0 {
+			help.WriteString("\n")
+		}
+		for _, line := range wrapText(paragraph, cols-1) {
+			help.WriteString(line + "\n")
+		}
+	}
+	_, err := io.WriteString(out, help.String())
+	return err
+}
+
+// writeColumns writes rows of a name and its description to b, indented,
+// with the descriptions lined up after sep and wrapped to fit in cols. When
+// too little room would be left for them, each description is put on the
+// line after its name instead.
+func writeColumns(b *strings.Builder, rows [][2]string, sep string, cols int) {
+	width := 0
+	for _, row := range rows {
+		width = max(width, stringWidth(row[0]))
+	}
+	indent := 2 + width + len(sep)
+	for _, row := range rows {
+		name := row[0] + strings.Repeat(" ", width-stringWidth(row[0]))
+		if cols-1-indent < 20 {
+			b.WriteString("  " + row[0] + "\n")
+			for _, line := range wrapText(row[1], cols-5) {
+				b.WriteString("    " + line + "\n")
+			}
+			continue
+		}
+		for i, line := range wrapText(row[1], cols-1-indent) {
+			if i == 0 {
+				b.WriteString("  " + name + sep + line + "\n")
+			} else {
+				b.WriteString(strings.Repeat(" ", indent) + line + "\n")
+			}
+		}
+	}
+}
+
+// wrapText breaks text into lines of at most width columns, at spaces where
+// it can. Line breaks in text are taken as spaces.
+func wrapText(text string, width int) []string {
+	width = max(width, 1)
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		switch {
+		case line == "":
+			line = word
+		case stringWidth(line)+1+stringWidth(word) <= width:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+		// A word too long for a line of its own is broken up
+		for stringWidth(line) > width {
+			head := truncateWidth(line, width)
+			if head == "" {
+				_, size := utf8.DecodeRuneInString(line)
+				head = line[:size]
+			}
+			lines = append(lines, head)
+			line = line[len(head):]
+		}
+	}
+	if line != "" || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// capitalize returns s with its first letter in upper case
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+// builtinUsage is the Usage of each default builtin
+var builtinUsage = map[string]string{
+	"alias": `alias [name[=value]...]
+
+Defines each name=value as an alias, so that name at the start
+of a command is replaced by value. A name on its own shows that
+alias, and alias on its own lists them all. Aliases are saved
+for later sessions.`,
+	"bg": `bg [job]
+
+Continues a stopped job, the most recent one if none is given,
+in the background. A job is %N for job N, %- for the one before
+the most recent or %text for the job whose command starts with
+text.`,
+	"cd": `cd [dir | -]
+
+Changes the working directory to dir, or the home directory if
+there is none. cd - goes back to the previous directory. A
+relative directory that isn't in the working directory is looked
+for in the directories of CDPATH.`,
+	"clear": `clear
+
+Clears the screen.`,
+	"debug-keys": `debug-keys
+
+Shows each key as it is pressed: the bytes the terminal sent for
+it, its name in the [keybindings] section of the config file and
+the action it is bound to. Ctrl+C or Ctrl+D ends it.`,
+	"dirs": `dirs [-clv]
+
+Shows the directory stack kept by pushd and popd, the working
+directory first. -c clears it, -l shows full paths rather than
+using ~ and -v shows each directory on its own line, numbered.`,
+	"exit": `exit [n]
+
+Exits with status n, or that of the last command.`,
+	"export": `export [name=value...]
+
+Sets environment variables for the commands run after it, or
+lists the environment if given none.`,
+	"fg": `fg [job]
+
+Brings a job, the most recent one if none is given, to the
+foreground and waits for it. A job is %N for job N, %- for the
+one before the most recent or %text for the job whose command
+starts with text.`,
+	"help": `help [command]
+
+Lists the builtins and aliases, or shows how to use a builtin.`,
+	"history": `history [N] | history -c | history -d N | history import [bash|zsh|fish|auto] [path]
+
+Lists the command history, or its last N entries. -c clears it
+and -d N deletes the Nth entry. import adds the history of
+another shell, the one in $SHELL with auto.`,
+	"j": `j fragment... | j --list
+
+Changes to the directory changed to most often and recently
+whose path holds each fragment in turn, ignoring case, and
+prints it. --list lists the directories remembered, the
+highest ranked first.`,
+	"jobs": `jobs
+
+Lists the background and stopped jobs.`,
+	"popd": `popd [+N]
+
+Goes back to the directory on top of the directory stack and
+removes it. +N removes the Nth directory instead, without
+changing directory.`,
+	"pushd": `pushd [dir | +N]
+
+Saves the working directory on the directory stack and changes
+to dir. With no directory the top two are swapped, and +N
+rotates the stack to bring the Nth directory to the top.`,
+	"quit": `quit [n]
+
+Exits with status n, or that of the last command.`,
+	"reload-config": `reload-config
+
+Reads the config file again, so that changes to it take effect
+straight away.`,
+	"set": `set [name value...]
+
+Sets the environment variable name to the values, joined with
+spaces, or lists the environment if given none.`,
+	"source": `source file
+
+Runs the commands in file as if they had been typed.`,
+	"theme": `theme [name]
+
+Switches to a color theme, or lists them with a preview of each
+if given none.`,
+	"type": `type name...
+
+Shows whether each name is a builtin, an alias or an executable
+file, and which.`,
+	"unalias": `unalias [-a] name...
+
+Removes the aliases named, or with -a all of them.`,
+	"unset": `unset name...
+
+Removes environment variables.`,
+	"which": `which name...
+
+Shows the path of each command, or what it is if it isn't an
+executable file.`,
+}
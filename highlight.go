@@ -0,0 +1,180 @@
+package goterm
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Styles used by the default highlighter
+const (
+	StyleCommand        = "\033[32m" // Green for commands that exist
+	StyleUnknownCommand = "\033[31m" // Red for commands that don't
+	StyleQuoted         = "\033[33m" // Yellow for quoted strings
+	StylePath           = "\033[4m"  // Underline for existing paths
+)
+
+// StyledSpan applies an ANSI style to the characters from Start up to End,
+// counted in runes from the start of the line
+type StyledSpan struct {
+	Start int
+	End   int
+	Style string
+}
+
+// Highlighter colors the input line as it is typed
+type Highlighter interface {
+	Highlight(line string) []StyledSpan
+}
+
+// DefaultHighlighter colors the command green when it can be run and red
+// otherwise, colors quoted strings and underlines arguments that are
+// existing files
+type DefaultHighlighter struct {
+	Aliases   func(name string) (string, bool) // Looks up aliases, if set
+	IsBuiltin func(name string) bool           // Reports whether a command is a builtin, if set
+	Colors    *Colors                          // Colors to use in place of the Style constants, if set
+	// Commands are looked up in the index of PATH kept for completion
+	// rather than by searching PATH on every redraw, if set
+	Commands *CommandCompleter
+
+	paths *pathCache // Arguments already looked up, if set
+}
+
+// Highlight implements Highlighter
+func (h DefaultHighlighter) Highlight(line string) []StyledSpan {
+	colors := DefaultColors()
+	if h.Colors != nil {
+		colors = *h.Colors
+	}
+	var spans []StyledSpan
+	for i, word := range splitWords(line) {
+		// Quoted parts are colored whatever the word is
+		for _, q := range word.quoted {
+			spans = append(spans, StyledSpan{Start: q[0], End: q[1], Style: colors.Quoted})
+		}
+
+		if i == 0 {
+			style := colors.UnknownCommand
+			if h.commandExists(word.text) || h.isAlias(word.text) || h.IsBuiltin != nil && h.IsBuiltin(word.text) {
+				style = colors.Command
+			}
+			spans = append(spans, StyledSpan{Start: word.start, End: word.end, Style: style})
+		} else if h.paths.exists(word.text) {
+			spans = append(spans, StyledSpan{Start: word.start, End: word.end, Style: colors.Path})
+		}
+	}
+	return spans
+}
+
+// isAlias reports whether name is an alias
+func (h DefaultHighlighter) isAlias(name string) bool {
+	if h.Aliases == nil {
+		return false
+	}
+	_, ok := h.Aliases(name)
+	return ok
+}
+
+// commandExists reports whether name can be run from PATH, or is the path
+// of an executable
+func (h DefaultHighlighter) commandExists(name string) bool {
+	if h.Commands == nil || strings.ContainsRune(name, '/') || strings.ContainsRune(name, os.PathSeparator) {
+		return commandExists(name)
+	}
+	return h.Commands.Has(name)
+}
+
+// commandExists reports whether name can be found on PATH
+func commandExists(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// pathExists reports whether the argument names an existing file or directory
+func pathExists(arg string) bool {
+	if arg == "" {
+		return false
+	}
+	_, err := os.Stat(expandTilde(arg))
+	return err == nil
+}
+
+// pathCache remembers which of the arguments typed name existing paths, so
+// that each is looked up once while a line is typed rather than on every
+// redraw. It is cleared for each line, as the commands run in between may
+// have created or removed files.
+type pathCache struct {
+	mu    sync.Mutex
+	found map[string]bool
+}
+
+// exists reports whether arg names an existing path, as pathExists does.
+// A nil cache looks it up every time.
+func (c *pathCache) exists(arg string) bool {
+	if c == nil {
+		return pathExists(arg)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	found, ok := c.found[arg]
+	if !ok {
+		found = pathExists(arg)
+		if c.found == nil {
+			c.found = make(map[string]bool)
+		}
+		c.found[arg] = found
+	}
+	return found
+}
+
+// reset forgets the paths looked up so far
+func (c *pathCache) reset() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.found = nil
+}
+
+// highlightLines renders text with the given spans applied. The text is
+// returned split on newlines, with every line ending in a style reset so
+// that styles never leak into continuation prompts.
+func highlightLines(text string, spans []StyledSpan) []string {
+	runes := []rune(text)
+	styles := make([]string, len(runes))
+	for _, span := range spans {
+		for i := max(span.Start, 0); i < span.End && i < len(runes); i++ {
+			styles[i] += span.Style
+		}
+	}
+
+	var lines []string
+	var b strings.Builder
+	current := ""
+	for i, r := range runes {
+		if r == '\n' {
+			if current != "" {
+				b.WriteString(resetColor)
+				current = ""
+			}
+			lines = append(lines, b.String())
+			b.Reset()
+			continue
+		}
+		if styles[i] != current {
+			if current != "" {
+				b.WriteString(resetColor)
+			}
+			b.WriteString(styles[i])
+			current = styles[i]
+		}
+		b.WriteRune(r)
+	}
+	if current != "" {
+		b.WriteString(resetColor)
+	}
+	return append(lines, b.String())
+}
@@ -0,0 +1,98 @@
+package goterm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// wordStyle returns the style the highlighter gives the word starting at
+// start, or "" if it has none
+func wordStyle(h Highlighter, line string, start int) string {
+	for _, span := range h.Highlight(line) {
+		if span.Start == start {
+			return span.Style
+		}
+	}
+	return ""
+}
+
+func TestHighlightCommandIndex(t *testing.T) {
+	dir := t.TempDir()
+	writeExecutables(t, dir, "zqalpha")
+	t.Setenv("PATH", dir)
+
+	commands := &CommandCompleter{}
+	commands.Refresh()
+	h := DefaultHighlighter{Commands: commands}
+	if got := wordStyle(h, "zqalpha -v", 0); got != StyleCommand {
+		t.Errorf("style of a command in the index = %q, want %q", got, StyleCommand)
+	}
+	if got := wordStyle(h, "zqbeta -v", 0); got != StyleUnknownCommand {
+		t.Errorf("style of a missing command = %q, want %q", got, StyleUnknownCommand)
+	}
+
+	// PATH isn't searched again until the index is rebuilt
+	writeExecutables(t, dir, "zqbeta")
+	if got := wordStyle(h, "zqbeta -v", 0); got != StyleUnknownCommand {
+		t.Errorf("style of a command added since the index was built = %q, want %q", got, StyleUnknownCommand)
+	}
+	commands.Refresh()
+	if got := wordStyle(h, "zqbeta -v", 0); got != StyleCommand {
+		t.Errorf("style of a command once the index is rebuilt = %q, want %q", got, StyleCommand)
+	}
+
+	// A path to an executable is looked at directly
+	if got := wordStyle(h, filepath.Join(dir, "zqbeta"), 0); got != StyleCommand {
+		t.Errorf("style of the path of a command = %q, want %q", got, StyleCommand)
+	}
+}
+
+func TestHighlightPathCache(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.txt")
+	line := "cat " + path
+
+	h := DefaultHighlighter{paths: &pathCache{}}
+	if got := wordStyle(h, line, 4); got != "" {
+		t.Errorf("style of a missing file = %q, want none", got)
+	}
+	// The file is only looked for again once the cache is reset for the
+	// next line
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := wordStyle(h, line, 4); got != "" {
+		t.Errorf("style of a file created while the line is typed = %q, want none", got)
+	}
+	h.paths.reset()
+	if got := wordStyle(h, line, 4); got != StylePath {
+		t.Errorf("style of an existing file = %q, want %q", got, StylePath)
+	}
+
+	// Without a cache, every redraw looks
+	h = DefaultHighlighter{}
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := wordStyle(h, line, 4); got != "" {
+		t.Errorf("style of a removed file = %q, want none", got)
+	}
+}
+
+func TestFakeHighlightNewLine(t *testing.T) {
+	line := "cat " + filepath.Join(t.TempDir(), "notes.txt")
+	f := newFake(t)
+	readLine(t, f, line+"\r")
+	if got := wordStyle(f.highlighter, line, 4); got != "" {
+		t.Errorf("style of a missing file = %q, want none", got)
+	}
+	// A file made since the last line is underlined in the next
+	if err := os.WriteFile(line[4:], nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	readLine(t, f, line+"\r")
+	if got := wordStyle(f.highlighter, line, 4); got != StylePath {
+		t.Errorf("style of a file made since the last line = %q, want %q", got, StylePath)
+	}
+}
@@ -0,0 +1,101 @@
+package goterm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ExpandHistory replaces history references in line, as bash does before
+// running a command:
+//
+//	!!       the previous command
+//	!n       history entry n, counting from 1
+//	!prefix  the most recent command starting with prefix
+//	!$       the last argument of the previous command
+//
+// References inside single quotes or escaped as \! are left alone, as is a
+// ! followed by a space, an equals sign, an opening parenthesis or anything
+// else that can't start a reference, such as a quote or ;. It reports whether anything was expanded, and fails if a reference matches
+// no history entry.
+func ExpandHistory(line string, history []string) (expanded string, changed bool, err error) {
+	if !strings.Contains(line, "!") {
+		return line, false, nil
+	}
+
+	var b strings.Builder
+	runes := []rune(line)
+	quoted, escaped := false, false
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && !quoted:
+			escaped = true
+		case r == '\'':
+			quoted = !quoted
+		case r == '!' && !quoted && i+1 < len(runes) && !strings.ContainsRune(" \t\n=(", runes[i+1]):
+			event, n := historyEvent(runes[i+1:])
+			if n == 0 {
+				// Nothing that can start a reference, as in "done!"
+				break
+			}
+			text, err := lookupHistoryEvent(event, history)
+			if err != nil {
+				return line, false, err
+			}
+			b.WriteString(text)
+			i += n
+			changed = true
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String(), changed, nil
+}
+
+// historyEvent returns the event designator at the start of s, the text
+// after a !, and its length in runes
+func historyEvent(s []rune) (string, int) {
+	if s[0] == '!' || s[0] == '$' {
+		return string(s[:1]), 1
+	}
+	n := 0
+	for n < len(s) && !strings.ContainsRune(" \t\n;&|<>()'\"`!", s[n]) {
+		n++
+	}
+	return string(s[:n]), n
+}
+
+// lookupHistoryEvent returns the text an event designator refers to
+func lookupHistoryEvent(event string, history []string) (string, error) {
+	notFound := fmt.Errorf("!%s: event not found", event)
+	if len(history) == 0 {
+		return "", notFound
+	}
+	previous := history[len(history)-1]
+
+	switch event {
+	case "!":
+		return previous, nil
+	case "$":
+		if arg := LastWord(previous); arg != "" {
+			return arg, nil
+		}
+		return "", notFound
+	}
+
+	if n, err := strconv.Atoi(event); err == nil {
+		if n < 1 || n > len(history) {
+			return "", notFound
+		}
+		return history[n-1], nil
+	}
+	for i := len(history) - 1; i >= 0; i-- {
+		if strings.HasPrefix(history[i], event) {
+			return history[i], nil
+		}
+	}
+	return "", notFound
+}
@@ -0,0 +1,56 @@
+package goterm
+
+import "testing"
+
+func TestExpandHistory(t *testing.T) {
+	history := []string{"ls -l", "git status", "rm -rf build"}
+	tests := []struct {
+		line    string
+		want    string
+		changed bool
+		wantErr bool
+	}{
+		{line: "echo hi", want: "echo hi"},
+		{line: "!!", want: "rm -rf build", changed: true},
+		{line: "sudo !!", want: "sudo rm -rf build", changed: true},
+		{line: "!1", want: "ls -l", changed: true},
+		{line: "!git", want: "git status", changed: true},
+		{line: "echo !$", want: "echo build", changed: true},
+		{line: "!nope", wantErr: true},
+		{line: "!9", wantErr: true},
+
+		// A ! that can't start a reference is left as it is
+		{line: `git commit -m "done!"`, want: `git commit -m "done!"`},
+		{line: "echo a!;ls", want: "echo a!;ls"},
+		{line: "echo a!|cat", want: "echo a!|cat"},
+		{line: "echo a!&", want: "echo a!&"},
+		{line: "echo a!>out", want: "echo a!>out"},
+		{line: "echo a!<in", want: "echo a!<in"},
+		{line: "echo !`date`", want: "echo !`date`"},
+		{line: "echo !'x'", want: "echo !'x'"},
+		{line: "echo hi!", want: "echo hi!"},
+		{line: "echo ! x", want: "echo ! x"},
+		{line: "[ !=x ]", want: "[ !=x ]"},
+		{line: "echo !(x)", want: "echo !(x)"},
+
+		// Quoted and escaped references are left alone
+		{line: "echo '!!'", want: "echo '!!'"},
+		{line: `echo \!!`, want: `echo \!!`},
+	}
+	for _, tt := range tests {
+		got, changed, err := ExpandHistory(tt.line, history)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ExpandHistory(%q) = %q, want an error", tt.line, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ExpandHistory(%q) failed: %v", tt.line, err)
+			continue
+		}
+		if got != tt.want || changed != tt.changed {
+			t.Errorf("ExpandHistory(%q) = %q, %v, want %q, %v", tt.line, got, changed, tt.want, tt.changed)
+		}
+	}
+}
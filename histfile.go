@@ -0,0 +1,324 @@
+package goterm
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultHistoryLimit is the number of entries kept in memory and in
+	// the history file once it is compacted, unless set by WithHistoryLimit
+	defaultHistoryLimit = 1000
+
+	// historyCompactSize is the size in bytes past which the history file
+	// is compacted straight away rather than when the terminal is closed
+	historyCompactSize = 256 << 10
+)
+
+// HistoryEntry is a command from the history with when it ran and for how
+// long. Entries read from old history files have a zero Time and Duration.
+type HistoryEntry struct {
+	Command  string
+	Time     time.Time
+	Duration time.Duration
+}
+
+// encodeHistoryEntry returns e as a line of the history file. Entries with a
+// time are written in the zsh extended form `: <unix time>:<duration in
+// ms>;<command>`. Line breaks inside multi-line commands are marked with a
+// trailing backslash, and backslashes a line of the command ends with are
+// doubled so that they aren't taken for one.
+func encodeHistoryEntry(e HistoryEntry) string {
+	lines := strings.Split(e.Command, "\n")
+	for i, l := range lines {
+		lines[i] = l + strings.Repeat("\\", trailingBackslashes(l))
+	}
+	line := strings.Join(lines, "\\\n")
+	if !e.Time.IsZero() {
+		line = fmt.Sprintf(": %d:%d;%s", e.Time.Unix(), e.Duration.Milliseconds(), line)
+	}
+	return line + "\n"
+}
+
+// decodeHistoryEntry parses the zsh extended form written by
+// encodeHistoryEntry, treating anything else as a plain command
+func decodeHistoryEntry(line string) HistoryEntry {
+	if meta, cmd, ok := strings.Cut(line, ";"); ok && strings.HasPrefix(meta, ": ") {
+		if unix, ms, ok := strings.Cut(meta[2:], ":"); ok {
+			sec, err1 := strconv.ParseInt(unix, 10, 64)
+			dur, err2 := strconv.ParseInt(ms, 10, 64)
+			if err1 == nil && err2 == nil {
+				return HistoryEntry{
+					Command:  cmd,
+					Time:     time.Unix(sec, 0),
+					Duration: time.Duration(dur) * time.Millisecond,
+				}
+			}
+		}
+	}
+	return HistoryEntry{Command: line}
+}
+
+// trailingBackslashes returns the number of backslashes s ends with
+func trailingBackslashes(s string) int {
+	n := 0
+	for n < len(s) && s[len(s)-1-n] == '\\' {
+		n++
+	}
+	return n
+}
+
+// parseHistory splits the contents of a history file into entries, oldest
+// first, skipping empty lines. A line ending in an odd number of
+// backslashes continues a multi-line command on the next line, and the
+// pairs of backslashes before that stand for one each.
+func parseHistory(data string) []HistoryEntry {
+	entries := []HistoryEntry{}
+	var multiLine []string
+	for _, line := range strings.Split(data, "\n") {
+		n := trailingBackslashes(line)
+		line = line[:len(line)-n] + strings.Repeat("\\", n/2)
+		if n%2 == 1 {
+			multiLine = append(multiLine, line)
+			continue
+		}
+		if multiLine != nil {
+			line = strings.Join(append(multiLine, line), "\n")
+			multiLine = nil
+		}
+		if line == "" {
+			continue
+		}
+		if e := decodeHistoryEntry(line); e.Command != "" {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// setHistory replaces the history with entries, keeping the last
+// t.historyLimit, and only the most recent copy of each command if
+// HistoryOptions.Dedup is set. The command list is rebuilt rather than changed in place,
+// as completers may still be reading the old one. The caller must hold
+// t.historyMu for writing.
+func (t *Terminal) setHistory(entries []HistoryEntry) {
+	if t.historyOptions.Dedup {
+		entries = dedupHistory(entries)
+	}
+	if len(entries) > t.historyLimit {
+		entries = entries[len(entries)-t.historyLimit:]
+	}
+	history := make([]string, len(entries))
+	for i, e := range entries {
+		history[i] = e.Command
+	}
+	t.entries = entries
+	t.history = history
+}
+
+// dedupHistory keeps only the most recent copy of each command in entries,
+// leaving the rest in order
+func dedupHistory(entries []HistoryEntry) []HistoryEntry {
+	last := make(map[string]int, len(entries))
+	for i, e := range entries {
+		last[e.Command] = i
+	}
+	if len(last) == len(entries) {
+		return entries
+	}
+	unique := make([]HistoryEntry, 0, len(last))
+	for i, e := range entries {
+		if last[e.Command] == i {
+			unique = append(unique, e)
+		}
+	}
+	return unique
+}
+
+// historyPath returns the path of the history file, working it out on
+// first use
+func (t *Terminal) historyPath() (string, error) {
+	if t.historyFile == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("could not get home directory: %v", err)
+		}
+		t.historyFile = filepath.Join(homeDir, ".go_term_history")
+	}
+	return t.historyFile, nil
+}
+
+// withHistoryFile opens the history file for appending, holding an exclusive
+// lock while fn runs so that other sessions never interleave their writes.
+// Errors are wrapped in ErrHistoryIO.
+func (t *Terminal) withHistoryFile(fn func(f *os.File) error) error {
+	path, err := t.historyPath()
+	if err != nil {
+		return historyError(err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return historyError(err)
+	}
+	defer f.Close()
+
+	if err := lockFile(f); err != nil {
+		return historyError(fmt.Errorf("could not lock %s: %w", path, err))
+	}
+	defer unlockFile(f)
+
+	if err := fn(f); err != nil {
+		return historyError(err)
+	}
+	if err := f.Sync(); err != nil {
+		return historyError(err)
+	}
+
+	// Everything up to here is either in memory or was written from it
+	info, err := f.Stat()
+	if err != nil {
+		return historyError(err)
+	}
+	t.historyOffset = info.Size()
+	return nil
+}
+
+// SyncHistory merges in commands that other sessions have added to the
+// history file since it was last read, unless history sharing is off
+func (t *Terminal) SyncHistory() error {
+	if !t.historyOptions.Share {
+		return nil
+	}
+	return t.withHistoryFile(t.mergeHistoryFile)
+}
+
+// mergeHistoryFile adds the entries appended to the locked history file f
+// since it was last read to the history in memory
+func (t *Terminal) mergeHistoryFile(f *os.File) error {
+	if !t.historyOptions.Share {
+		return nil
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	// A file that has shrunk was compacted by another session, and what is
+	// new in it can't be told apart
+	if info.Size() <= t.historyOffset {
+		return nil
+	}
+	data := make([]byte, info.Size()-t.historyOffset)
+	if _, err := f.ReadAt(data, t.historyOffset); err != nil {
+		return err
+	}
+
+	t.historyMu.Lock()
+	defer t.historyMu.Unlock()
+	entries := t.entries
+	for _, e := range parseHistory(string(data)) {
+		if len(entries) == 0 || entries[len(entries)-1].Command != e.Command {
+			entries = append(entries, e)
+		}
+	}
+	t.setHistory(entries)
+	return nil
+}
+
+// appendHistory adds one entry to the end of the history file, compacting
+// the file if it has grown too large. Entries other sessions have appended
+// are merged into memory first, so they come before this one.
+func (t *Terminal) appendHistory(e HistoryEntry) error {
+	return t.withHistoryFile(func(f *os.File) error {
+		if err := t.mergeHistoryFile(f); err != nil {
+			return err
+		}
+		return t.appendHistoryFile(f, []HistoryEntry{e})
+	})
+}
+
+// appendHistoryFile adds entries to the end of the locked history file f,
+// compacting it if it has grown too large
+func (t *Terminal) appendHistoryFile(f *os.File, entries []HistoryEntry) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	for _, e := range entries {
+		b.WriteString(encodeHistoryEntry(e))
+	}
+	lines := b.String()
+
+	// Files written before entries were appended don't end in a newline
+	if size := info.Size(); size > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, size-1); err != nil {
+			return err
+		}
+		if last[0] != '\n' {
+			lines = "\n" + lines
+		}
+	}
+
+	// A single write to a file opened with O_APPEND lands at the end even
+	// if another session has appended in the meantime
+	if _, err := f.WriteString(lines); err != nil {
+		return err
+	}
+	if info.Size()+int64(len(lines)) > historyCompactSize {
+		return t.compactHistoryFile(f)
+	}
+	return nil
+}
+
+// compactHistory trims the history file to the last t.historyLimit entries,
+// keeping entries added by other sessions
+func (t *Terminal) compactHistory() error {
+	return t.withHistoryFile(t.compactHistoryFile)
+}
+
+// compactHistoryFile trims the locked history file f to the last
+// t.historyLimit entries, dropping older copies of commands first if
+// HistoryOptions.Dedup is set
+func (t *Terminal) compactHistoryFile(f *os.File) error {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	entries := parseHistory(string(data))
+	kept := entries
+	if t.historyOptions.Dedup {
+		kept = dedupHistory(kept)
+	}
+	if len(kept) > t.historyLimit {
+		kept = kept[len(kept)-t.historyLimit:]
+	}
+	if len(kept) == len(entries) {
+		return nil
+	}
+	return rewriteHistoryFile(f, kept)
+}
+
+// rewriteHistoryFile replaces the contents of the locked history file f.
+// The file is rewritten in place rather than replaced, so that sessions
+// waiting for the lock still write to the right file.
+func rewriteHistoryFile(f *os.File, entries []HistoryEntry) error {
+	var b strings.Builder
+	for _, e := range entries {
+		b.WriteString(encodeHistoryEntry(e))
+	}
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.WriteString(b.String())
+	return err
+}
@@ -0,0 +1,182 @@
+package goterm
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHistoryEntryRoundTrip(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	entries := []HistoryEntry{
+		{Command: "ls -l"},
+		{Command: `echo a\\`},
+		{Command: `echo a\`},
+		{Command: "ls"},
+		{Command: "for f in *\ndo echo $f\ndone"},
+		{Command: "echo \\\nls"},
+		{Command: "echo a\\\\\nb\\"},
+		{Command: "make", Time: at, Duration: 1500 * time.Millisecond},
+		{Command: `echo c\`, Time: at},
+		{Command: "pwd", Time: at},
+	}
+	var b strings.Builder
+	for _, e := range entries {
+		b.WriteString(encodeHistoryEntry(e))
+	}
+	got := parseHistory(b.String())
+	if !reflect.DeepEqual(got, entries) {
+		t.Errorf("parseHistory(%q)\n got %q\nwant %q", b.String(), got, entries)
+	}
+}
+
+func TestParseHistory(t *testing.T) {
+	tests := []struct {
+		data string
+		want []string
+	}{
+		{data: "", want: []string{}},
+		{data: "ls\n\npwd\n", want: []string{"ls", "pwd"}},
+		// A lone trailing backslash is a line break in the command
+		{data: "echo a\\\nb\n", want: []string{"echo a\nb"}},
+		// A doubled one is a backslash the command ends with
+		{data: "echo a\\\\\nls\n", want: []string{`echo a\`, "ls"}},
+		{data: "echo a\\\\\\\nb\n", want: []string{"echo a\\\nb"}},
+		{data: ": 1700000000:0;echo a\\\n\n", want: []string{"echo a\n"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, e := range parseHistory(tt.data) {
+			got = append(got, e.Command)
+		}
+		if got == nil {
+			got = []string{}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseHistory(%q) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestConcurrentHistoryAppend(t *testing.T) {
+	const sessions, perSession = 8, 100
+	path := filepath.Join(t.TempDir(), "history")
+
+	// Long multi-line commands show up any writes that were interleaved,
+	// and take the file past historyCompactSize so that it is rewritten
+	// while other sessions are appending. Repeats of a shared command give
+	// each compaction something to drop.
+	payload := strings.Repeat("x", 600)
+	command := func(s, i int) string {
+		return fmt.Sprintf("echo %d-%d %s\ndone", s, i, payload)
+	}
+
+	var wg sync.WaitGroup
+	for s := 0; s < sessions; s++ {
+		term := newTerminal(nil, io.Discard)
+		term.historyFile = path
+		wg.Add(1)
+		go func(s int) {
+			defer wg.Done()
+			for i := 0; i < perSession; i++ {
+				if err := term.appendHistory(HistoryEntry{Command: command(s, i)}); err != nil {
+					t.Error(err)
+					return
+				}
+				if i%2 == 0 {
+					if err := term.appendHistory(HistoryEntry{Command: "ls"}); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}
+		}(s)
+	}
+	wg.Wait()
+	if t.Failed() {
+		return
+	}
+
+	check := newTerminal(nil, io.Discard)
+	check.historyFile = path
+	if err := check.loadHistory(); err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]int)
+	for _, cmd := range check.History() {
+		seen[cmd]++
+	}
+	for s := 0; s < sessions; s++ {
+		for i := 0; i < perSession; i++ {
+			if n := seen[command(s, i)]; n != 1 {
+				t.Errorf("command %d of session %d is in the history %d times, want 1", i, s, n)
+			}
+			delete(seen, command(s, i))
+		}
+	}
+	delete(seen, "ls")
+	for cmd := range seen {
+		t.Errorf("history has %q, which no session wrote", cmd)
+	}
+}
+
+func TestHistoryDedup(t *testing.T) {
+	fixture, err := os.ReadFile(filepath.Join("testdata", "dup_history"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"echo first\nsecond", "cd /tmp", "git status", "ls", "make test"}
+
+	path := filepath.Join(t.TempDir(), "history")
+	if err := os.WriteFile(path, fixture, 0600); err != nil {
+		t.Fatal(err)
+	}
+	term := newTerminal(nil, io.Discard)
+	term.historyFile = path
+	if err := term.loadHistory(); err != nil {
+		t.Fatal(err)
+	}
+	if got := term.History(); !reflect.DeepEqual(got, want) {
+		t.Errorf("history loaded = %q, want %q", got, want)
+	}
+
+	// Compacting drops the same copies from the file
+	if err := term.compactHistory(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range parseHistory(string(data)) {
+		got = append(got, e.Command)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("history file after compacting = %q, want %q", got, want)
+	}
+
+	// With dedup off every copy is kept
+	term = newTerminal(nil, io.Discard)
+	term.historyFile = filepath.Join(t.TempDir(), "history")
+	if err := os.WriteFile(term.historyFile, fixture, 0600); err != nil {
+		t.Fatal(err)
+	}
+	opts := term.HistoryOptions()
+	opts.Dedup = false
+	if err := term.SetHistoryOptions(opts); err != nil {
+		t.Fatal(err)
+	}
+	if err := term.loadHistory(); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(term.History()); got != 202 {
+		t.Errorf("history loaded without dedup has %d entries, want 202", got)
+	}
+}
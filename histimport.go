@@ -0,0 +1,380 @@
+package goterm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// HistoryFormat identifies the history file format of another shell
+type HistoryFormat int
+
+const (
+	HistoryAuto HistoryFormat = iota // Worked out from the file contents
+	HistoryBash                      // One command per line, with optional #<time> lines
+	HistoryZsh                       // zsh extended history, `: <time>:<seconds>;<command>`
+	HistoryFish                      // fish's `- cmd:` entries
+)
+
+// historyFormatNames are the names of the formats, as used by the history
+// import builtin
+var historyFormatNames = map[HistoryFormat]string{
+	HistoryAuto: "auto",
+	HistoryBash: "bash",
+	HistoryZsh:  "zsh",
+	HistoryFish: "fish",
+}
+
+// String returns the name of the format
+func (f HistoryFormat) String() string {
+	return historyFormatNames[f]
+}
+
+// ParseHistoryFormat parses a history format name: "bash", "zsh", "fish"
+// or "auto"
+func ParseHistoryFormat(name string) (HistoryFormat, error) {
+	for format, n := range historyFormatNames {
+		if strings.EqualFold(name, n) {
+			return format, nil
+		}
+	}
+	return HistoryAuto, fmt.Errorf("unknown history format %q", name)
+}
+
+// shellHistoryPath returns where the shell keeps its history by default
+func shellHistoryPath(format HistoryFormat) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	switch format {
+	case HistoryBash:
+		return filepath.Join(homeDir, ".bash_history"), nil
+	case HistoryZsh:
+		return filepath.Join(homeDir, ".zsh_history"), nil
+	case HistoryFish:
+		dataDir := os.Getenv("XDG_DATA_HOME")
+		if dataDir == "" {
+			dataDir = filepath.Join(homeDir, ".local", "share")
+		}
+		return filepath.Join(dataDir, "fish", "fish_history"), nil
+	}
+	return "", fmt.Errorf("no default history file for %s", format)
+}
+
+// detectHistoryFormat guesses the format of a history file from its first
+// non-empty line
+func detectHistoryFormat(data []byte) HistoryFormat {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		switch {
+		case len(bytes.TrimSpace(line)) == 0:
+			continue
+		case bytes.HasPrefix(line, []byte("- cmd: ")):
+			return HistoryFish
+		case bytes.HasPrefix(line, []byte(": ")):
+			return HistoryZsh
+		}
+		break
+	}
+	return HistoryBash
+}
+
+// ImportHistory adds the commands from another shell's history file to the
+// end of the history, oldest first, and saves them to the history file.
+// Commands already in the history, or left out of it by the history
+// options, are not added, and only the last copy of a command repeated in
+// the file is. Lines that can't be parsed are skipped and counted rather
+// than stopping the import.
+func (t *Terminal) ImportHistory(path string, format HistoryFormat) (imported, skipped int, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	if format == HistoryAuto {
+		format = detectHistoryFormat(data)
+	}
+
+	var entries []HistoryEntry
+	switch format {
+	case HistoryBash:
+		entries, skipped = parseBashHistory(data)
+	case HistoryZsh:
+		entries, skipped = parseZshHistory(data)
+	case HistoryFish:
+		entries, skipped = parseFishHistory(data)
+	default:
+		return 0, 0, fmt.Errorf("unknown history format %d", format)
+	}
+
+	err = t.withHistoryFile(func(f *os.File) error {
+		if err := t.mergeHistoryFile(f); err != nil {
+			return err
+		}
+
+		t.historyMu.Lock()
+		defer t.historyMu.Unlock()
+		existing := make(map[string]bool, len(t.history))
+		for _, cmd := range t.history {
+			existing[cmd] = true
+		}
+		var added []HistoryEntry
+		for _, e := range dedupHistory(entries) {
+			if !existing[e.Command] && !t.ignoredByHistory(e.Command) {
+				added = append(added, e)
+			}
+		}
+		if len(added) == 0 {
+			return nil
+		}
+
+		if err := t.appendHistoryFile(f, added); err != nil {
+			return err
+		}
+		all := make([]HistoryEntry, 0, len(t.entries)+len(added))
+		t.setHistory(append(append(all, t.entries...), added...))
+		imported = len(added)
+		return nil
+	})
+	return imported, skipped, err
+}
+
+// parseBashHistory parses a bash history file. With HISTTIMEFORMAT set,
+// bash writes the time of each command on a `#<unix time>` line before it.
+func parseBashHistory(data []byte) (entries []HistoryEntry, skipped int) {
+	var when time.Time
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			continue
+		}
+		if !utf8.ValidString(line) {
+			skipped++
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			if sec, err := strconv.ParseInt(line[1:], 10, 64); err == nil {
+				when = time.Unix(sec, 0)
+				continue
+			}
+		}
+		entries = append(entries, HistoryEntry{Command: line, Time: when})
+		when = time.Time{}
+	}
+	return entries, skipped
+}
+
+// zshMeta marks a byte in a zsh history file that has been altered so that
+// it isn't mistaken for a special character
+const zshMeta = 0x83
+
+// unmetafyZsh undoes zsh's escaping of special bytes, where each is written
+// as zshMeta followed by the byte xor 32
+func unmetafyZsh(data []byte) []byte {
+	if bytes.IndexByte(data, zshMeta) < 0 {
+		return data
+	}
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] == zshMeta && i+1 < len(data) {
+			i++
+			out = append(out, data[i]^32)
+		} else {
+			out = append(out, data[i])
+		}
+	}
+	return out
+}
+
+// parseZshHistory parses a zsh history file, in the extended format or as
+// plain lines. Unlike go-term's own history file, durations are in whole
+// seconds. A line ending in a backslash continues on the next line.
+func parseZshHistory(data []byte) (entries []HistoryEntry, skipped int) {
+	var multiLine []string
+	for _, line := range strings.Split(string(unmetafyZsh(data)), "\n") {
+		if strings.HasSuffix(line, "\\") {
+			multiLine = append(multiLine, strings.TrimSuffix(line, "\\"))
+			continue
+		}
+		if multiLine != nil {
+			line = strings.Join(append(multiLine, line), "\n")
+			multiLine = nil
+		}
+		if line == "" {
+			continue
+		}
+		if !utf8.ValidString(line) {
+			skipped++
+			continue
+		}
+		if !strings.HasPrefix(line, ": ") {
+			entries = append(entries, HistoryEntry{Command: line})
+			continue
+		}
+
+		meta, cmd, ok := strings.Cut(line[2:], ";")
+		unix, secs, ok2 := strings.Cut(meta, ":")
+		sec, err1 := strconv.ParseInt(unix, 10, 64)
+		dur, err2 := strconv.ParseInt(secs, 10, 64)
+		if !ok || !ok2 || err1 != nil || err2 != nil || cmd == "" {
+			skipped++
+			continue
+		}
+		entries = append(entries, HistoryEntry{
+			Command:  cmd,
+			Time:     time.Unix(sec, 0),
+			Duration: time.Duration(dur) * time.Second,
+		})
+	}
+	return entries, skipped
+}
+
+// parseFishHistory parses fish's history file, a subset of YAML where each
+// entry starts with a `- cmd:` line followed by indented fields such as
+// `when:` and a list of `paths:`
+func parseFishHistory(data []byte) (entries []HistoryEntry, skipped int) {
+	var current *HistoryEntry
+	inEntry := false
+	for _, line := range strings.Split(string(data), "\n") {
+		switch {
+		case line == "":
+		case !utf8.ValidString(line):
+			skipped++
+		case strings.HasPrefix(line, "- cmd: "):
+			if current != nil {
+				entries = append(entries, *current)
+			}
+			current = &HistoryEntry{Command: unescapeFish(strings.TrimPrefix(line, "- cmd: "))}
+			inEntry = true
+		case strings.HasPrefix(line, "  when: ") && current != nil:
+			sec, err := strconv.ParseInt(strings.TrimSpace(line[len("  when: "):]), 10, 64)
+			if err != nil {
+				skipped++
+				continue
+			}
+			current.Time = time.Unix(sec, 0)
+		case strings.HasPrefix(line, " ") && inEntry:
+			// Paths and any other fields aren't needed
+		default:
+			// Anything else ends the entry, which is dropped as it can't
+			// be trusted
+			skipped++
+			current, inEntry = nil, false
+		}
+	}
+	if current != nil {
+		entries = append(entries, *current)
+	}
+
+	// Entries with empty commands are of no use
+	kept := entries[:0]
+	for _, e := range entries {
+		if e.Command != "" {
+			kept = append(kept, e)
+		}
+	}
+	return kept, skipped
+}
+
+// unescapeFish decodes a command from fish's history file, where line
+// breaks are written as \n and backslashes are doubled
+func unescapeFish(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// importShellHistories imports each shell's default history file that
+// exists, describing the result of each import in a message
+func (t *Terminal) importShellHistories(formats ...HistoryFormat) ([]string, error) {
+	var messages []string
+	for _, format := range formats {
+		path, err := shellHistoryPath(format)
+		if err != nil {
+			return messages, err
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		msg, err := t.importHistoryMessage(path, format)
+		if err != nil {
+			return messages, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// importHistoryMessage imports the history file at path and describes the
+// result
+func (t *Terminal) importHistoryMessage(path string, format HistoryFormat) (string, error) {
+	imported, skipped, err := t.ImportHistory(path, format)
+	if err != nil {
+		return "", fmt.Errorf("could not import %s: %v", path, err)
+	}
+	msg := fmt.Sprintf("Imported %d commands from %s", imported, path)
+	if skipped > 0 {
+		msg += fmt.Sprintf(", skipped %d malformed lines", skipped)
+	}
+	return msg, nil
+}
+
+// importHistoryCommand runs `history import [bash|zsh|fish|auto] [path]`.
+// Without a path, the shell's default history file is imported, or those
+// of every shell for auto. What was imported is described on out.
+func (t *Terminal) importHistoryCommand(args []string, out io.Writer) error {
+	if len(args) > 2 {
+		return fmt.Errorf("usage: history import [bash|zsh|fish|auto] [path]")
+	}
+	format := HistoryAuto
+	if len(args) > 0 {
+		var err error
+		if format, err = ParseHistoryFormat(args[0]); err != nil {
+			return fmt.Errorf("history import: %v", err)
+		}
+	}
+
+	var messages []string
+	var err error
+	switch {
+	case len(args) == 2:
+		var msg string
+		msg, err = t.importHistoryMessage(expandTilde(args[1]), format)
+		messages = append(messages, msg)
+	case format == HistoryAuto:
+		messages, err = t.importShellHistories(HistoryBash, HistoryZsh, HistoryFish)
+		if err == nil && len(messages) == 0 {
+			messages = append(messages, "No bash, zsh or fish history found")
+		}
+	default:
+		messages, err = t.importShellHistories(format)
+		if err == nil && len(messages) == 0 {
+			return fmt.Errorf("history import: no %s history found", format)
+		}
+	}
+	for _, msg := range messages {
+		if msg != "" {
+			fmt.Fprintln(out, msg)
+		}
+	}
+	return err
+}
@@ -0,0 +1,237 @@
+package goterm
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HistoryOptions controls which commands are kept in the history and how it
+// is searched
+type HistoryOptions struct {
+	// Ignore lists glob patterns, such as "ls" or "cd *", for commands that
+	// are never stored. * matches any text and ? any single character.
+	Ignore []string
+
+	// IgnoreSpace keeps commands typed with a leading space out of history
+	IgnoreSpace bool
+
+	// EraseDups removes earlier copies of a command from the history file
+	// as soon as it is run again
+	EraseDups bool
+
+	// Dedup keeps only the most recent copy of each command in memory, and
+	// in the history file once it is compacted
+	Dedup bool
+
+	// Share merges commands run in other sessions into the history as they
+	// are added, rather than only reading them at startup
+	Share bool
+
+	// SearchEscapeRestores makes Escape leave history search with the line
+	// from before the search, rather than the command found
+	SearchEscapeRestores bool
+}
+
+// DefaultHistoryOptions returns the options used unless configured otherwise
+func DefaultHistoryOptions() HistoryOptions {
+	return HistoryOptions{IgnoreSpace: true, Share: true, Dedup: true}
+}
+
+// SetHistoryOptions changes which commands are kept in the history. It
+// fails without changing anything if an ignore pattern is invalid.
+func (t *Terminal) SetHistoryOptions(opts HistoryOptions) error {
+	ignore := make([]*regexp.Regexp, len(opts.Ignore))
+	for i, pattern := range opts.Ignore {
+		re, err := globRegexp(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid ignore pattern %q: %v", pattern, err)
+		}
+		ignore[i] = re
+	}
+	t.historyOptions = opts
+	t.historyIgnore = ignore
+
+	t.historyMu.Lock()
+	t.setHistory(t.entries)
+	t.historyMu.Unlock()
+	return nil
+}
+
+// HistoryOptions returns the options controlling which commands are kept
+func (t *Terminal) HistoryOptions() HistoryOptions {
+	return t.historyOptions
+}
+
+// newestFirst returns the commands in history from newest to oldest,
+// leaving out older copies of a command. Without HistoryOptions.Dedup the
+// history itself may hold several.
+func newestFirst(history []string) []string {
+	commands := make([]string, 0, len(history))
+	seen := make(map[string]bool, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		if !seen[history[i]] {
+			seen[history[i]] = true
+			commands = append(commands, history[i])
+		}
+	}
+	return commands
+}
+
+// globRegexp compiles a glob pattern matching a whole command into a
+// regular expression. Unlike path globs, * also matches slashes.
+func globRegexp(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// ignoredByHistory reports whether cmd should be run without being stored
+func (t *Terminal) ignoredByHistory(cmd string) bool {
+	if t.historyOptions.IgnoreSpace && (strings.HasPrefix(cmd, " ") || strings.HasPrefix(cmd, "\t")) {
+		return true
+	}
+	trimmed := strings.TrimSpace(cmd)
+	for _, re := range t.historyIgnore {
+		if re.MatchString(trimmed) {
+			return true
+		}
+	}
+	return false
+}
+
+// addHistoryEraseDups adds e to the history in place of any earlier entries
+// for the same command and rewrites the history file to match
+func (t *Terminal) addHistoryEraseDups(e HistoryEntry) error {
+	return t.withHistoryFile(func(f *os.File) error {
+		if err := t.mergeHistoryFile(f); err != nil {
+			return err
+		}
+
+		t.historyMu.Lock()
+		entries := make([]HistoryEntry, 0, len(t.entries)+1)
+		for _, old := range t.entries {
+			if old.Command != e.Command {
+				entries = append(entries, old)
+			}
+		}
+		t.setHistory(append(entries, e))
+		entries = t.entries
+		t.historyMu.Unlock()
+
+		return rewriteHistoryFile(f, entries)
+	})
+}
+
+// DeleteHistory removes the history entry at index i, counting from 0 for
+// the oldest, and saves the history
+func (t *Terminal) DeleteHistory(i int) error {
+	t.historyMu.Lock()
+	if i < 0 || i >= len(t.history) {
+		t.historyMu.Unlock()
+		return fmt.Errorf("history position out of range: %d", i+1)
+	}
+	entries := make([]HistoryEntry, 0, len(t.entries)-1)
+	entries = append(entries, t.entries[:i]...)
+	t.setHistory(append(entries, t.entries[i+1:]...))
+	t.historyMu.Unlock()
+
+	t.historyIndex = -1
+	return t.saveHistory()
+}
+
+// ClearHistory removes every history entry, in memory and on disk
+func (t *Terminal) ClearHistory() error {
+	t.historyMu.Lock()
+	t.setHistory(nil)
+	t.historyMu.Unlock()
+
+	t.historyIndex = -1
+	return t.saveHistory()
+}
+
+// HistoryEntries returns the command history with the time each command
+// ran and how long it took, oldest first
+func (t *Terminal) HistoryEntries() []HistoryEntry {
+	t.historyMu.RLock()
+	defer t.historyMu.RUnlock()
+	return append([]HistoryEntry(nil), t.entries...)
+}
+
+// HistoryCommand runs the history builtin. With no arguments it lists the
+// history, numbered from 1, and `history N` lists the last N entries.
+// `history -c` clears the history, `history -d N` deletes entry N and
+// `history import` adds the commands from another shell's history. Lists
+// written to the terminal are paged.
+func (t *Terminal) HistoryCommand(args []string, out io.Writer) error {
+	switch {
+	case len(args) > 0 && args[0] == "import":
+		return t.importHistoryCommand(args[1:], out)
+	case len(args) == 1 && args[0] == "-c":
+		return t.ClearHistory()
+	case len(args) == 2 && args[0] == "-d":
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("history: %s: numeric argument required", args[1])
+		}
+		return t.DeleteHistory(n - 1)
+	case len(args) > 1 || (len(args) == 1 && strings.HasPrefix(args[0], "-")):
+		return fmt.Errorf("usage: history [-c] [-d N] [N] | history import [bash|zsh|fish|auto] [path]")
+	}
+
+	history := t.HistoryEntries()
+	first := 0
+	if len(args) == 1 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("history: %s: numeric argument required", args[0])
+		}
+		first = max(len(history)-n, 0)
+	}
+
+	var lines []string
+	for i := first; i < len(history); i++ {
+		e := history[i]
+		when, took := "", ""
+		if !e.Time.IsZero() {
+			when = e.Time.Format("2006-01-02 15:04:05")
+			took = formatDuration(e.Duration)
+		}
+		prefix := fmt.Sprintf("%5d  %-19s %7s  ", i+1, when, took)
+
+		// Continuation lines of multi-line commands line up after the prefix
+		cmd := strings.ReplaceAll(e.Command, "\n", "\n"+strings.Repeat(" ", len(prefix)))
+		lines = append(lines, strings.Split(prefix+cmd, "\n")...)
+	}
+	if out == t.stdout {
+		return t.Page(lines)
+	}
+	for _, line := range lines {
+		fmt.Fprintln(out, line)
+	}
+	return nil
+}
+
+// formatDuration shows how long a command took to the nearest millisecond
+// under a second and to a tenth of a second above
+func formatDuration(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(100 * time.Millisecond).String()
+}
@@ -0,0 +1,45 @@
+package goterm
+
+import (
+	"fmt"
+	"time"
+)
+
+// OnPreExec adds a function to be called with each command line Run is
+// given, just before it runs
+func (t *Terminal) OnPreExec(hook func(cmd string)) {
+	t.preExec = append(t.preExec, hook)
+}
+
+// OnPostExec adds a function to be called after each command line Run is
+// given has finished, with the exit status of its last command and how long
+// it took. It is called whether the command succeeded, failed or was
+// interrupted.
+func (t *Terminal) OnPostExec(hook func(cmd string, status int, dur time.Duration)) {
+	t.postExec = append(t.postExec, hook)
+}
+
+// OnCommandNotFound adds a function to be called with the name of a command
+// that isn't a builtin, an alias or on PATH, such as one that offers to
+// install it. Returning true stops the usual message and suggestions from
+// being shown, and stops any functions added after it from being called.
+func (t *Terminal) OnCommandNotFound(hook func(cmd string) bool) {
+	t.notFound = append(t.notFound, hook)
+}
+
+// SetDurationThreshold sets how long a command must run for before the
+// time it took is shown after it, as in `took 4.2s`. Zero turns this off.
+func (t *Terminal) SetDurationThreshold(threshold time.Duration) {
+	t.durationThreshold = threshold
+}
+
+// afterRun calls the OnPostExec hooks for a command line and reports how
+// long it took if that was longer than the duration threshold
+func (t *Terminal) afterRun(line string, dur time.Duration) {
+	for _, hook := range t.postExec {
+		hook(line, t.lastStatus, dur)
+	}
+	if t.durationThreshold > 0 && dur >= t.durationThreshold {
+		t.WriteLine(fmt.Sprintf("took %s", formatDuration(dur)))
+	}
+}
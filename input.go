@@ -0,0 +1,110 @@
+package goterm
+
+import (
+	"io"
+
+	"golang.org/x/term"
+)
+
+// inputSource is where key presses are read from
+type inputSource interface {
+	io.Reader
+	// Available returns the number of bytes that can be read without
+	// blocking
+	Available() (int, error)
+}
+
+// readerInput reads key presses from a reader that isn't a terminal, such
+// as a pipe, so that they can be waited for with a timeout. A goroutine
+// reads ahead into a buffered channel.
+type readerInput struct {
+	bytes chan byte
+	done  chan struct{} // Closed once the reader has failed or ended
+	err   error         // Why the reader stopped, set before done is closed
+}
+
+// newReaderInput starts reading from r in the background
+func newReaderInput(r io.Reader) *readerInput {
+	in := &readerInput{bytes: make(chan byte, 4096), done: make(chan struct{})}
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := r.Read(buf)
+			for _, b := range buf[:n] {
+				in.bytes <- b
+			}
+			if err != nil {
+				in.err = err
+				close(in.done)
+				return
+			}
+		}
+	}()
+	return in
+}
+
+// Read implements io.Reader, blocking until at least one byte is available
+func (in *readerInput) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	select {
+	case b := <-in.bytes:
+		p[0] = b
+	case <-in.done:
+		// Bytes read before the reader ended come first
+		select {
+		case b := <-in.bytes:
+			p[0] = b
+		default:
+			return 0, in.err
+		}
+	}
+	n := 1
+	for n < len(p) {
+		select {
+		case b := <-in.bytes:
+			p[n] = b
+			n++
+		default:
+			return n, nil
+		}
+	}
+	return n, nil
+}
+
+// Available implements inputSource. Once the reader has ended with nothing
+// left to read, the error it ended with is returned.
+func (in *readerInput) Available() (int, error) {
+	if n := len(in.bytes); n > 0 {
+		return n, nil
+	}
+	select {
+	case <-in.done:
+		if n := len(in.bytes); n > 0 {
+			return n, nil
+		}
+		return 0, in.err
+	default:
+		return 0, nil
+	}
+}
+
+// noInput is the input of a terminal in line mode, which has no key presses
+// to read
+type noInput struct{}
+
+// Read implements io.Reader
+func (noInput) Read(p []byte) (int, error) {
+	return 0, io.EOF
+}
+
+// Available implements inputSource
+func (noInput) Available() (int, error) {
+	return 0, io.EOF
+}
+
+// isTerminal reports whether the file descriptor fd is a terminal
+func isTerminal(fd uintptr) bool {
+	return term.IsTerminal(int(fd))
+}
@@ -0,0 +1,218 @@
+package goterm
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/term"
+)
+
+// JobState is what a job's processes are doing
+type JobState int
+
+const (
+	JobRunning JobState = iota
+	JobStopped
+	JobDone
+)
+
+// String returns the state as shown in job notices
+func (s JobState) String() string {
+	switch s {
+	case JobStopped:
+		return "Stopped"
+	case JobDone:
+		return "Done"
+	}
+	return "Running"
+}
+
+// Job is a command or pipeline run in a process group of its own. On
+// Windows, which has no process groups, PID is that of the first command
+// and jobs are never stopped.
+type Job struct {
+	ID      int // Number used to refer to the job, 0 until it joins the job table
+	PID     int // Process group ID, the process ID of the first command
+	Command string
+	State   JobState
+
+	procs []*jobProcess // Processes of the pipeline, in order
+	shown JobState      // State last reported to the user
+	modes *term.State   // Terminal modes when it was stopped, restored by fg
+}
+
+// finished reports whether all of the job's processes have ended
+func (job *Job) finished() bool {
+	for _, p := range job.procs {
+		if !p.done {
+			return false
+		}
+	}
+	return true
+}
+
+// exitCode returns the exit status of a process as the shell reports it,
+// which for one killed by a signal is 128 plus the signal number
+func exitCode(status syscall.WaitStatus) int {
+	if status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return status.ExitStatus()
+}
+
+// addJob adds job to the job table, numbering it after the highest job
+// number in use
+func (t *Terminal) addJob(job *Job) {
+	t.jobsMu.Lock()
+	defer t.jobsMu.Unlock()
+	job.ID = 1
+	for _, j := range t.jobs {
+		job.ID = max(job.ID, j.ID+1)
+	}
+	t.jobs = append(t.jobs, job)
+}
+
+// removeJob takes job out of the job table
+func (t *Terminal) removeJob(job *Job) {
+	t.jobsMu.Lock()
+	defer t.jobsMu.Unlock()
+	for i, j := range t.jobs {
+		if j == job {
+			t.jobs = append(t.jobs[:i], t.jobs[i+1:]...)
+			return
+		}
+	}
+}
+
+// Jobs returns the jobs that are running in the background, stopped, or
+// have finished without being reported yet
+func (t *Terminal) Jobs() []Job {
+	t.updateJobs()
+	t.jobsMu.Lock()
+	defer t.jobsMu.Unlock()
+	jobs := make([]Job, len(t.jobs))
+	for i, j := range t.jobs {
+		jobs[i] = *j
+	}
+	return jobs
+}
+
+// JobNotices reports the jobs that have finished or stopped since they were
+// last reported, and forgets the finished ones. The REPL shows them before
+// the prompt, so they don't appear in the middle of the line being typed.
+func (t *Terminal) JobNotices() []string {
+	t.updateJobs()
+	t.jobsMu.Lock()
+	defer t.jobsMu.Unlock()
+
+	var notices []string
+	var kept []*Job
+	for _, job := range t.jobs {
+		if job.State != job.shown {
+			job.shown = job.State
+			if job.State != JobRunning {
+				notices = append(notices, t.formatJobLocked(job))
+			}
+		}
+		if job.State != JobDone {
+			kept = append(kept, job)
+		}
+	}
+	t.jobs = kept
+	return notices
+}
+
+// formatJob describes job as the jobs builtin lists it
+func (t *Terminal) formatJob(job *Job) string {
+	t.jobsMu.Lock()
+	defer t.jobsMu.Unlock()
+	return t.formatJobLocked(job)
+}
+
+// formatJobLocked is formatJob for callers holding t.jobsMu. The most recent
+// job, which fg and bg use by default, is marked with + and the one before
+// it with -.
+func (t *Terminal) formatJobLocked(job *Job) string {
+	mark := " "
+	if n := len(t.jobs); n > 0 && t.jobs[n-1] == job {
+		mark = "+"
+	} else if n > 1 && t.jobs[n-2] == job {
+		mark = "-"
+	}
+	command := job.Command
+	if job.State == JobRunning {
+		command += " &"
+	}
+	return fmt.Sprintf("[%d]%s  %-8s  %s", job.ID, mark, job.State, command)
+}
+
+// findJob returns the job named by a job spec: %N or N for job N, %+ or %%
+// for the most recent job, %- for the one before it, or %text for the job
+// whose command starts with text. No spec means the most recent job.
+func (t *Terminal) findJob(args []string) (*Job, error) {
+	if len(args) > 1 {
+		return nil, fmt.Errorf("too many arguments")
+	}
+	t.updateJobs()
+	t.jobsMu.Lock()
+	defer t.jobsMu.Unlock()
+
+	spec := "%+"
+	if len(args) == 1 {
+		spec = args[0]
+	}
+	var job *Job
+	n := len(t.jobs)
+	switch name := strings.TrimPrefix(spec, "%"); {
+	case name == "+" || name == "%":
+		if n > 0 {
+			job = t.jobs[n-1]
+		}
+	case name == "-":
+		if n > 1 {
+			job = t.jobs[n-2]
+		}
+	default:
+		id, err := strconv.Atoi(name)
+		for i := n - 1; i >= 0 && job == nil; i-- {
+			if (err == nil && t.jobs[i].ID == id) || (err != nil && strings.HasPrefix(t.jobs[i].Command, name)) {
+				job = t.jobs[i]
+			}
+		}
+	}
+
+	switch {
+	case job == nil && len(args) == 0:
+		return nil, fmt.Errorf("no current job")
+	case job == nil:
+		return nil, fmt.Errorf("%s: no such job", spec)
+	case job.State == JobDone:
+		return nil, fmt.Errorf("%s: job has terminated", spec)
+	}
+	return job, nil
+}
+
+// JobsCommand runs the jobs builtin, listing the jobs to out
+func (t *Terminal) JobsCommand(args []string, out io.Writer) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: jobs")
+	}
+	t.updateJobs()
+	t.jobsMu.Lock()
+	var lines []string
+	for _, job := range t.jobs {
+		lines = append(lines, t.formatJobLocked(job))
+		job.shown = job.State
+	}
+	t.jobsMu.Unlock()
+	for _, line := range lines {
+		fmt.Fprintln(out, line)
+	}
+
+	// Finished jobs are only listed once
+	t.JobNotices()
+	return nil
+}
@@ -0,0 +1,277 @@
+//go:build unix
+
+package goterm
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/term"
+)
+
+// jobProcess is one of the processes of a job
+type jobProcess struct {
+	pid    int
+	status syscall.WaitStatus
+	done   bool // Exited or killed, with its status recorded
+}
+
+// foregroundGroup returns the process group in the foreground of the
+// terminal open on fd
+func foregroundGroup(fd int) (int, error) {
+	var pgid int32
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd),
+		uintptr(syscall.TIOCGPGRP), uintptr(unsafe.Pointer(&pgid)))
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(pgid), nil
+}
+
+// setForegroundGroup puts the process group pgid in the foreground of the
+// terminal open on fd
+func setForegroundGroup(fd, pgid int) error {
+	id := int32(pgid)
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd),
+		uintptr(syscall.TIOCSPGRP), uintptr(unsafe.Pointer(&id)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// takeTerminal puts go-term's own process group back in the foreground of
+// the terminal. The kernel stops background processes that try this with
+// SIGTTOU, so it is ignored meanwhile.
+func takeTerminal(fd int) error {
+	signal.Ignore(syscall.SIGTTOU)
+	defer signal.Reset(syscall.SIGTTOU)
+	return setForegroundGroup(fd, syscall.Getpgrp())
+}
+
+// waitJob waits for the process pid to exit or be stopped
+func waitJob(pid int) (syscall.WaitStatus, error) {
+	var status syscall.WaitStatus
+	for {
+		_, err := syscall.Wait4(pid, &status, syscall.WUNTRACED, nil)
+		if err != syscall.EINTR {
+			return status, err
+		}
+	}
+}
+
+// controllingTerminal returns the descriptor of the terminal commands are
+// run on, and whether there is one
+func (t *Terminal) controllingTerminal() (int, bool) {
+	f, ok := t.cmdIn.(*os.File)
+	if !ok {
+		return 0, false
+	}
+	fd := int(f.Fd())
+	_, err := foregroundGroup(fd)
+	return fd, err == nil
+}
+
+// startJob starts cmds, the commands of a pipeline already connected to one
+// another, in a process group of their own. A foreground job is given the
+// terminal as its first command starts, so that Ctrl+C and Ctrl+Z go to it
+// rather than go-term. If a command can't be started, those already running
+// are killed.
+func startJob(cmds []*exec.Cmd, command string, tty int, foreground bool) (*Job, error) {
+	job := &Job{Command: command}
+	for i, cmd := range cmds {
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Pgid: job.PID}
+		if i == 0 {
+			cmd.SysProcAttr.Foreground = foreground
+			cmd.SysProcAttr.Ctty = tty
+		}
+		if err := cmd.Start(); err != nil {
+			for _, p := range job.procs {
+				syscall.Kill(p.pid, syscall.SIGKILL)
+				waitJob(p.pid)
+			}
+			if foreground && len(job.procs) > 0 {
+				takeTerminal(tty)
+			}
+			return nil, err
+		}
+		if i == 0 {
+			job.PID = cmd.Process.Pid
+		}
+		// The processes are waited for directly, to learn when they are
+		// stopped
+		job.procs = append(job.procs, &jobProcess{pid: cmd.Process.Pid})
+		cmd.Process.Release()
+	}
+	return job, nil
+}
+
+// waitForeground waits for job, which has been given the terminal, to exit
+// or be stopped and then takes the terminal back, returning the exit status
+// of its last command. A stopped job is kept in the job table and any other
+// is removed from it.
+func (t *Terminal) waitForeground(job *Job, tty int, hasTTY bool) (int, error) {
+	t.jobsMu.Lock()
+	t.foreground = job
+	t.jobsMu.Unlock()
+	var err error
+	stopped := false
+	for _, p := range job.procs {
+		if p.done {
+			continue
+		}
+		var status syscall.WaitStatus
+		if status, err = waitJob(p.pid); err != nil {
+			break
+		}
+		if status.Stopped() {
+			// Ctrl+Z stops the whole group. Reports of the other processes
+			// stopping are dropped by the kernel once they are continued.
+			stopped = true
+			break
+		}
+		p.status, p.done = status, true
+	}
+	t.jobsMu.Lock()
+	t.foreground = nil
+	t.jobsMu.Unlock()
+	t.progress.end()
+
+	if hasTTY {
+		if err := takeTerminal(tty); err != nil {
+			return 1, fmt.Errorf("could not take back the terminal: %v", err)
+		}
+	}
+	if err != nil {
+		return 1, err
+	}
+	if stopped {
+		// Programs that don't restore the terminal themselves when stopped
+		// expect to find it as they left it when continued
+		job.modes = nil
+		if hasTTY {
+			job.modes, _ = term.GetState(tty)
+		}
+		job.State, job.shown = JobStopped, JobStopped
+		if job.ID == 0 {
+			t.addJob(job)
+		}
+		t.print("\n" + t.formatJob(job) + "\n")
+		return 128 + int(syscall.SIGTSTP), nil
+	}
+
+	t.removeJob(job)
+	status := job.procs[len(job.procs)-1].status
+	switch {
+	case status.Signaled() && status.Signal() == syscall.SIGINT:
+		// Interrupted with Ctrl+C, which the terminal has echoed
+		t.print("\n")
+	case status.Signaled():
+		return exitCode(status), fmt.Errorf("signal: %v", status.Signal())
+	}
+	return exitCode(status), nil
+}
+
+// updateJobs records which jobs have finished, stopped or continued since
+// they were last checked, without waiting for any
+func (t *Terminal) updateJobs() {
+	t.jobsMu.Lock()
+	defer t.jobsMu.Unlock()
+	for _, job := range t.jobs {
+		if job.State == JobDone {
+			continue
+		}
+		for _, p := range job.procs {
+			if p.done {
+				continue
+			}
+			var status syscall.WaitStatus
+			pid, err := syscall.Wait4(p.pid, &status, syscall.WNOHANG|syscall.WUNTRACED|syscall.WCONTINUED, nil)
+			switch {
+			case err != nil:
+				// Already reaped, so there is nothing more to learn
+				p.done = true
+			case pid == 0:
+			case status.Exited() || status.Signaled():
+				p.status, p.done = status, true
+			case status.Stopped():
+				job.State = JobStopped
+			case status.Continued():
+				job.State = JobRunning
+			}
+		}
+		if job.finished() {
+			job.State = JobDone
+		}
+	}
+}
+
+// ForegroundCommand runs `fg [job]`, continuing a stopped or background job
+// with the terminal and waiting for it. The job's command is written to out.
+func (t *Terminal) ForegroundCommand(args []string, out io.Writer) error {
+	job, err := t.findJob(args)
+	if err != nil {
+		return fmt.Errorf("fg: %v", err)
+	}
+	fmt.Fprintln(out, job.Command)
+
+	t.beginExecuting()
+	defer t.endExecuting()
+	if err := t.Suspend(); err != nil {
+		return err
+	}
+	tty, hasTTY := t.controllingTerminal()
+	if hasTTY && job.modes != nil {
+		err = term.Restore(tty, job.modes)
+	}
+	if hasTTY && err == nil {
+		err = setForegroundGroup(tty, job.PID)
+	}
+	if err == nil {
+		err = syscall.Kill(-job.PID, syscall.SIGCONT)
+	}
+	if err == nil {
+		job.State, job.shown = JobRunning, JobRunning
+		_, err = t.waitForeground(job, tty, hasTTY)
+	}
+	if resumeErr := t.Resume(); err == nil {
+		err = resumeErr
+	}
+	return err
+}
+
+// BackgroundCommand runs `bg [job]`, continuing a stopped job in the
+// background and writing it to out
+func (t *Terminal) BackgroundCommand(args []string, out io.Writer) error {
+	job, err := t.findJob(args)
+	if err != nil {
+		return fmt.Errorf("bg: %v", err)
+	}
+	if job.State == JobRunning {
+		return fmt.Errorf("bg: job %d already in background", job.ID)
+	}
+	if err := syscall.Kill(-job.PID, syscall.SIGCONT); err != nil {
+		return fmt.Errorf("bg: %v", err)
+	}
+	job.State, job.shown = JobRunning, JobRunning
+	fmt.Fprintln(out, t.formatJob(job))
+	return nil
+}
+
+// ForwardSignal sends sig to the process group of the command running in
+// the foreground, reporting whether there was one
+func (t *Terminal) ForwardSignal(sig syscall.Signal) bool {
+	t.jobsMu.Lock()
+	job := t.foreground
+	t.jobsMu.Unlock()
+	if job == nil {
+		return false
+	}
+	syscall.Kill(-job.PID, sig)
+	return true
+}
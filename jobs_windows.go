@@ -0,0 +1,150 @@
+package goterm
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// statusControlCExit is the exit status of a program ended by Ctrl+C
+const statusControlCExit = 0xC000013A
+
+// jobProcess is one of the processes of a job
+type jobProcess struct {
+	pid    int
+	status syscall.WaitStatus
+	done   bool          // Exited, with its status recorded
+	exited chan struct{} // Closed once status is set
+}
+
+// controllingTerminal reports that there is no terminal to hand to
+// commands, as Windows has no process groups to hand it to. The console
+// sends Ctrl+C to every program attached to it.
+func (t *Terminal) controllingTerminal() (int, bool) {
+	return 0, false
+}
+
+// startJob starts cmds, the commands of a pipeline already connected to one
+// another, waiting for each in the background. If a command can't be
+// started, those already running are killed.
+func startJob(cmds []*exec.Cmd, command string, tty int, foreground bool) (*Job, error) {
+	job := &Job{Command: command}
+	for i, cmd := range cmds {
+		// cmd reads its command line itself rather than as Go quotes
+		// arguments, so a line run with cmd /C is passed on as it is
+		if len(cmd.Args) == 3 && strings.EqualFold(cmd.Args[1], "/C") {
+			cmd.SysProcAttr = &syscall.SysProcAttr{
+				CmdLine: syscall.EscapeArg(cmd.Path) + ` /S /C "` + cmd.Args[2] + `"`,
+			}
+		}
+		if err := cmd.Start(); err != nil {
+			for j, p := range job.procs {
+				cmds[j].Process.Kill()
+				<-p.exited
+			}
+			return nil, err
+		}
+		if i == 0 {
+			job.PID = cmd.Process.Pid
+		}
+		p := &jobProcess{pid: cmd.Process.Pid, exited: make(chan struct{})}
+		job.procs = append(job.procs, p)
+		go func(cmd *exec.Cmd) {
+			cmd.Wait()
+			if cmd.ProcessState != nil {
+				p.status, _ = cmd.ProcessState.Sys().(syscall.WaitStatus)
+			}
+			close(p.exited)
+		}(cmd)
+	}
+	return job, nil
+}
+
+// waitForeground waits for job to exit, removing it from the job table, and
+// returns the exit status of its last command
+func (t *Terminal) waitForeground(job *Job, tty int, hasTTY bool) (int, error) {
+	t.jobsMu.Lock()
+	t.foreground = job
+	t.jobsMu.Unlock()
+	for _, p := range job.procs {
+		<-p.exited
+		p.done = true
+	}
+	t.jobsMu.Lock()
+	t.foreground = nil
+	t.jobsMu.Unlock()
+	t.progress.end()
+
+	t.removeJob(job)
+	status := job.procs[len(job.procs)-1].status
+	if status.ExitCode == statusControlCExit {
+		// Interrupted with Ctrl+C, which the console has echoed
+		t.print("\n")
+	}
+	return exitCode(status), nil
+}
+
+// updateJobs records which jobs have finished since they were last checked,
+// without waiting for any
+func (t *Terminal) updateJobs() {
+	t.jobsMu.Lock()
+	defer t.jobsMu.Unlock()
+	for _, job := range t.jobs {
+		if job.State == JobDone {
+			continue
+		}
+		for _, p := range job.procs {
+			select {
+			case <-p.exited:
+				p.done = true
+			default:
+			}
+		}
+		if job.finished() {
+			job.State = JobDone
+		}
+	}
+}
+
+// ForegroundCommand runs `fg [job]`, waiting for a background job as if it
+// had been run in the foreground. The job's command is written to out.
+func (t *Terminal) ForegroundCommand(args []string, out io.Writer) error {
+	job, err := t.findJob(args)
+	if err != nil {
+		return fmt.Errorf("fg: %v", err)
+	}
+	fmt.Fprintln(out, job.Command)
+
+	t.beginExecuting()
+	defer t.endExecuting()
+	if err := t.Suspend(); err != nil {
+		return err
+	}
+	job.shown = JobRunning
+	_, err = t.waitForeground(job, 0, false)
+	if resumeErr := t.Resume(); err == nil {
+		err = resumeErr
+	}
+	return err
+}
+
+// BackgroundCommand runs `bg [job]`. Jobs are never stopped on Windows, so
+// there is nothing to continue.
+func (t *Terminal) BackgroundCommand(args []string, out io.Writer) error {
+	job, err := t.findJob(args)
+	if err != nil {
+		return fmt.Errorf("bg: %v", err)
+	}
+	return fmt.Errorf("bg: job %d already in background", job.ID)
+}
+
+// ForwardSignal reports whether a command is running in the foreground. The
+// console sends Ctrl+C and Ctrl+Break to it directly, and other signals
+// can't be sent, so sig is not passed on.
+func (t *Terminal) ForwardSignal(sig syscall.Signal) bool {
+	t.jobsMu.Lock()
+	defer t.jobsMu.Unlock()
+	return t.foreground != nil
+}
@@ -0,0 +1,117 @@
+package goterm
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// rankedDir is a directory changed to before and its frecency
+type rankedDir struct {
+	path  string
+	score float64
+}
+
+// rankDirs returns the directories changed to before whose paths hold each
+// of fragments in turn, ignoring case, the most frecent first. Directories
+// that no longer exist are forgotten as they are come across.
+func (t *Terminal) rankDirs(fragments []string) []rankedDir {
+	now := time.Now()
+	var ranked []rankedDir
+	var missing []string
+	for dir, u := range t.stats.Dirs() {
+		if !matchDir(dir, fragments) {
+			continue
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			missing = append(missing, dir)
+			continue
+		}
+		ranked = append(ranked, rankedDir{path: dir, score: u.Frecency(now)})
+	}
+	if len(missing) > 0 {
+		t.stats.ForgetDirs(missing...)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return ranked[i].path < ranked[j].path
+	})
+	return ranked
+}
+
+// matchDir reports whether dir holds each of fragments in turn, ignoring
+// case
+func matchDir(dir string, fragments []string) bool {
+	rest := dir
+	for _, f := range fragments {
+		_, end := foldedIndex(rest, f)
+		if end < 0 {
+			return false
+		}
+		rest = rest[end:]
+	}
+	return true
+}
+
+// JumpCommand runs `j fragment...`, which changes to the most frecent
+// directory changed to before whose path holds each fragment in turn, and
+// writes it to out. `j --list` lists the directories by frecency instead.
+func (t *Terminal) JumpCommand(args []string, out io.Writer) error {
+	if len(args) == 1 && args[0] == "--list" {
+		for _, d := range t.rankDirs(nil) {
+			fmt.Fprintf(out, "%8.2f  %s\n", d.score, abbreviateHome(d.path))
+		}
+		return nil
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("usage: j fragment... | j --list")
+	}
+
+	// The working directory is skipped, so that repeating j with a
+	// fragment more than one directory holds moves on to the next
+	pwd, _ := os.Getwd()
+	for _, d := range t.rankDirs(args) {
+		if d.path == pwd {
+			continue
+		}
+		if err := t.chdir("j", d.path); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintln(out, abbreviateHome(d.path))
+		return err
+	}
+	return fmt.Errorf("j: no directory matches %s", strings.Join(args, " "))
+}
+
+// JumpCompleter offers the directories changed to before, for j, the most
+// frecent first
+type JumpCompleter struct {
+	Terminal *Terminal
+}
+
+// Complete implements Completer
+func (j *JumpCompleter) Complete(line string, pos int) []Completion {
+	w, _ := wordAt(line, pos)
+	if strings.HasPrefix(w.text, "-") {
+		return nil
+	}
+
+	ranked := j.Terminal.rankDirs([]string{w.text})
+	completions := make([]Completion, 0, len(ranked))
+	for i, d := range ranked {
+		completions = append(completions, Completion{
+			Text:    quoteWord(d.path, w.openQuote, true),
+			Display: abbreviateHome(d.path),
+			Kind:    CompletionFile,
+			Replace: pos - w.start,
+			// Keep the order by frecency
+			Score: len(ranked) - i,
+		})
+	}
+	return completions
+}
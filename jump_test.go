@@ -0,0 +1,30 @@
+package goterm
+
+import "testing"
+
+func TestMatchDir(t *testing.T) {
+	tests := []struct {
+		dir       string
+		fragments []string
+		want      bool
+	}{
+		{dir: "/home/me/src/go-term", fragments: nil, want: true},
+		{dir: "/home/me/src/go-term", fragments: []string{"term"}, want: true},
+		{dir: "/home/me/src/go-term", fragments: []string{"SRC", "Term"}, want: true},
+		{dir: "/home/me/src/go-term", fragments: []string{"term", "src"}, want: false},
+		{dir: "/home/me/src/go-term", fragments: []string{"src", "src"}, want: false},
+		{dir: "/home/me/Projekte/Ärger", fragments: []string{"projekte", "är"}, want: true},
+		{dir: "/home/me/ÄRGER", fragments: []string{"ärg"}, want: true},
+		// Case folding that changes byte lengths
+		{dir: "/tmp/xi", fragments: []string{"İ"}, want: false},
+		{dir: "/tmp/İstanbul", fragments: []string{"İ", "tan"}, want: true},
+		{dir: "/tmp/ȺȺx", fragments: []string{"ⱥ", "x"}, want: true},
+		{dir: "/tmp/ⱥⱥx/y", fragments: []string{"Ⱥx", "y"}, want: true},
+		{dir: "/tmp/KELVIN", fragments: []string{"K"}, want: true},
+	}
+	for _, tt := range tests {
+		if got := matchDir(tt.dir, tt.fragments); got != tt.want {
+			t.Errorf("matchDir(%q, %q) = %v, want %v", tt.dir, tt.fragments, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,370 @@
+package goterm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Action names an editing operation that a key can be bound to
+type Action string
+
+// Actions understood by the REPL loop
+const (
+	ActionNone                 Action = ""
+	ActionSelfInsert           Action = "self-insert"
+	ActionAcceptLine           Action = "accept-line"
+	ActionComplete             Action = "complete"
+	ActionCompletePrevious     Action = "complete-previous"
+	ActionBackwardDeleteChar   Action = "backward-delete-char"
+	ActionDeleteChar           Action = "delete-char"
+	ActionDeleteCharOrExit     Action = "delete-char-or-exit"
+	ActionBackwardChar         Action = "backward-char"
+	ActionForwardChar          Action = "forward-char"
+	ActionBackwardWord         Action = "backward-word"
+	ActionForwardWord          Action = "forward-word"
+	ActionBeginningOfLine      Action = "beginning-of-line"
+	ActionEndOfLine            Action = "end-of-line"
+	ActionHistoryPrev          Action = "history-prev"
+	ActionHistoryNext          Action = "history-next"
+	ActionHistorySearch        Action = "history-search"
+	ActionHistorySearchForward Action = "history-search-forward"
+	ActionHistoryPicker        Action = "history-picker"
+	ActionMenuPrev             Action = "menu-prev"
+	ActionMenuNext             Action = "menu-next"
+	ActionMenuPageUp           Action = "menu-page-up"
+	ActionMenuPageDown         Action = "menu-page-down"
+	ActionDismiss              Action = "dismiss"
+	ActionUndo                 Action = "undo"
+	ActionRedo                 Action = "redo"
+	ActionKillLine             Action = "kill-line"
+	ActionBackwardKillLine     Action = "backward-kill-line"
+	ActionKillWord             Action = "kill-word"
+	ActionBackwardKillWord     Action = "backward-kill-word"
+	ActionYank                 Action = "yank"
+	ActionYankPop              Action = "yank-pop"
+	ActionYankLastArg          Action = "yank-last-arg"
+	ActionPaste                Action = "paste"
+	ActionEditCommandLine      Action = "edit-command-line"
+	ActionCopyLine             Action = "copy-line"
+	ActionPasteClipboard       Action = "paste-clipboard"
+	ActionInterrupt            Action = "interrupt"
+	ActionRedraw               Action = "redraw"
+
+	// ActionPrefix marks a key that starts a multi-key sequence such as
+	// Ctrl+X u. It is set up by SetKeySequence rather than bound directly.
+	ActionPrefix Action = "prefix"
+)
+
+// knownActions lists every action that can be used in a key binding
+var knownActions = map[Action]bool{
+	ActionNone:                 true,
+	ActionSelfInsert:           true,
+	ActionAcceptLine:           true,
+	ActionComplete:             true,
+	ActionCompletePrevious:     true,
+	ActionBackwardDeleteChar:   true,
+	ActionDeleteChar:           true,
+	ActionDeleteCharOrExit:     true,
+	ActionBackwardChar:         true,
+	ActionForwardChar:          true,
+	ActionBackwardWord:         true,
+	ActionForwardWord:          true,
+	ActionBeginningOfLine:      true,
+	ActionEndOfLine:            true,
+	ActionHistoryPrev:          true,
+	ActionHistoryNext:          true,
+	ActionHistorySearch:        true,
+	ActionHistorySearchForward: true,
+	ActionHistoryPicker:        true,
+	ActionMenuPrev:             true,
+	ActionMenuNext:             true,
+	ActionMenuPageUp:           true,
+	ActionMenuPageDown:         true,
+	ActionDismiss:              true,
+	ActionUndo:                 true,
+	ActionRedo:                 true,
+	ActionKillLine:             true,
+	ActionBackwardKillLine:     true,
+	ActionKillWord:             true,
+	ActionBackwardKillWord:     true,
+	ActionYank:                 true,
+	ActionYankPop:              true,
+	ActionYankLastArg:          true,
+	ActionEditCommandLine:      true,
+	ActionCopyLine:             true,
+	ActionPasteClipboard:       true,
+	ActionInterrupt:            true,
+	ActionRedraw:               true,
+}
+
+// Keymap maps key presses to the actions they trigger
+type Keymap map[KeyEvent]Action
+
+// defaultKeySequences returns the standard multi-key bindings
+func defaultKeySequences() map[KeyEvent]Keymap {
+	return map[KeyEvent]Keymap{
+		KeyCtrl('X'): {
+			{Key: KeyRune, Rune: 'u'}: ActionUndo,
+			KeyCtrl('U'):              ActionUndo,
+			KeyCtrl('E'):              ActionEditCommandLine,
+			KeyCtrl('C'):              ActionCopyLine,
+			KeyCtrl('V'):              ActionPasteClipboard,
+		},
+	}
+}
+
+// DefaultKeymap returns the standard key bindings
+func DefaultKeymap() Keymap {
+	keymap := Keymap{
+		{Key: KeyEnter}:     ActionAcceptLine,
+		{Key: KeyTab}:       ActionComplete,
+		{Key: KeyShiftTab}:  ActionCompletePrevious,
+		{Key: KeyBackspace}: ActionBackwardDeleteChar,
+		{Key: KeyDelete}:    ActionDeleteChar,
+		{Key: KeyLeft}:      ActionBackwardChar,
+		{Key: KeyRight}:     ActionForwardChar,
+		{Key: KeyCtrlLeft}:  ActionBackwardWord,
+		{Key: KeyCtrlRight}: ActionForwardWord,
+		{Key: KeyAltLeft}:   ActionBackwardWord,
+		{Key: KeyAltRight}:  ActionForwardWord,
+		{Key: KeyHome}:      ActionBeginningOfLine,
+		{Key: KeyEnd}:       ActionEndOfLine,
+		{Key: KeyUp}:        ActionHistoryPrev,
+		{Key: KeyDown}:      ActionHistoryNext,
+		{Key: KeyCtrlUp}:    ActionMenuPrev,
+		{Key: KeyCtrlDown}:  ActionMenuNext,
+		{Key: KeyPageUp}:    ActionMenuPageUp,
+		{Key: KeyPageDown}:  ActionMenuPageDown,
+		{Key: KeyEsc}:       ActionDismiss,
+		KeyCtrl('A'):        ActionBeginningOfLine,
+		KeyCtrl('C'):        ActionInterrupt,
+		KeyCtrl('D'):        ActionDeleteCharOrExit,
+		KeyCtrl('E'):        ActionEndOfLine,
+		KeyCtrl('K'):        ActionKillLine,
+		KeyCtrl('R'):        ActionHistorySearch,
+		KeyCtrl('S'):        ActionHistorySearchForward,
+		KeyCtrl('U'):        ActionBackwardKillLine,
+		KeyCtrl('W'):        ActionBackwardKillWord,
+		KeyCtrl('Y'):        ActionYank,
+		KeyCtrl('_'):        ActionUndo,
+		KeyMeta('b'):        ActionBackwardWord,
+		KeyMeta('d'):        ActionKillWord,
+		KeyMeta('f'):        ActionForwardWord,
+		KeyMeta('y'):        ActionYankPop,
+		KeyMeta('.'):        ActionYankLastArg,
+	}
+	for prefix := range defaultKeySequences() {
+		keymap[prefix] = ActionPrefix
+	}
+	return keymap
+}
+
+// Lookup returns the action bound to key. Printable characters without an
+// explicit binding insert themselves and pasted text is always inserted.
+func (k Keymap) Lookup(key KeyEvent) Action {
+	if key.Key == KeyPaste {
+		return ActionPaste
+	}
+	if action, ok := k[key]; ok {
+		return action
+	}
+	if key.Key == KeyRune {
+		return ActionSelfInsert
+	}
+	return ActionNone
+}
+
+// namedKeys maps the names used in config files to keys without modifiers
+var namedKeys = map[string]Key{
+	"enter":      KeyEnter,
+	"tab":        KeyTab,
+	"shift-tab":  KeyShiftTab,
+	"backspace":  KeyBackspace,
+	"delete":     KeyDelete,
+	"esc":        KeyEsc,
+	"escape":     KeyEsc,
+	"up":         KeyUp,
+	"down":       KeyDown,
+	"left":       KeyLeft,
+	"right":      KeyRight,
+	"ctrl-up":    KeyCtrlUp,
+	"ctrl-down":  KeyCtrlDown,
+	"ctrl-left":  KeyCtrlLeft,
+	"ctrl-right": KeyCtrlRight,
+	"alt-left":   KeyAltLeft,
+	"alt-right":  KeyAltRight,
+	"home":       KeyHome,
+	"end":        KeyEnd,
+	"pageup":     KeyPageUp,
+	"pagedown":   KeyPageDown,
+}
+
+// ParseKeySequence parses space separated key names such as "ctrl-x u"
+func ParseKeySequence(name string) ([]KeyEvent, error) {
+	fields := strings.Fields(name)
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, fmt.Errorf("invalid key sequence %q", name)
+	}
+	keys := make([]KeyEvent, len(fields))
+	for i, field := range fields {
+		key, err := ParseKey(field)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = key
+	}
+	return keys, nil
+}
+
+// ParseKey parses a key name such as "ctrl-r", "alt-f", "up" or "x"
+func ParseKey(name string) (KeyEvent, error) {
+	lower := strings.ToLower(name)
+	if key, ok := namedKeys[lower]; ok {
+		return KeyEvent{Key: key}, nil
+	}
+
+	// Modifier followed by a single character
+	for prefix, build := range map[string]func(rune) KeyEvent{
+		"ctrl-": KeyCtrl,
+		"c-":    KeyCtrl,
+		"alt-":  KeyMeta,
+		"meta-": KeyMeta,
+		"m-":    KeyMeta,
+	} {
+		if strings.HasPrefix(lower, prefix) {
+			rest := name[len(prefix):]
+			if utf8.RuneCountInString(rest) != 1 {
+				return KeyEvent{}, fmt.Errorf("invalid key name %q", name)
+			}
+			r, _ := utf8.DecodeRuneInString(rest)
+			return build(r), nil
+		}
+	}
+
+	// A single printable character
+	if utf8.RuneCountInString(name) == 1 {
+		r, _ := utf8.DecodeRuneInString(name)
+		return KeyEvent{Key: KeyRune, Rune: r}, nil
+	}
+
+	return KeyEvent{}, fmt.Errorf("invalid key name %q", name)
+}
+
+// KeyName returns the name key is given in config files, such as "ctrl-r",
+// "alt-f", "up" or "x", the reverse of ParseKey. Keys that can't be bound
+// are named "paste" and "unknown".
+func KeyName(key KeyEvent) string {
+	switch key.Key {
+	case KeyRune:
+		return string(key.Rune)
+	case KeyControl:
+		return "ctrl-" + string(unicode.ToLower(key.Rune))
+	case KeyAlt:
+		return "alt-" + string(key.Rune)
+	case KeyPaste:
+		return "paste"
+	}
+	name := ""
+	for n, k := range namedKeys {
+		// Of "esc" and "escape", the shorter
+		if k == key.Key && (name == "" || len(n) < len(name)) {
+			name = n
+		}
+	}
+	if name == "" {
+		return "unknown"
+	}
+	return name
+}
+
+// SetKeyBinding binds key to action, replacing any existing binding
+func (t *Terminal) SetKeyBinding(key KeyEvent, action Action) error {
+	return t.SetKeySequence([]KeyEvent{key}, action)
+}
+
+// SetKeySequence binds a sequence of one or two keys, such as Ctrl+X
+// followed by u, to action. The first key of a two key sequence becomes a
+// prefix key and loses any action it was bound to on its own.
+func (t *Terminal) SetKeySequence(keys []KeyEvent, action Action) error {
+	if !knownActions[action] {
+		return fmt.Errorf("unknown action %q", action)
+	}
+
+	switch len(keys) {
+	case 1:
+		t.keymap[keys[0]] = action
+		delete(t.prefixKeymaps, keys[0])
+	case 2:
+		if t.prefixKeymaps[keys[0]] == nil {
+			t.prefixKeymaps[keys[0]] = Keymap{}
+		}
+		t.keymap[keys[0]] = ActionPrefix
+		t.prefixKeymaps[keys[0]][keys[1]] = action
+	default:
+		return fmt.Errorf("key sequences must have one or two keys")
+	}
+	return nil
+}
+
+// KeyAction returns the action bound to key
+func (t *Terminal) KeyAction(key KeyEvent) Action {
+	return t.keymap.Lookup(key)
+}
+
+// ReadKeyAction reads the next key press and returns it with the action it is
+// bound to. When the key is a prefix such as Ctrl+X, the following key is read
+// as well and the action bound to the whole sequence is returned.
+func (t *Terminal) ReadKeyAction() (KeyEvent, Action, error) {
+	key, err := t.ReadKey()
+	if err != nil {
+		return key, ActionNone, err
+	}
+
+	action := t.keymap.Lookup(key)
+	if action != ActionPrefix {
+		return key, action, nil
+	}
+
+	next, err := t.ReadKey()
+	if err != nil {
+		return next, ActionNone, err
+	}
+	if action, ok := t.prefixKeymaps[key][next]; ok {
+		return next, action, nil
+	}
+	// Unbound sequences do nothing
+	return next, ActionNone, nil
+}
+
+// interruptPollInterval is how often ReadInputAction checks for an
+// interrupt while no key has been pressed
+const interruptPollInterval = 50 * time.Millisecond
+
+// ReadInputAction is like ReadKeyAction, but returns ActionInterrupt if
+// Interrupt is called before a key is pressed, and ActionRedraw if Resize
+// is
+func (t *Terminal) ReadInputAction() (KeyEvent, Action, error) {
+	return t.readInputAction(context.Background())
+}
+
+// readInputAction is ReadInputAction, returning ctx.Err() once ctx is done
+func (t *Terminal) readInputAction(ctx context.Context) (KeyEvent, Action, error) {
+	for {
+		select {
+		case <-t.interrupts:
+			return KeyCtrl('C'), ActionInterrupt, nil
+		case <-t.resizes:
+			return KeyEvent{}, ActionRedraw, nil
+		case <-ctx.Done():
+			return KeyEvent{}, ActionNone, ctx.Err()
+		default:
+		}
+		if t.waitForInput(ctx, interruptPollInterval) {
+			return t.ReadKeyAction()
+		}
+	}
+}
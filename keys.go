@@ -0,0 +1,318 @@
+package goterm
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// escapeTimeout is how long to wait after an ESC byte for the rest of an
+// escape sequence before treating it as a lone Escape key press
+const escapeTimeout = 50 * time.Millisecond
+
+// Key identifies the kind of a decoded key press
+type Key int
+
+const (
+	KeyUnknown   Key = iota // Unrecognized input, safe to ignore
+	KeyRune                 // Printable character, see KeyEvent.Rune
+	KeyControl              // Control character, KeyEvent.Rune holds the letter (Ctrl+R is 'R')
+	KeyAlt                  // Alt/Meta combined with a character, see KeyEvent.Rune
+	KeyEnter                // Enter or Return
+	KeyTab                  // Tab
+	KeyBackspace            // Backspace
+	KeyDelete               // Forward delete
+	KeyEsc                  // Escape pressed on its own
+	KeyUp                   // Up arrow
+	KeyDown                 // Down arrow
+	KeyLeft                 // Left arrow
+	KeyRight                // Right arrow
+	KeyCtrlUp               // Ctrl+Up
+	KeyCtrlDown             // Ctrl+Down
+	KeyCtrlLeft             // Ctrl+Left
+	KeyCtrlRight            // Ctrl+Right
+	KeyAltLeft              // Alt+Left
+	KeyAltRight             // Alt+Right
+	KeyHome                 // Home
+	KeyEnd                  // End
+	KeyPageUp               // Page Up
+	KeyPageDown             // Page Down
+	KeyShiftTab             // Shift+Tab
+	KeyPaste                // Bracketed paste, see KeyEvent.Text
+)
+
+// Bracketed paste markers sent by the terminal around pasted text
+const (
+	enableBracketedPaste  = "\033[?2004h"
+	disableBracketedPaste = "\033[?2004l"
+	pasteStart            = "200"
+	pasteEnd              = "\033[201~"
+)
+
+// KeyEvent is a single decoded key press
+type KeyEvent struct {
+	Key  Key
+	Rune rune
+	Text string // Pasted text for KeyPaste
+}
+
+// KeyCtrl returns the event for Ctrl combined with the letter r, e.g. KeyCtrl('R')
+func KeyCtrl(r rune) KeyEvent {
+	return KeyEvent{Key: KeyControl, Rune: unicode.ToUpper(r)}
+}
+
+// KeyMeta returns the event for Alt combined with the character r, e.g. KeyMeta('f')
+func KeyMeta(r rune) KeyEvent {
+	return KeyEvent{Key: KeyAlt, Rune: r}
+}
+
+// KeySource is the input a key decoder reads from
+type KeySource interface {
+	// ReadChar reads a single byte, blocking until one is available
+	ReadChar() (byte, error)
+	// WaitForInput reports whether a byte becomes available within d
+	WaitForInput(d time.Duration) bool
+}
+
+// ReadKey reads and decodes the next key press from the terminal
+func (t *Terminal) ReadKey() (KeyEvent, error) {
+	key, _, err := t.readKeyBytes()
+	return key, err
+}
+
+// ReadKeyContext is ReadKey, returning ctx.Err() if ctx is done before a
+// key is pressed, to within a few milliseconds. A context with a deadline
+// gives up on a key after a while.
+func (t *Terminal) ReadKeyContext(ctx context.Context) (KeyEvent, error) {
+	for !t.waitForInput(ctx, interruptPollInterval) {
+		if err := ctx.Err(); err != nil {
+			return KeyEvent{}, err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return KeyEvent{}, err
+	}
+	return t.ReadKey()
+}
+
+// DecodeKey reads bytes from src until they form a complete key press
+func DecodeKey(src KeySource) (KeyEvent, error) {
+	ch, err := src.ReadChar()
+	if err != nil {
+		return KeyEvent{}, err
+	}
+
+	switch {
+	case ch == '\r' || ch == '\n':
+		return KeyEvent{Key: KeyEnter}, nil
+	case ch == '\t':
+		return KeyEvent{Key: KeyTab}, nil
+	case ch == 127 || ch == 8:
+		return KeyEvent{Key: KeyBackspace}, nil
+	case ch == 27:
+		return decodeEscape(src)
+	case ch < 32:
+		// Ctrl+A is 1, Ctrl+B is 2 and so on
+		return KeyEvent{Key: KeyControl, Rune: rune(ch) + '@'}, nil
+	}
+
+	r, err := finishRune(src, ch)
+	if err != nil {
+		return KeyEvent{}, err
+	}
+	if r == utf8.RuneError || !unicode.IsPrint(r) {
+		return KeyEvent{Key: KeyUnknown}, nil
+	}
+	return KeyEvent{Key: KeyRune, Rune: r}, nil
+}
+
+// decodeEscape decodes the rest of a sequence that started with ESC
+func decodeEscape(src KeySource) (KeyEvent, error) {
+	// A lone Escape key press is not followed by the rest of a sequence
+	if !src.WaitForInput(escapeTimeout) {
+		return KeyEvent{Key: KeyEsc}, nil
+	}
+
+	ch, err := src.ReadChar()
+	if err != nil {
+		return KeyEvent{}, err
+	}
+
+	switch ch {
+	case '[':
+		return decodeCSI(src)
+	case 'O':
+		// SS3 sequences sent by terminals in application cursor mode
+		final, err := src.ReadChar()
+		if err != nil {
+			return KeyEvent{}, err
+		}
+		return cursorKey(final, ""), nil
+	case 'A': // Up arrow in some terminals
+		return KeyEvent{Key: KeyUp}, nil
+	case 'B': // Down arrow in some terminals
+		return KeyEvent{Key: KeyDown}, nil
+	case 27:
+		return KeyEvent{Key: KeyEsc}, nil
+	}
+
+	// Anything else is Alt combined with a character
+	r, err := finishRune(src, ch)
+	if err != nil {
+		return KeyEvent{}, err
+	}
+	return KeyMeta(r), nil
+}
+
+// decodeCSI decodes a control sequence of the form ESC [ params final
+func decodeCSI(src KeySource) (KeyEvent, error) {
+	var params strings.Builder
+	for {
+		ch, err := src.ReadChar()
+		if err != nil {
+			return KeyEvent{}, err
+		}
+		// Parameter and intermediate bytes come before the final byte
+		if ch >= 0x40 && ch <= 0x7E {
+			if ch == '~' && params.String() == pasteStart {
+				return readPaste(src)
+			}
+			if ch == '~' {
+				return tildeKey(params.String()), nil
+			}
+			return cursorKey(ch, params.String()), nil
+		}
+		params.WriteByte(ch)
+	}
+}
+
+// readPaste collects pasted text up to the bracketed paste end marker. The
+// whole payload becomes a single event so that pasted newlines and control
+// characters are never interpreted as key presses.
+func readPaste(src KeySource) (KeyEvent, error) {
+	var text []byte
+	for {
+		ch, err := src.ReadChar()
+		if err != nil {
+			return KeyEvent{}, err
+		}
+		text = append(text, ch)
+		if len(text) >= len(pasteEnd) && string(text[len(text)-len(pasteEnd):]) == pasteEnd {
+			text = text[:len(text)-len(pasteEnd)]
+			return KeyEvent{Key: KeyPaste, Text: strings.ToValidUTF8(string(text), "")}, nil
+		}
+	}
+}
+
+// cursorKey maps the final byte of a cursor key sequence and its parameters,
+// such as "1;5" for Ctrl, to a key event
+func cursorKey(final byte, params string) KeyEvent {
+	// The modifier is the last parameter: ESC [ 1 ; 5 A, or ESC [ 5 A on some terminals.
+	// It is one more than a bit mask of Shift (1), Alt (2) and Ctrl (4), so
+	// Ctrl+Shift+Left, which Windows Terminal sends as ESC [ 1 ; 6 D, is
+	// taken as Ctrl+Left.
+	modifier := params
+	if i := strings.LastIndexByte(params, ';'); i >= 0 {
+		modifier = params[i+1:]
+	}
+	mask, _ := strconv.Atoi(modifier)
+	mask--
+	ctrl := mask > 0 && mask&4 != 0
+	alt := mask > 0 && mask&2 != 0 && !ctrl
+
+	switch final {
+	case 'A':
+		if ctrl {
+			return KeyEvent{Key: KeyCtrlUp}
+		}
+		return KeyEvent{Key: KeyUp}
+	case 'B':
+		if ctrl {
+			return KeyEvent{Key: KeyCtrlDown}
+		}
+		return KeyEvent{Key: KeyDown}
+	case 'C':
+		if ctrl {
+			return KeyEvent{Key: KeyCtrlRight}
+		} else if alt {
+			return KeyEvent{Key: KeyAltRight}
+		}
+		return KeyEvent{Key: KeyRight}
+	case 'D':
+		if ctrl {
+			return KeyEvent{Key: KeyCtrlLeft}
+		} else if alt {
+			return KeyEvent{Key: KeyAltLeft}
+		}
+		return KeyEvent{Key: KeyLeft}
+	case 'H':
+		return KeyEvent{Key: KeyHome}
+	case 'F':
+		return KeyEvent{Key: KeyEnd}
+	case 'Z':
+		return KeyEvent{Key: KeyShiftTab}
+	}
+	return KeyEvent{Key: KeyUnknown}
+}
+
+// tildeKey maps sequences of the form ESC [ n ~ to a key event
+func tildeKey(params string) KeyEvent {
+	// Ignore any modifier after the key number
+	if i := strings.IndexByte(params, ';'); i >= 0 {
+		params = params[:i]
+	}
+
+	switch params {
+	case "1", "7": // 7 on rxvt
+		return KeyEvent{Key: KeyHome}
+	case "3":
+		return KeyEvent{Key: KeyDelete}
+	case "4", "8": // 8 on rxvt
+		return KeyEvent{Key: KeyEnd}
+	case "5":
+		return KeyEvent{Key: KeyPageUp}
+	case "6":
+		return KeyEvent{Key: KeyPageDown}
+	}
+	return KeyEvent{Key: KeyUnknown}
+}
+
+// finishRune reads the continuation bytes of a UTF-8 sequence starting with lead
+// and returns the decoded character. Invalid sequences decode to utf8.RuneError.
+func finishRune(src KeySource, lead byte) (rune, error) {
+	if lead < utf8.RuneSelf {
+		return rune(lead), nil
+	}
+
+	// Determine the sequence length from the lead byte
+	var n int
+	switch {
+	case lead&0xE0 == 0xC0:
+		n = 2
+	case lead&0xF0 == 0xE0:
+		n = 3
+	case lead&0xF8 == 0xF0:
+		n = 4
+	default:
+		return utf8.RuneError, nil
+	}
+
+	buf := []byte{lead}
+	for len(buf) < n {
+		b, err := src.ReadChar()
+		if err != nil {
+			return 0, err
+		}
+		if b&0xC0 != 0x80 {
+			// Not a continuation byte
+			return utf8.RuneError, nil
+		}
+		buf = append(buf, b)
+	}
+
+	r, _ := utf8.DecodeRune(buf)
+	return r, nil
+}
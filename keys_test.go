@@ -0,0 +1,77 @@
+package goterm
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+// byteSource is a KeySource reading from a fixed slice of bytes
+type byteSource struct {
+	data []byte
+}
+
+func (s *byteSource) ReadChar() (byte, error) {
+	if len(s.data) == 0 {
+		return 0, io.EOF
+	}
+	b := s.data[0]
+	s.data = s.data[1:]
+	return b, nil
+}
+
+func (s *byteSource) WaitForInput(time.Duration) bool {
+	return len(s.data) > 0
+}
+
+func TestDecodeKey(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []KeyEvent
+	}{
+		{input: "a", want: []KeyEvent{{Key: KeyRune, Rune: 'a'}}},
+		{input: "é", want: []KeyEvent{{Key: KeyRune, Rune: 'é'}}},
+		{input: "\r", want: []KeyEvent{{Key: KeyEnter}}},
+		{input: "\t", want: []KeyEvent{{Key: KeyTab}}},
+		{input: "\x7f", want: []KeyEvent{{Key: KeyBackspace}}},
+		{input: "\x01", want: []KeyEvent{KeyCtrl('A')}},
+		{input: "\033", want: []KeyEvent{{Key: KeyEsc}}},
+		{input: "\033f", want: []KeyEvent{KeyMeta('f')}},
+		{input: "\033[A", want: []KeyEvent{{Key: KeyUp}}},
+		{input: "\033OB", want: []KeyEvent{{Key: KeyDown}}},
+		{input: "\033[1;5C", want: []KeyEvent{{Key: KeyCtrlRight}}},
+		{input: "\033[1;3D", want: []KeyEvent{{Key: KeyAltLeft}}},
+		{input: "\033[H", want: []KeyEvent{{Key: KeyHome}}},
+		{input: "\033[4~", want: []KeyEvent{{Key: KeyEnd}}},
+		{input: "\033[Z", want: []KeyEvent{{Key: KeyShiftTab}}},
+		{input: "\033[200~a\nb\033[201~", want: []KeyEvent{{Key: KeyPaste, Text: "a\nb"}}},
+
+		// Delete, with and without modifiers, is read whole, leaving
+		// nothing of the sequence to be taken as typed
+		{input: "\033[3~", want: []KeyEvent{{Key: KeyDelete}}},
+		{input: "\033[3;5~", want: []KeyEvent{{Key: KeyDelete}}},
+		{input: "\033[3~x", want: []KeyEvent{{Key: KeyDelete}, {Key: KeyRune, Rune: 'x'}}},
+		{input: "\033[3~\033[3~", want: []KeyEvent{{Key: KeyDelete}, {Key: KeyDelete}}},
+	}
+	for _, tt := range tests {
+		src := &byteSource{data: []byte(tt.input)}
+		var got []KeyEvent
+		for len(src.data) > 0 {
+			key, err := DecodeKey(src)
+			if err != nil {
+				t.Fatalf("DecodeKey(%q) failed: %v", tt.input, err)
+			}
+			got = append(got, key)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("DecodeKey(%q) = %v, want %v", tt.input, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("DecodeKey(%q) = %v, want %v", tt.input, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package goterm
+
+// killRingSize is the number of killed strings kept for yanking
+const killRingSize = 16
+
+// KillRing stores recently killed text, most recent last, for yanking back
+// into the line with Ctrl+Y and cycling through with Alt+Y
+type KillRing struct {
+	entries []string
+	index   int
+}
+
+// Push adds killed text as the most recent entry
+func (k *KillRing) Push(s string) {
+	if s == "" {
+		return
+	}
+	k.entries = append(k.entries, s)
+	if len(k.entries) > killRingSize {
+		k.entries = k.entries[len(k.entries)-killRingSize:]
+	}
+	k.index = len(k.entries) - 1
+}
+
+// Extend merges text killed by consecutive kill commands into the most recent
+// entry, before it when killing backwards and after it otherwise
+func (k *KillRing) Extend(s string, backward bool) {
+	if len(k.entries) == 0 {
+		k.Push(s)
+		return
+	}
+	last := len(k.entries) - 1
+	if backward {
+		k.entries[last] = s + k.entries[last]
+	} else {
+		k.entries[last] += s
+	}
+	k.index = last
+}
+
+// Yank returns the most recent entry and resets the yank-pop position
+func (k *KillRing) Yank() string {
+	if len(k.entries) == 0 {
+		return ""
+	}
+	k.index = len(k.entries) - 1
+	return k.entries[k.index]
+}
+
+// Rotate moves to the next older entry, wrapping around, and returns it
+func (k *KillRing) Rotate() string {
+	if len(k.entries) == 0 {
+		return ""
+	}
+	k.index--
+	if k.index < 0 {
+		k.index = len(k.entries) - 1
+	}
+	return k.entries[k.index]
+}
+
+// Len returns the number of entries in the ring
+func (k *KillRing) Len() int {
+	return len(k.entries)
+}
@@ -0,0 +1,78 @@
+package goterm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// dataDir returns the directory go-term keeps its state in,
+// $XDG_DATA_HOME/go-term or ~/.local/share/go-term
+func dataDir() (string, error) {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(dir, "go-term"), nil
+}
+
+// lastDirPath returns the path of the file the working directory is
+// recorded in for RestoreWorkingDir
+func lastDirPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "last_dir"), nil
+}
+
+// SetRestoreCwd turns on or off recording the working directory each time
+// it changes, for RestoreWorkingDir to go back to in the next session. It
+// is off by default.
+func (t *Terminal) SetRestoreCwd(on bool) {
+	t.restoreCwd = on
+}
+
+// saveLastDir records dir as the working directory to go back to, if that
+// is turned on. Failing to is not worth interrupting a cd for, so errors
+// are ignored.
+func (t *Terminal) saveLastDir(dir string) {
+	if !t.restoreCwd {
+		return
+	}
+	path, err := lastDirPath()
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return
+	}
+	os.WriteFile(path, []byte(dir+"\n"), 0600)
+}
+
+// RestoreWorkingDir changes to the working directory the last session
+// ended in, if SetRestoreCwd has turned that on and the directory still
+// exists, leaving the working directory as it is otherwise. The directory
+// started in becomes OLDPWD, for cd - to go back to.
+func (t *Terminal) RestoreWorkingDir() {
+	if !t.restoreCwd {
+		return
+	}
+	path, err := lastDirPath()
+	if err != nil {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	dir := strings.TrimSpace(string(data))
+	if info, err := os.Stat(dir); dir == "" || err != nil || !info.IsDir() {
+		return
+	}
+	t.chdir("cd", dir)
+}
@@ -0,0 +1,178 @@
+package goterm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// MatchMode selects how typed text is matched against completions and
+// history search results
+type MatchMode int
+
+const (
+	MatchPrefix MatchMode = iota // Completions must start with the typed text
+	MatchFuzzy                   // The typed characters must appear in order
+)
+
+// Scores used to rank fuzzy matches
+const (
+	scoreMatch       = 1    // Each matched character
+	scoreConsecutive = 5    // A match directly after the previous one
+	scoreBoundary    = 8    // A match at the start of a word
+	scoreExactPrefix = 1000 // The whole pattern is a prefix, ranked above any fuzzy match
+)
+
+// ParseMatchMode parses a match mode name, "prefix" or "fuzzy"
+func ParseMatchMode(name string) (MatchMode, error) {
+	switch strings.ToLower(name) {
+	case "prefix":
+		return MatchPrefix, nil
+	case "fuzzy":
+		return MatchFuzzy, nil
+	}
+	return MatchPrefix, fmt.Errorf("unknown match mode %q", name)
+}
+
+// matchModeSetter is implemented by completers that support fuzzy matching
+type matchModeSetter interface {
+	SetMatchMode(mode MatchMode)
+}
+
+// SetMatchMode selects prefix or fuzzy matching for completions and history
+// search. It should be called before input is read.
+func (t *Terminal) SetMatchMode(mode MatchMode) {
+	t.matchMode = mode
+	for _, c := range t.completers {
+		if s, ok := c.(matchModeSetter); ok {
+			s.SetMatchMode(mode)
+		}
+	}
+}
+
+// match reports whether s matches pattern in the given mode, ignoring case.
+// It returns a score for ranking and the rune offsets of the matched
+// characters in s.
+func match(mode MatchMode, pattern, s string) (score int, positions []int, ok bool) {
+	if n, ok := foldedPrefixLen(s, pattern); ok {
+		return scoreExactPrefix, prefixPositions(s[:n]), true
+	}
+	if mode != MatchFuzzy {
+		return 0, nil, false
+	}
+	return fuzzyMatch(pattern, s)
+}
+
+// fuzzyMatch reports whether the characters of pattern appear in s in order,
+// ignoring case. Matches at word boundaries and runs of consecutive
+// characters score higher, and the best scoring placement is chosen.
+func fuzzyMatch(pattern, s string) (score int, positions []int, ok bool) {
+	p := []rune(strings.ToLower(pattern))
+	runes := []rune(s)
+	if len(p) == 0 {
+		return 0, nil, true
+	}
+	if len(p) > len(runes) {
+		return 0, nil, false
+	}
+
+	// best[j][i] is the best score for matching p[:j+1] with p[j] at
+	// runes[i], or -1 if that isn't possible. from[j][i] is where p[j-1]
+	// was matched in that case.
+	const none = -1
+	best := make([][]int, len(p))
+	from := make([][]int, len(p))
+	for j := range p {
+		best[j] = make([]int, len(runes))
+		from[j] = make([]int, len(runes))
+		for i := range runes {
+			best[j][i] = none
+			if unicode.ToLower(runes[i]) != p[j] {
+				continue
+			}
+			bonus := scoreMatch
+			if i == 0 || isWordSeparator(runes[i-1]) ||
+				(unicode.IsUpper(runes[i]) && unicode.IsLower(runes[i-1])) {
+				bonus += scoreBoundary
+			}
+			if j == 0 {
+				best[j][i] = bonus
+				continue
+			}
+			for k := j - 1; k < i; k++ {
+				if best[j-1][k] == none {
+					continue
+				}
+				total := best[j-1][k] + bonus
+				if k == i-1 {
+					total += scoreConsecutive
+				}
+				if total > best[j][i] {
+					best[j][i] = total
+					from[j][i] = k
+				}
+			}
+		}
+	}
+
+	// Pick the best end position and walk back through the matches
+	last := len(p) - 1
+	end := none
+	for i := range runes {
+		if best[last][i] != none && (end == none || best[last][i] > best[last][end]) {
+			end = i
+		}
+	}
+	if end == none {
+		return 0, nil, false
+	}
+	positions = make([]int, len(p))
+	for j, i := last, end; j >= 0; j-- {
+		positions[j] = i
+		i = from[j][i]
+	}
+	return best[last][end], positions, true
+}
+
+// sortByScore orders completions from best to worst match, keeping the
+// existing order between equal scores
+func sortByScore(completions []Completion) {
+	sort.SliceStable(completions, func(i, j int) bool {
+		return completions[i].Score > completions[j].Score
+	})
+}
+
+// boldMatches renders s with the runes at the given offsets in bold
+func boldMatches(s string, positions []int) string {
+	return styleRunes(s, positions, "\033[1m", "\033[22m")
+}
+
+// inverseVideo swaps the foreground and background colors
+const inverseVideo = "\033[7m"
+
+// styleRunes renders s with the runes at the given offsets wrapped in the
+// on and off escape sequences, merging runs of consecutive runes
+func styleRunes(s string, positions []int, on, off string) string {
+	if len(positions) == 0 {
+		return s
+	}
+	matched := make(map[int]bool, len(positions))
+	for _, p := range positions {
+		matched[p] = true
+	}
+
+	var b strings.Builder
+	i := 0
+	for _, r := range s {
+		if matched[i] && !matched[i-1] {
+			b.WriteString(on)
+		}
+		b.WriteRune(r)
+		if matched[i] && !matched[i+1] {
+			b.WriteString(off)
+		}
+		i++
+	}
+	return b.String()
+}
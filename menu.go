@@ -0,0 +1,209 @@
+package goterm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultMenuRows is the number of rows the completion menu uses before it
+// starts paging
+const defaultMenuRows = 6
+
+// Styles for the descriptions shown next to completions
+const (
+	dimText    = "\033[2m"
+	normalText = "\033[22m"
+)
+
+// menuLayout describes how the completion menu was last laid out. Items
+// fill the grid column by column, like ls.
+type menuLayout struct {
+	rows      int // Rows of items on each page
+	cols      int // Columns of items on each page
+	colWidth  int // Width of the labels in each column
+	descWidth int // Width of the descriptions in each column, 0 if there are none
+}
+
+// pageSize returns the number of items shown on each page
+func (l menuLayout) pageSize() int {
+	return l.rows * l.cols
+}
+
+// SetMenuRows sets the number of rows the completion menu shows at once
+func (t *Terminal) SetMenuRows(rows int) {
+	t.menuRows = max(rows, 1)
+}
+
+// MenuActive reports whether the user is navigating the completion menu, in
+// which case the arrow keys move the selection instead of the cursor
+func (t *Terminal) MenuActive() bool {
+	return t.menuActive && len(t.currentSuggestions) > 0
+}
+
+// ActivateMenu makes the arrow keys move the selection in the visible menu
+func (t *Terminal) ActivateMenu() {
+	t.menuActive = len(t.currentSuggestions) > 0
+}
+
+// layoutMenu works out the grid for the current suggestions on a terminal
+// cols wide, using at most maxRows rows of items
+func (t *Terminal) layoutMenu(cols, maxRows int) menuLayout {
+	// Each cell is the selection indicator, the label and a space
+	const cellPadding = 3
+
+	// Descriptions are shown as " (description)" after the label
+	const descPadding = 3
+
+	widest, widestDesc := 0, 0
+	for _, s := range t.currentSuggestions {
+		widest = max(widest, stringWidth(s.Label()))
+		widestDesc = max(widestDesc, stringWidth(s.Description))
+	}
+
+	// Labels take priority, descriptions get whatever room is left
+	l := menuLayout{colWidth: max(min(widest, cols-cellPadding), 1)}
+	if widestDesc > 0 {
+		l.descWidth = min(widestDesc, cols-cellPadding-l.colWidth-descPadding)
+		if l.descWidth <= 0 {
+			l.descWidth = 0
+		}
+	}
+	cellWidth := l.colWidth + cellPadding
+	if l.descWidth > 0 {
+		cellWidth += l.descWidth + descPadding
+	}
+	l.cols = max(cols/cellWidth, 1)
+
+	// Use as few rows as the items need, up to the limit
+	n := len(t.currentSuggestions)
+	l.rows = max(min((n+l.cols-1)/l.cols, t.menuRows, maxRows), 1)
+	return l
+}
+
+// ShowCompletions displays the current completion suggestions in a grid
+// directly below the input, scrolling the screen first if there isn't room.
+// When there are more than fit on one page, the page holding the selected
+// item is shown along with the position of the selection.
+func (t *Terminal) ShowCompletions() error {
+	if len(t.currentSuggestions) == 0 {
+		return t.ClearCompletions()
+	}
+
+	cols, rows, _ := t.Size()
+
+	// Rows left below the input, keeping one for the page indicator
+	maxRows := rows - t.inputRows() - 1
+	if maxRows <= 0 {
+		return t.ClearCompletions()
+	}
+	layout := t.layoutMenu(cols, maxRows)
+	t.menuLayout = layout
+
+	pageSize := layout.pageSize()
+	pageStart := t.selectedIndex / pageSize * pageSize
+	paged := len(t.currentSuggestions) > pageSize
+
+	var menu []string
+	for row := 0; row < layout.rows; row++ {
+		var b strings.Builder
+		for col := 0; col < layout.cols; col++ {
+			i := pageStart + col*layout.rows + row
+			if i >= len(t.currentSuggestions) || i >= pageStart+pageSize {
+				break
+			}
+			b.WriteString(t.menuCell(i, layout) + " ")
+		}
+		menu = append(menu, b.String())
+	}
+
+	// Show which item is selected out of how many
+	if paged {
+		menu = append(menu, fmt.Sprintf("(%d/%d)", t.selectedIndex+1, len(t.currentSuggestions)))
+	}
+
+	t.menu = menu
+	t.staleMenu = false
+	return t.render()
+}
+
+// menuCell renders suggestion i, and its description if the layout has
+// room for them, padded or truncated to fit its column
+func (t *Terminal) menuCell(i int, layout menuLayout) string {
+	suggestion := t.currentSuggestions[i]
+	width := layout.colWidth
+	label := suggestion.Label()
+
+	// Matched characters are shown in bold. Labels that are too long are
+	// cut short here, the full text is still inserted on acceptance.
+	text := boldMatches(label, suggestion.Matched)
+	if stringWidth(label) > width {
+		label = truncateWidth(label, width-1) + "…"
+		text = boldMatches(truncateWidth(suggestion.Label(), width-1), suggestion.Matched) + "…"
+	}
+	text += strings.Repeat(" ", width-stringWidth(label))
+
+	// Items from the history have their own colors, even when selected
+	style := t.colors.Menu
+	if i == t.selectedIndex {
+		style = t.colors.MenuSelected
+	}
+	if suggestion.Kind == CompletionHistory {
+		style = t.colors.MenuHistory
+	}
+
+	// Add arrow indicator for selected item
+	indicator := "  " // Two spaces for non-selected items
+	if i == t.selectedIndex {
+		indicator = "► " // Arrow with space for selected item
+	}
+
+	cell := indicator + style + text + Reset
+
+	// Add the description dimmed, or padding if this item has none
+	if layout.descWidth > 0 {
+		desc := ""
+		if suggestion.Description != "" {
+			desc = "(" + suggestion.Description + ")"
+			if stringWidth(desc) > layout.descWidth+2 {
+				desc = "(" + truncateWidth(suggestion.Description, layout.descWidth-1) + "…)"
+			}
+		}
+		cell += " " + dimText + desc + normalText + strings.Repeat(" ", layout.descWidth+2-stringWidth(desc))
+	}
+	return cell
+}
+
+// MoveSelection moves the menu selection by rows within a column and by
+// cols across columns of the grid, stopping at the first and last items
+func (t *Terminal) MoveSelection(rows, cols int) {
+	n := len(t.currentSuggestions)
+	if n == 0 {
+		return
+	}
+	step := rows + cols*max(t.menuLayout.rows, 1)
+	index := min(max(t.selectedIndex+step, 0), n-1)
+	if index != t.selectedIndex {
+		t.selectedIndex = index
+		t.ShowCompletions()
+	}
+}
+
+// PageCompletions shows the next or previous page of the menu, selecting
+// the first item on it
+func (t *Terminal) PageCompletions(forward bool) {
+	n := len(t.currentSuggestions)
+	pageSize := t.menuLayout.pageSize()
+	if n == 0 || pageSize == 0 || n <= pageSize {
+		return
+	}
+
+	page := t.selectedIndex / pageSize
+	pages := (n + pageSize - 1) / pageSize
+	if forward {
+		page = (page + 1) % pages
+	} else {
+		page = (page - 1 + pages) % pages
+	}
+	t.selectedIndex = page * pageSize
+	t.ShowCompletions()
+}
@@ -0,0 +1,101 @@
+package goterm
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// maxSuggestions is how many commands are suggested for one not found
+const maxSuggestions = 3
+
+// notFoundCommand returns the name of the command err says couldn't be
+// found on PATH, if it says that
+func notFoundCommand(err error) (string, bool) {
+	var execErr *exec.Error
+	if errors.As(err, &execErr) && errors.Is(execErr.Err, exec.ErrNotFound) {
+		return execErr.Name, true
+	}
+	return "", false
+}
+
+// commandNotFound reports that a command isn't on PATH. The functions given
+// to OnCommandNotFound get the first chance to handle it, and if none does,
+// the builtins, aliases and commands on PATH it is closest to are suggested.
+func (t *Terminal) commandNotFound(name string) {
+	for _, hook := range t.notFound {
+		if hook(name) {
+			return
+		}
+	}
+	t.writeError(fmt.Errorf("%s: command not found", name))
+	if suggestions := t.suggestCommands(name); len(suggestions) > 0 {
+		t.WriteLine("Did you mean: " + strings.Join(suggestions, ", ") + "?")
+	}
+}
+
+// suggestCommands returns up to maxSuggestions known commands that name
+// might be a typo of, the closest first
+func (t *Terminal) suggestCommands(name string) []string {
+	candidates, _ := t.commands.candidates()
+
+	// Allow one typo in short names and more in longer ones
+	limit := max(1, len([]rune(name))/3)
+	type suggestion struct {
+		name     string
+		distance int
+	}
+	var found []suggestion
+	for _, c := range candidates {
+		if c == name {
+			continue
+		}
+		if d := editDistance(name, c); d <= limit {
+			found = append(found, suggestion{c, d})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].distance != found[j].distance {
+			return found[i].distance < found[j].distance
+		}
+		return found[i].name < found[j].name
+	})
+
+	var names []string
+	for i := 0; i < len(found) && i < maxSuggestions; i++ {
+		names = append(names, found[i].name)
+	}
+	return names
+}
+
+// editDistance returns the number of single character insertions,
+// deletions, substitutions and swaps of neighbours it takes to turn a into
+// b, so that a swap such as gti for git counts as one typo
+func editDistance(a, b string) int {
+	s, u := []rune(a), []rune(b)
+	// rows[i][j] is the distance between s[:i] and u[:j]
+	rows := make([][]int, len(s)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(u)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(u); j++ {
+			cost := 1
+			if s[i-1] == u[j-1] {
+				cost = 0
+			}
+			d := min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == u[j-2] && s[i-2] == u[j-1] {
+				d = min(d, rows[i-2][j-2]+1)
+			}
+			rows[i][j] = d
+		}
+	}
+	return rows[len(s)][len(u)]
+}
@@ -0,0 +1,154 @@
+package goterm
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// NotifyOptions controls the desktop notifications shown when a long
+// command finishes, so that it can be left to run in another window.
+//
+// A command is notified of whether or not the terminal has focus. Focus
+// reports, CSI ?1004h, are sent as input to the command running, so go-term
+// can't tell whether its window was left while the command ran. Some
+// terminals hold back notifications while they have focus.
+type NotifyOptions struct {
+	// After is how long a command must run for to be notified of. Zero
+	// turns notifications off.
+	After time.Duration
+
+	// Escape notifies with an escape sequence, OSC 9 or OSC 777 on rxvt and
+	// foot, which terminals such as iTerm2, kitty and WezTerm show as a
+	// notification. Otherwise, or on terminals that take no escape
+	// sequences, notify-send is run if it is installed.
+	Escape bool
+
+	// Exclude lists commands never notified of, such as interactive
+	// programs, which run for as long as they are used
+	Exclude []string
+}
+
+// DefaultNotifyOptions returns the options used unless configured
+// otherwise: notifications are off, and full-screen programs such as vim
+// are excluded once they are turned on
+func DefaultNotifyOptions() NotifyOptions {
+	exclude := make([]string, 0, len(fullScreenCommands))
+	for name := range fullScreenCommands {
+		exclude = append(exclude, name)
+	}
+	sort.Strings(exclude)
+	return NotifyOptions{Escape: true, Exclude: exclude}
+}
+
+// SetNotifyOptions changes when and how commands finishing are notified of
+func (t *Terminal) SetNotifyOptions(opts NotifyOptions) {
+	t.notifyOptions = opts
+}
+
+// NotifyOptions returns the options controlling notifications
+func (t *Terminal) NotifyOptions() NotifyOptions {
+	return t.notifyOptions
+}
+
+// notifyFinished is an OnPostExec hook notifying of a command line that
+// ran for longer than NotifyOptions.After. Lines interrupted with Ctrl+C
+// aren't notified of, as someone was there to press it.
+func (t *Terminal) notifyFinished(line string, status int, dur time.Duration) {
+	opts := t.notifyOptions
+	if opts.After <= 0 || dur < opts.After || status == 128+int(syscall.SIGINT) {
+		return
+	}
+	// Commands are excluded by the name of an alias as well as what it runs
+	names := append(lineCommands(line), lineCommands(expandAliases(line, t.Alias, nil))...)
+	for _, name := range names {
+		for _, excluded := range opts.Exclude {
+			if name == excluded {
+				return
+			}
+		}
+	}
+
+	// The line is shortened as for the window title. A control character
+	// would end the sequence early.
+	line, _, more := strings.Cut(strings.TrimSpace(line), "\n")
+	line = SanitizeInput(line)
+	if more || stringWidth(line) > maxTitleWidth {
+		line = truncateWidth(line, maxTitleWidth-1) + "…"
+	}
+	body := fmt.Sprintf("%s finished after %s", line, formatDuration(dur))
+	if status != 0 {
+		body = fmt.Sprintf("%s failed with status %d after %s", line, status, formatDuration(dur))
+	}
+
+	if opts.Escape && t.oscSupported() {
+		t.writer.WriteString(notifySequence("go-term", body))
+		t.flush()
+		return
+	}
+	path, err := exec.LookPath("notify-send")
+	if err != nil {
+		return
+	}
+	cmd := exec.Command(path, "--app-name=go-term", "go-term", body)
+	if cmd.Start() == nil {
+		go cmd.Wait()
+	}
+}
+
+// notifySequence returns the escape sequence showing a notification of
+// body: OSC 777 with a title on the terminals that take that, and OSC 9,
+// which more terminals take, elsewhere
+func notifySequence(title, body string) string {
+	title, body = notifyText(title), notifyText(body)
+	term := os.Getenv("TERM")
+	if strings.HasPrefix(term, "rxvt") || strings.HasPrefix(term, "foot") {
+		return "\033]777;notify;" + title + ";" + body + "\a"
+	}
+	return "\033]9;" + body + "\a"
+}
+
+// notifyText makes s safe to put in a notification sequence. A control
+// character, such as BEL or ESC, or a stray byte a terminal might read as
+// one would end the sequence early, and a ; would start another field, so
+// they are removed and replaced with a comma.
+func notifyText(s string) string {
+	return strings.ReplaceAll(SanitizeInput(strings.ToValidUTF8(s, "")), ";", ",")
+}
+
+// lineCommands returns the names of the commands a line runs. Of a line
+// left to the shell, only the first command can be told, skipping any
+// variables set for it.
+func lineCommands(line string) []string {
+	name := func(word string) string {
+		return strings.TrimSuffix(filepath.Base(word), ".exe")
+	}
+	lists, err := parseCommandLine(line)
+	if err != nil {
+		for _, field := range shellFields(line) {
+			if !assignmentPrefix.MatchString(field) {
+				return []string{name(field)}
+			}
+		}
+		return nil
+	}
+	var names []string
+	for _, list := range lists {
+		for _, p := range list.pipelines {
+			for _, cmd := range p.commands {
+				if len(cmd.words) == 0 {
+					continue
+				}
+				if fields := shellFields(cmd.words[0]); len(fields) > 0 {
+					names = append(names, name(fields[0]))
+				}
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,89 @@
+package goterm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotifySequence(t *testing.T) {
+	tests := []struct {
+		term        string
+		title, body string
+		want        string
+	}{
+		{term: "xterm-256color", title: "go-term", body: "make finished after 12s", want: "\033]9;make finished after 12s\a"},
+		{term: "rxvt-unicode", title: "go-term", body: "make finished after 12s", want: "\033]777;notify;go-term;make finished after 12s\a"},
+		{term: "foot", title: "go-term", body: "日本 finished", want: "\033]777;notify;go-term;日本 finished\a"},
+		// Nothing in the title or body can end the sequence or start
+		// another field
+		{term: "xterm", title: "go-term", body: "a;b\a\033]0;x\033\\", want: "\033]9;a,b]0,x\\\a"},
+		{term: "xterm", title: "go-term", body: "4;1 done", want: "\033]9;4,1 done\a"},
+		{term: "foot", title: "go;term\a", body: "make; make install\x9c\u009c", want: "\033]777;notify;go,term;make, make install\a"},
+	}
+	for _, tt := range tests {
+		t.Setenv("TERM", tt.term)
+		if got := notifySequence(tt.title, tt.body); got != tt.want {
+			t.Errorf("notifySequence(%q, %q) with TERM=%s = %q, want %q", tt.title, tt.body, tt.term, got, tt.want)
+		}
+	}
+}
+
+func TestLineCommands(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{line: "", want: nil},
+		{line: "vim notes.txt", want: []string{"vim"}},
+		{line: "make && ./run | less; top &", want: []string{"make", "run", "less", "top"}},
+		{line: "/usr/bin/htop -d 5", want: []string{"htop"}},
+		{line: `"vim" a`, want: []string{"vim"}},
+		// Operators in quotes or escaped don't split the line
+		{line: "echo 'a; vim' | grep x", want: []string{"echo", "grep"}},
+		{line: `echo a\|vim`, want: []string{"echo"}},
+		{line: "echo a > out.txt", want: []string{"echo"}},
+		// Lines left to the shell give their first command
+		{line: "vim $(git ls-files)", want: []string{"vim"}},
+		{line: "EDITOR=nano FOO=1 vim a", want: []string{"vim"}},
+		{line: "for f in *; do vim $f; done", want: []string{"for"}},
+	}
+	for _, tt := range tests {
+		if got := lineCommands(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("lineCommands(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestNotifyFinished(t *testing.T) {
+	t.Setenv("TERM", "xterm")
+	f := newFake(t)
+	f.SetNotifyOptions(NotifyOptions{After: time.Second, Escape: true, Exclude: []string{"vim"}})
+
+	tests := []struct {
+		line   string
+		status int
+		dur    time.Duration
+		want   string
+	}{
+		{line: "make; make test", dur: 2 * time.Second, want: "\033]9;make, make test finished after 2s\a"},
+		{line: "make", status: 2, dur: 2 * time.Second, want: "\033]9;make failed with status 2 after 2s\a"},
+		{line: "make", dur: 500 * time.Millisecond},
+		{line: "make", status: 130, dur: 2 * time.Second},
+		{line: "git commit && vim notes.txt", dur: 2 * time.Second},
+		// An escaped ; doesn't make what follows a command
+		{line: `echo a\;vim`, dur: 2 * time.Second, want: "\033]9;echo a\\,vim finished after 2s\a"},
+	}
+	for _, tt := range tests {
+		f.Reset()
+		f.notifyFinished(tt.line, tt.status, tt.dur)
+		got := ""
+		if i := strings.Index(f.Output(), "\033]9;"); i >= 0 {
+			got = f.Output()[i:]
+		}
+		if got != tt.want {
+			t.Errorf("notifying of %q = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package goterm
+
+import "fmt"
+
+// Option configures a Terminal as it is created by NewTerminal or
+// NewTerminalWithIO. Options set defaults, which the user's config file can
+// still override.
+type Option func(*Terminal) error
+
+// apply applies opts in order, stopping at the first that fails
+func (t *Terminal) apply(opts []Option) error {
+	for _, opt := range opts {
+		if err := opt(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WithHistoryFile keeps the history in path rather than ~/.go_term_history
+func WithHistoryFile(path string) Option {
+	return func(t *Terminal) error {
+		if path == "" {
+			return fmt.Errorf("history file path is empty")
+		}
+		t.historyFile = path
+		return nil
+	}
+}
+
+// WithHistoryLimit sets the number of history entries kept, 1000 by default
+func WithHistoryLimit(n int) Option {
+	return func(t *Terminal) error {
+		if n < 1 {
+			return fmt.Errorf("history limit must be positive, not %d", n)
+		}
+		t.historyLimit = n
+		return nil
+	}
+}
+
+// WithShell sets the shell lines go-term can't run itself are run with, in
+// place of $SHELL. See SetShell.
+func WithShell(path string) Option {
+	return func(t *Terminal) error {
+		return t.SetShell(path)
+	}
+}
+
+// WithMaxCompletions sets the number of completions the menu shows at once,
+// 6 by default
+func WithMaxCompletions(n int) Option {
+	return func(t *Terminal) error {
+		if n < 1 {
+			return fmt.Errorf("max completions must be positive, not %d", n)
+		}
+		t.SetMenuRows(n)
+		return nil
+	}
+}
+
+// WithColorLevel sets the colors the terminal can show, in place of those
+// detected from the environment. ColorNone draws nothing in color, which the
+// config file can't undo.
+func WithColorLevel(level ColorLevel) Option {
+	return func(t *Terminal) error {
+		t.SetColorLevel(level)
+		return nil
+	}
+}
+
+// WithPrompt has GetPrompt return the prompt rendered by prompt rather than
+// the working directory
+func WithPrompt(prompt func() string) Option {
+	return func(t *Terminal) error {
+		if prompt == nil {
+			return fmt.Errorf("prompt function is nil")
+		}
+		t.prompt = prompt
+		return nil
+	}
+}
@@ -0,0 +1,118 @@
+package goterm
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestOptionsReject(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  Option
+	}{
+		{name: "WithHistoryFile empty", opt: WithHistoryFile("")},
+		{name: "WithHistoryLimit 0", opt: WithHistoryLimit(0)},
+		{name: "WithHistoryLimit -1", opt: WithHistoryLimit(-1)},
+		{name: "WithShell missing", opt: WithShell(filepath.Join(t.TempDir(), "no-such-shell"))},
+		{name: "WithMaxCompletions 0", opt: WithMaxCompletions(0)},
+		{name: "WithPrompt nil", opt: WithPrompt(nil)},
+	}
+	for _, tt := range tests {
+		if f, err := NewFakeTerm(tt.opt); err == nil {
+			f.Close()
+			t.Errorf("%s: NewFakeTerm succeeded", tt.name)
+		}
+		in := strings.NewReader("")
+		if term, err := NewTerminalWithIO(in, io.Discard, WithHistoryFile(filepath.Join(t.TempDir(), "history")), tt.opt); err == nil {
+			term.Close()
+			t.Errorf("%s: NewTerminalWithIO succeeded", tt.name)
+		}
+	}
+}
+
+func TestOptionsApply(t *testing.T) {
+	t.Run("WithHistoryFile", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "history")
+		f := newFake(t, WithHistoryFile(path))
+		if err := f.AddToHistory("ls -l"); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), "ls -l\n") {
+			t.Errorf("history file has %q, want the command added", data)
+		}
+	})
+
+	t.Run("WithHistoryLimit", func(t *testing.T) {
+		f := newFake(t, WithHistoryLimit(3))
+		for i := 0; i < 5; i++ {
+			if err := f.AddToHistory(fmt.Sprintf("echo %d", i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		want := []string{"echo 2", "echo 3", "echo 4"}
+		if got := f.History(); !reflect.DeepEqual(got, want) {
+			t.Errorf("history = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("WithShell", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("the shell is a shell script")
+		}
+		shell := filepath.Join(t.TempDir(), "myshell")
+		if err := os.WriteFile(shell, []byte("#!/bin/sh\necho \"myshell $1 $2\"\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		f := newFake(t, WithShell(shell))
+		if got, err := f.Shell(); err != nil || got != shell {
+			t.Errorf("Shell() = %q, %v, want %q", got, err, shell)
+		}
+		// Lines go-term can't run itself are given to the shell
+		if err := f.Run("for x in a; do :; done"); err != nil {
+			t.Fatal(err)
+		}
+		if want := "myshell -c for x in a; do :; done"; !strings.Contains(f.Output(), want) {
+			t.Errorf("output %q doesn't have %q", f.Output(), want)
+		}
+	})
+
+	t.Run("WithMaxCompletions", func(t *testing.T) {
+		// Names too wide for more than one column
+		dir := t.TempDir()
+		long := strings.Repeat("a", 40)
+		writeExecutables(t, dir, "zq1"+long, "zq2"+long, "zq3"+long, "zq4"+long, "zq5"+long)
+		t.Setenv("PATH", dir)
+
+		f := newFake(t, WithMaxCompletions(2))
+		if !f.OpenCompletions("zq", 2) {
+			t.Fatal("no completions")
+		}
+		if f.menuLayout.cols != 1 || f.menuLayout.rows != 2 {
+			t.Errorf("menu is %d by %d, want 1 by 2", f.menuLayout.cols, f.menuLayout.rows)
+		}
+	})
+
+	t.Run("WithPrompt", func(t *testing.T) {
+		f := newFake(t, WithPrompt(func() string { return "custom> " }))
+		if got, err := f.GetPrompt(); err != nil || got != "custom> " {
+			t.Errorf("GetPrompt() = %q, %v, want %q", got, err, "custom> ")
+		}
+	})
+
+	t.Run("WithColorLevel", func(t *testing.T) {
+		f := newFake(t, WithColorLevel(ColorNone))
+		if got := f.ColorLevel(); got != ColorNone {
+			t.Errorf("ColorLevel() = %v, want ColorNone", got)
+		}
+	})
+}
@@ -0,0 +1,385 @@
+package goterm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// morePrompt is shown in reverse video at the bottom of each page
+const morePrompt = "\033[7m--More--\033[0m"
+
+// Page writes lines to the terminal a screenful at a time. At the end of
+// each screen Space shows the next one, Enter the next line, and q or
+// Escape stops. In line mode they are all written at once.
+func (t *Terminal) Page(lines []string) error {
+	out := t.stdout
+	if t.LineMode() {
+		for _, line := range lines {
+			fmt.Fprintln(out, line)
+		}
+		return nil
+	}
+	_, rows, _ := t.Size()
+	pageSize := max(rows-1, 1)
+
+	shown := 0
+	for shown < len(lines) {
+		for end := min(shown+pageSize, len(lines)); shown < end; shown++ {
+			fmt.Fprintln(out, lines[shown])
+		}
+		if shown == len(lines) {
+			break
+		}
+
+		fmt.Fprint(out, t.styled(morePrompt))
+		key, err := t.ReadKey()
+		fmt.Fprint(out, "\r"+clearToEndLine)
+		if err != nil {
+			return err
+		}
+		switch {
+		case key.Key == KeyEnter:
+			pageSize = 1
+		case key.Key == KeyRune && key.Rune == ' ':
+			pageSize = max(rows-1, 1)
+		case key.Key == KeyEsc, key.Key == KeyRune && key.Rune == 'q', key == KeyCtrl('C'):
+			return nil
+		default:
+			pageSize = 0
+		}
+	}
+	return nil
+}
+
+// maxPagedOutput is the most output kept for paging. What a command writes
+// beyond it is dropped, so that one that never stops can't use up memory.
+const maxPagedOutput = 16 << 20
+
+// fullScreenCommands are programs that draw on the whole screen themselves
+// or page their own output. Their output is never paged, as they need the
+// terminal as their standard output.
+var fullScreenCommands = map[string]bool{
+	"vi": true, "vim": true, "nvim": true, "view": true, "nano": true,
+	"emacs": true, "less": true, "more": true, "most": true, "man": true,
+	"top": true, "htop": true, "btop": true, "watch": true, "ssh": true,
+	"tmux": true, "screen": true, "mc": true, "fzf": true,
+}
+
+// SetOutputPaging turns on or off paging command output that doesn't fit on
+// the screen. Once a command fills the screen the rest of its output is
+// kept, and shown with $PAGER or on the alternate screen when the command
+// exits. Only output going to the terminal is paged, and commands then see
+// a pipe rather than the terminal as their standard output.
+func (t *Terminal) SetOutputPaging(on bool) {
+	t.paging = on
+}
+
+// pageable reports whether the output of a job of stages, run in the
+// foreground as command, is to be paged
+func (t *Terminal) pageable(stages []stage, command string) bool {
+	if !t.paging || t.sourcing > 0 || t.LineMode() {
+		return false
+	}
+	if f, ok := t.cmdOut.(*os.File); !ok || !isTerminal(f.Fd()) {
+		return false
+	}
+	return !t.fullScreenJob(stages, command)
+}
+
+// fullScreenJob reports whether a job of stages, run as command, runs a
+// full-screen program or the pager, which need the terminal as their
+// standard output
+func (t *Terminal) fullScreenJob(stages []stage, command string) bool {
+	names := make([]string, 0, len(stages))
+	for _, s := range stages {
+		names = append(names, s.args[0])
+	}
+	// A line run by the shell is checked command by command
+	for _, part := range strings.Split(command, "|") {
+		if fields := strings.Fields(part); len(fields) > 0 {
+			names = append(names, fields[0])
+		}
+	}
+	for _, name := range names {
+		name = strings.TrimSuffix(filepath.Base(name), ".exe")
+		if fullScreenCommands[name] || name == filepath.Base(t.Getenv("PAGER")) {
+			return true
+		}
+	}
+	return false
+}
+
+// outputPager passes the output of a job to the terminal until it fills the
+// screen, then keeps the rest to be paged once the job is done
+type outputPager struct {
+	mu        sync.Mutex
+	w         io.Writer
+	cols      int
+	rows      int // Rows written before output is kept
+	row, col  int
+	escape    bool         // Within an escape sequence
+	output    bytes.Buffer // Everything written, up to maxPagedOutput
+	shown     int          // Bytes of output written to w
+	full      bool         // The screen is full, so output is being kept
+	truncated bool         // Output past maxPagedOutput was dropped
+	released  bool         // Output is passed on to w as it is written
+}
+
+// newOutputPager returns an outputPager writing to the terminal, leaving a
+// row for the prompt. Output may still be copied to it once the terminal is
+// back in raw mode, so line endings are fixed.
+func (t *Terminal) newOutputPager() *outputPager {
+	cols, rows, _ := t.Size()
+	return &outputPager{w: t.newLineWriter(t.cmdOut), cols: max(cols, 1), rows: max(rows-1, 1)}
+}
+
+func (p *outputPager) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := len(b)
+	if p.released {
+		return p.w.Write(b)
+	}
+	if !p.full {
+		end := p.fits(b)
+		if _, err := p.w.Write(b[:end]); err != nil {
+			return 0, err
+		}
+		p.shown += end
+		p.keep(b[:end])
+		b = b[end:]
+	}
+	p.keep(b)
+	return n, nil
+}
+
+// keep adds b to the output, as far as maxPagedOutput
+func (p *outputPager) keep(b []byte) {
+	if room := maxPagedOutput - p.output.Len(); len(b) > room {
+		b = b[:max(room, 0)]
+		p.truncated = true
+	}
+	p.output.Write(b)
+}
+
+// fits returns how much of b can be written before the screen is full,
+// following the cursor as the terminal would
+func (p *outputPager) fits(b []byte) int {
+	for i := 0; i < len(b); {
+		r, size := utf8.DecodeRune(b[i:])
+		switch {
+		case p.escape:
+			// Escape sequences end with a letter, or BEL for those
+			// setting the title
+			p.escape = !(r >= '@' && r <= '~' && r != '[' && r != ']') && r != '\a'
+		case r == '\033':
+			p.escape = true
+		case r == '\n':
+			// The last row written is left for the prompt
+			if p.row >= p.rows {
+				p.full = true
+				return i
+			}
+			p.row, p.col = p.row+1, 0
+		case r == '\r':
+			p.col = 0
+		case r == '\t':
+			p.col = min(p.col+8-p.col%8, p.cols)
+		case !unicode.IsControl(r):
+			w := runeWidth(r)
+			if p.col+w > p.cols {
+				p.row, p.col = p.row+1, 0
+			}
+			if p.row >= p.rows {
+				p.full = true
+				return i
+			}
+			p.col += w
+		}
+		i += size
+	}
+	return len(b)
+}
+
+// release passes on what has been kept and anything written after, for a
+// job that carries on in the background
+func (p *outputPager) release() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.released = true
+	p.w.Write(p.output.Bytes()[p.shown:])
+}
+
+// pageOutput pages what p kept, with $PAGER if it is set and on the
+// alternate screen otherwise. A nil p, or one that kept nothing, does
+// nothing.
+func (t *Terminal) pageOutput(p *outputPager) error {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.output.Len() == p.shown {
+		return nil
+	}
+	output := p.output.String()
+	if p.truncated {
+		output += fmt.Sprintf("[output past %d MB not kept]\n", maxPagedOutput>>20)
+	}
+	if pager := t.Getenv("PAGER"); pager != "" {
+		return t.runPager(pager, output[p.shown:])
+	}
+	return t.viewOutput(output, p.shown)
+}
+
+// runPager runs pager with the shell, giving it text as its input
+func (t *Terminal) runPager(pager, text string) error {
+	shell, err := t.Shell()
+	if err != nil {
+		return err
+	}
+	args := shellArgs(shell, pager)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = t.Environ()
+	cmd.Stdout, cmd.Stderr = t.cmdOut, t.cmdErr
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	cmd.Stdin = r
+	go func() {
+		io.WriteString(w, text)
+		w.Close()
+	}()
+
+	t.beginExecuting()
+	defer t.endExecuting()
+	if err := t.Suspend(); err != nil {
+		r.Close()
+		return err
+	}
+	tty, hasTTY := t.controllingTerminal()
+	job, err := startJob([]*exec.Cmd{cmd}, pager, tty, hasTTY)
+	r.Close()
+	if err == nil {
+		_, err = t.waitForeground(job, tty, hasTTY)
+	}
+	if resumeErr := t.Resume(); err == nil {
+		err = resumeErr
+	}
+	return err
+}
+
+// viewOutput shows output on the alternate screen, starting at the row
+// holding its byte at start. Space and Page Down show the next page, b and
+// Page Up the one before, the arrow keys, j, k and Enter scroll a line, g
+// and G go to the start and end, and q or Escape quits.
+func (t *Terminal) viewOutput(output string, start int) error {
+	t.writer.WriteString(enterAltScreen)
+	defer func() {
+		t.writer.WriteString(exitAltScreen)
+		t.writer.Flush()
+	}()
+
+	top := -1
+	for {
+		cols, rows, _ := t.Size()
+		lines := screenRows(output, max(cols, 1))
+		page := max(rows-1, 1)
+		if top < 0 {
+			top = len(screenRows(output[:start], max(cols, 1)))
+			if !strings.HasSuffix(output[:start], "\n") {
+				top--
+			}
+		}
+		top = max(min(top, len(lines)-page), 0)
+		t.drawOutputPage(lines, top, page, max(cols, 1))
+
+		key, err := t.ReadKey()
+		if err != nil {
+			return err
+		}
+		switch {
+		case key.Key == KeyEsc, key == KeyCtrl('C'), key.Key == KeyRune && key.Rune == 'q':
+			return nil
+		case key.Key == KeyPageDown, key == KeyCtrl('F'), key.Key == KeyRune && (key.Rune == ' ' || key.Rune == 'f'):
+			top += page
+		case key.Key == KeyPageUp, key == KeyCtrl('B'), key.Key == KeyRune && key.Rune == 'b':
+			top -= page
+		case key.Key == KeyDown, key.Key == KeyEnter, key.Key == KeyRune && key.Rune == 'j':
+			top++
+		case key.Key == KeyUp, key.Key == KeyRune && key.Rune == 'k':
+			top--
+		case key.Key == KeyHome, key.Key == KeyRune && key.Rune == 'g':
+			top = 0
+		case key.Key == KeyEnd, key.Key == KeyRune && key.Rune == 'G':
+			top = len(lines)
+		}
+	}
+}
+
+// drawOutputPage draws the page rows of lines from top, with a status line
+// below them, on a terminal cols wide
+func (t *Terminal) drawOutputPage(lines []string, top, page, cols int) {
+	var b strings.Builder
+	for row := 0; row < page; row++ {
+		// Cleared first, as clearing after a full row would take its last
+		// character with it
+		fmt.Fprintf(&b, "\033[%d;1H%s", row+1, clearToEndLine)
+		if top+row < len(lines) {
+			b.WriteString(lines[top+row])
+		}
+	}
+	end := min(top+page, len(lines))
+	status := fmt.Sprintf(" lines %d-%d of %d (%d%%)", top+1, end, len(lines), end*100/max(len(lines), 1))
+	if end == len(lines) {
+		status += " (END)"
+	}
+	status = truncateWidth(status+"  space: next page  b: back  q: quit ", cols-1)
+	fmt.Fprintf(&b, "\033[%d;1H%s%s%s%s", page+1, inverseVideo, status, resetColor, clearToEndLine)
+	t.writer.writeOutput(t.styled(b.String()))
+	t.writer.Flush()
+}
+
+// screenRows splits text into the rows it takes up on a terminal cols wide,
+// without its escape sequences and control characters and with tabs
+// expanded
+func screenRows(text string, cols int) []string {
+	var rows []string
+	if text == "" {
+		return rows
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		var b strings.Builder
+		col := 0
+		for _, r := range stripEscapes(line) {
+			if r == '\t' {
+				n := min(8-col%8, cols-col)
+				b.WriteString(strings.Repeat(" ", n))
+				col += n
+				continue
+			}
+			if unicode.IsControl(r) {
+				continue
+			}
+			w := runeWidth(r)
+			if col+w > cols {
+				rows = append(rows, b.String())
+				b.Reset()
+				col = 0
+			}
+			b.WriteRune(r)
+			col += w
+		}
+		rows = append(rows, b.String())
+	}
+	return rows
+}
@@ -0,0 +1,352 @@
+package goterm
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// errUnsupportedSyntax is returned by parseCommandLine for lines using shell
+// syntax go-term doesn't run itself, such as loops, subshells and command
+// substitution. They are left to the shell.
+var errUnsupportedSyntax = errors.New("unsupported shell syntax")
+
+// tokenKind identifies the tokens of a command line
+type tokenKind int
+
+const (
+	tokenWord       tokenKind = iota
+	tokenPipe                 // |
+	tokenAnd                  // &&
+	tokenOr                   // ||
+	tokenSeparator            // ; or a line break
+	tokenBackground           // &
+	tokenRedirect             // <, >, >>, <& or >&, with an optional descriptor
+)
+
+// token is a word or operator of a command line
+type token struct {
+	kind       tokenKind
+	text       string // As typed, with any quotes and escapes
+	start, end int    // Rune offsets in the line
+	fd         int    // Descriptor redirected by a tokenRedirect
+}
+
+// shellKeywords start compound commands, which are left to the shell
+var shellKeywords = map[string]bool{
+	"if": true, "then": true, "else": true, "elif": true, "fi": true,
+	"for": true, "while": true, "until": true, "do": true, "done": true,
+	"case": true, "esac": true, "function": true, "select": true,
+	"{": true, "}": true, "!": true, "[[": true, "]]": true,
+}
+
+// shellExpansions are the characters that start expansions go-term leaves
+// to the shell: brace expansion
+const shellExpansions = "{"
+
+// dollarEnd returns the offset just past a $ at runes[i] that go-term
+// expands itself: a $NAME or ${NAME} reference, or a $ on its own. Other uses,
+// such as $(command) and ${NAME:-default}, are left to the shell.
+func dollarEnd(runes []rune, i int) (int, bool) {
+	if _, end, ok := variableAt(runes, i); ok {
+		return end, true
+	}
+	if i+1 == len(runes) || strings.ContainsRune(" \t\n\"", runes[i+1]) {
+		return i + 1, true
+	}
+	return 0, false
+}
+
+// assignmentPrefix matches a NAME=value word before a command
+var assignmentPrefix = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+// lexCommandLine splits a command line into words and operators. Words
+// keep their quotes, which are removed when they are expanded. A line break
+// separates commands like ; unless it is escaped, and # starts a comment.
+func lexCommandLine(line string) ([]token, error) {
+	runes := []rune(line)
+	var tokens []token
+	var word strings.Builder
+	wordStart := -1
+
+	endWord := func(end int) {
+		if wordStart >= 0 {
+			tokens = append(tokens, token{kind: tokenWord, text: word.String(), start: wordStart, end: end})
+			word.Reset()
+			wordStart = -1
+		}
+	}
+	addOperator := func(kind tokenKind, start, end int) {
+		endWord(start)
+		tokens = append(tokens, token{kind: kind, text: string(runes[start:end]), start: start, end: end})
+	}
+	startWord := func(i int) {
+		if wordStart < 0 {
+			wordStart = i
+		}
+	}
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		next := rune(0)
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+
+		switch {
+		case r == '\\':
+			switch next {
+			case 0:
+				return nil, fmt.Errorf("unexpected backslash at end of line")
+			case '\n':
+				// An escaped line break joins the lines
+			default:
+				startWord(i)
+				word.WriteString(string(runes[i : i+2]))
+			}
+			i++
+		case r == '\'' || r == '"':
+			startWord(i)
+			end := i + 1
+			for ; end < len(runes) && runes[end] != r; end++ {
+				switch {
+				case r != '"':
+				case runes[end] == '\\':
+					end++
+				case runes[end] == '`':
+					return nil, errUnsupportedSyntax
+				case runes[end] == '$':
+					next, ok := dollarEnd(runes, end)
+					if !ok {
+						return nil, errUnsupportedSyntax
+					}
+					end = next - 1
+				}
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("unterminated %c quote", r)
+			}
+			word.WriteString(string(runes[i : end+1]))
+			i = end
+		case r == '$':
+			end, ok := dollarEnd(runes, i)
+			if !ok {
+				return nil, errUnsupportedSyntax
+			}
+			startWord(i)
+			word.WriteString(string(runes[i:end]))
+			i = end - 1
+		case r == '`', strings.ContainsRune(shellExpansions, r):
+			return nil, errUnsupportedSyntax
+		case r == ' ' || r == '\t':
+			endWord(i)
+		case r == '#' && wordStart < 0:
+			for i+1 < len(runes) && runes[i+1] != '\n' {
+				i++
+			}
+		case r == '|' && next == '|':
+			addOperator(tokenOr, i, i+2)
+			i++
+		case r == '|':
+			addOperator(tokenPipe, i, i+1)
+		case r == '<' || r == '>':
+			start, fd := i, 1
+			if r == '<' {
+				fd = 0
+			}
+			// A number right before the operator is the descriptor it
+			// redirects, as in 2>
+			if w := word.String(); wordStart >= 0 && isDigits(w) {
+				start = wordStart
+				fd, _ = strconv.Atoi(w)
+				word.Reset()
+				wordStart = -1
+			}
+			endWord(i)
+			switch {
+			case r == '>' && next == '>', next == '&':
+				i++
+			case next == '<', next == '>', next == '|':
+				// Here documents, <> and >| are left to the shell
+				return nil, errUnsupportedSyntax
+			}
+			tokens = append(tokens, token{kind: tokenRedirect, text: string(runes[start : i+1]), start: start, end: i + 1, fd: fd})
+		case r == '&' && next == '>':
+			return nil, errUnsupportedSyntax
+		case r == '&' && next == '&':
+			addOperator(tokenAnd, i, i+2)
+			i++
+		case r == '&':
+			addOperator(tokenBackground, i, i+1)
+		case r == ';' && next == ';':
+			return nil, errUnsupportedSyntax
+		case r == ';' || r == '\n':
+			addOperator(tokenSeparator, i, i+1)
+		case r == '(' || r == ')':
+			return nil, errUnsupportedSyntax
+		default:
+			startWord(i)
+			word.WriteRune(r)
+		}
+	}
+	endWord(len(runes))
+	return tokens, nil
+}
+
+// isDigits reports whether s is a non-empty string of decimal digits
+func isDigits(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+// redirect is a redirection of one of a command's descriptors
+type redirect struct {
+	fd     int
+	op     string // <, >, >>, or <& and >& to duplicate another descriptor
+	target string // File name as typed, or the descriptor to duplicate
+}
+
+// simpleCommand is a command name and its arguments, as typed, with any
+// redirections in the order they appeared
+type simpleCommand struct {
+	words     []string
+	redirects []redirect
+}
+
+// pipeline is one or more commands joined by |
+type pipeline struct {
+	commands []*simpleCommand
+	text     string // As typed, shown in job notices
+}
+
+// andOrList is one or more pipelines joined by && and ||, each run
+// depending on the exit status of the one before
+type andOrList struct {
+	pipelines  []*pipeline
+	ops        []tokenKind // ops[i] joins pipelines[i] and pipelines[i+1]
+	background bool        // Ended with &
+	text       string
+}
+
+// parseCommandLine parses a line of commands separated by ;, & or line
+// breaks. It returns errUnsupportedSyntax if the line uses syntax go-term
+// can't run itself.
+func parseCommandLine(line string) ([]*andOrList, error) {
+	tokens, err := lexCommandLine(line)
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(line)
+	source := func(from, to token) string {
+		return string(runes[from.start:to.end])
+	}
+	syntaxError := func(tok token) error {
+		if tok.text == "\n" {
+			return fmt.Errorf("syntax error near unexpected line break")
+		}
+		return fmt.Errorf("syntax error near unexpected token `%s'", tok.text)
+	}
+	// unexpected returns the syntax error for the token at i, which may be
+	// past the end of the line
+	unexpected := func(i int) error {
+		if i < len(tokens) {
+			return syntaxError(tokens[i])
+		}
+		return fmt.Errorf("syntax error: unexpected end of line")
+	}
+
+	var lists []*andOrList
+	i := 0
+	// skipLineBreaks passes over line breaks, which may follow an operator
+	// that continues the command onto the next line
+	skipLineBreaks := func() {
+		for i < len(tokens) && tokens[i].kind == tokenSeparator && tokens[i].text == "\n" {
+			i++
+		}
+	}
+
+	parsePipeline := func() (*pipeline, error) {
+		p := &pipeline{}
+		first := i
+		for {
+			cmd := &simpleCommand{}
+			for i < len(tokens) && (tokens[i].kind == tokenWord || tokens[i].kind == tokenRedirect) {
+				if tokens[i].kind == tokenWord {
+					cmd.words = append(cmd.words, tokens[i].text)
+					i++
+					continue
+				}
+				tok := tokens[i]
+				if i+1 >= len(tokens) || tokens[i+1].kind != tokenWord {
+					return nil, unexpected(i + 1)
+				}
+				op := strings.TrimLeft(tok.text, "0123456789")
+				target := tokens[i+1].text
+				if strings.HasSuffix(op, "&") && !isDigits(target) {
+					// Closing descriptors and bash's >&file are left to
+					// the shell
+					return nil, errUnsupportedSyntax
+				}
+				cmd.redirects = append(cmd.redirects, redirect{fd: tok.fd, op: op, target: target})
+				i += 2
+			}
+			if len(cmd.words) == 0 {
+				if len(cmd.redirects) > 0 {
+					// Redirections alone, used to create or empty files
+					return nil, errUnsupportedSyntax
+				}
+				return nil, unexpected(i)
+			}
+			if shellKeywords[cmd.words[0]] || assignmentPrefix.MatchString(cmd.words[0]) {
+				return nil, errUnsupportedSyntax
+			}
+			p.commands = append(p.commands, cmd)
+			if i >= len(tokens) || tokens[i].kind != tokenPipe {
+				break
+			}
+			i++
+			skipLineBreaks()
+		}
+		p.text = source(tokens[first], tokens[i-1])
+		return p, nil
+	}
+
+	for i < len(tokens) {
+		if tokens[i].kind == tokenSeparator {
+			i++
+			continue
+		}
+
+		list := &andOrList{}
+		first := i
+		for {
+			p, err := parsePipeline()
+			if err != nil {
+				return nil, err
+			}
+			list.pipelines = append(list.pipelines, p)
+			if i >= len(tokens) || (tokens[i].kind != tokenAnd && tokens[i].kind != tokenOr) {
+				break
+			}
+			list.ops = append(list.ops, tokens[i].kind)
+			i++
+			skipLineBreaks()
+		}
+		list.text = source(tokens[first], tokens[i-1])
+
+		if i < len(tokens) {
+			switch tokens[i].kind {
+			case tokenBackground:
+				list.background = true
+				i++
+			case tokenSeparator:
+				i++
+			default:
+				return nil, syntaxError(tokens[i])
+			}
+		}
+		lists = append(lists, list)
+	}
+	return lists, nil
+}
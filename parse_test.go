@@ -0,0 +1,57 @@
+package goterm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLexCommandLine(t *testing.T) {
+	// Operators are shown as themselves and words in brackets, as typed
+	tests := []struct {
+		line string
+		want []string
+		err  string
+	}{
+		{line: `echo "a|b" 'c;d' e\&f`, want: []string{`[echo]`, `["a|b"]`, `['c;d']`, `[e\&f]`}},
+		{line: `echo "it's" | wc`, want: []string{`[echo]`, `["it's"]`, "|", `[wc]`}},
+		{line: `echo 'say "hi"'; ls`, want: []string{`[echo]`, `['say "hi"']`, ";", `[ls]`}},
+		{line: `echo "" ''`, want: []string{`[echo]`, `[""]`, `['']`}},
+		{line: `echo a""b`, want: []string{`[echo]`, `[a""b]`}},
+		{line: `echo "a\"b" && ls`, want: []string{`[echo]`, `["a\"b"]`, "&&", `[ls]`}},
+		{line: `echo "a\\"b`, want: []string{`[echo]`, `["a\\"b]`}},
+		{line: `echo 'a\' b`, want: []string{`[echo]`, `['a\']`, `[b]`}},
+		{line: `echo "$HOME/a b"`, want: []string{`[echo]`, `["$HOME/a b"]`}},
+		{line: `echo '$(pwd)'`, want: []string{`[echo]`, `['$(pwd)']`}},
+		{line: "echo a\\\nb", want: []string{`[echo]`, `[ab]`}},
+		{line: "echo \"a\nb\"", want: []string{`[echo]`, "[\"a\nb\"]"}},
+		{line: `echo a\`, err: "unexpected backslash at end of line"},
+		{line: `echo "a\"`, err: `unterminated " quote`},
+		{line: `echo 'it"s`, err: "unterminated ' quote"},
+		{line: `echo "$(pwd)"`, err: errUnsupportedSyntax.Error()},
+		{line: "echo \"`pwd`\"", err: errUnsupportedSyntax.Error()},
+	}
+	for _, tt := range tests {
+		tokens, err := lexCommandLine(tt.line)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("lexCommandLine(%q) error = %v, want %q", tt.line, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("lexCommandLine(%q) failed: %v", tt.line, err)
+			continue
+		}
+		got := []string{}
+		for _, tok := range tokens {
+			if tok.kind == tokenWord {
+				got = append(got, "["+tok.text+"]")
+			} else {
+				got = append(got, tok.text)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("lexCommandLine(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package goterm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Sequences switching to and from the terminal's alternate screen, which
+// keeps full-screen views out of the scrollback
+const (
+	enterAltScreen = "\033[?1049h"
+	exitAltScreen  = "\033[?1049l"
+)
+
+// pickerItem is a history entry shown by the picker
+type pickerItem struct {
+	text    string
+	matched []int
+}
+
+// PickFromHistory shows the history full screen, newest first, for the user
+// to choose from. Typing filters the entries with fuzzy matching, the arrow
+// and page keys move the selection, Enter returns the selected command and
+// Escape returns "". The screen is restored afterwards.
+func (t *Terminal) PickFromHistory() (string, error) {
+	commands := newestFirst(t.History())
+
+	t.writer.WriteString(enterAltScreen)
+	defer func() {
+		t.writer.WriteString(exitAltScreen)
+		t.writer.Flush()
+	}()
+
+	query := []rune{}
+	selected, top := 0, 0
+	for {
+		// Best matches first, newest first among equals
+		var items []pickerItem
+		var scored []Completion
+		for _, cmd := range commands {
+			if score, matched, ok := match(MatchFuzzy, string(query), cmd); ok {
+				scored = append(scored, Completion{Text: cmd, Score: score, Matched: matched})
+			}
+		}
+		sortByScore(scored)
+		for _, c := range scored {
+			items = append(items, pickerItem{text: c.Text, matched: c.Matched})
+		}
+
+		cols, rows, _ := t.Size()
+		listRows := max(rows-1, 1)
+		selected = min(max(selected, 0), max(len(items)-1, 0))
+		if selected < top {
+			top = selected
+		} else if selected >= top+listRows {
+			top = selected - listRows + 1
+		}
+		t.drawPicker(items, string(query), len(commands), selected, top, cols, listRows)
+
+		key, err := t.ReadKey()
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case key.Key == KeyEnter:
+			if len(items) == 0 {
+				return "", nil
+			}
+			return items[selected].text, nil
+		case key.Key == KeyEsc, key == KeyCtrl('C'), key == KeyCtrl('G'):
+			return "", nil
+		case key.Key == KeyUp, key == KeyCtrl('P'):
+			selected--
+		case key.Key == KeyDown, key == KeyCtrl('N'):
+			selected++
+		case key.Key == KeyPageUp:
+			selected -= listRows
+		case key.Key == KeyPageDown:
+			selected += listRows
+		case key.Key == KeyBackspace:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				selected, top = 0, 0
+			}
+		case key.Key == KeyRune:
+			query = append(query, key.Rune)
+			selected, top = 0, 0
+		}
+	}
+}
+
+// drawPicker draws the picker's query line followed by the visible part of
+// the list, leaving the cursor after the query
+func (t *Terminal) drawPicker(items []pickerItem, query string, total, selected, top, cols, listRows int) {
+	var b strings.Builder
+	b.WriteString("\033[H\033[2J")
+	for row := 0; row < listRows && top+row < len(items); row++ {
+		i := top + row
+		// Multi-line commands are shown on one line
+		text := truncateWidth(strings.ReplaceAll(items[i].text, "\n", " "), cols-2)
+		text = boldMatches(text, items[i].matched)
+		fmt.Fprintf(&b, "\033[%d;1H", row+2)
+		if i == selected {
+			b.WriteString(inverseVideo + "> " + text + resetColor)
+		} else {
+			b.WriteString("  " + text)
+		}
+	}
+
+	count := fmt.Sprintf(" %d/%d", len(items), total)
+	fmt.Fprintf(&b, "\033[1;1H%s%s%s", dimText, count, normalText)
+	fmt.Fprintf(&b, "\033[1;%dH> %s", len(count)+2, query)
+	t.writer.WriteString(t.styled(b.String()))
+	t.writer.Flush()
+}
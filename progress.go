@@ -0,0 +1,166 @@
+package goterm
+
+import (
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// progressFrames are the frames of the spinner shown while a command is
+// silent, drawn one after the other every progressInterval
+var progressFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+const progressInterval = 100 * time.Millisecond
+
+// SetProgressIndicator sets how long a foreground command must go without
+// writing anything before a spinner and how long it has run for are shown
+// on the row below its output, to show it is still going. They are erased
+// as soon as the command writes again or exits. Zero, the default, turns
+// this off. Commands then write to a pipe rather than the terminal, and
+// full-screen programs such as vim are left alone. Nothing is shown while
+// the command's output is in the middle of a line, as when it has asked a
+// question, but what is typed for a command reading the terminal may be
+// erased along with the spinner.
+func (t *Terminal) SetProgressIndicator(after time.Duration) {
+	t.progressAfter = after
+}
+
+// showsProgress reports whether a job of stages, run in the foreground as
+// command, is to have its progress shown while it is silent
+func (t *Terminal) showsProgress(stages []stage, command string) bool {
+	if t.progressAfter <= 0 || t.sourcing > 0 || t.LineMode() {
+		return false
+	}
+	if f, ok := t.cmdOut.(*os.File); !ok || !isTerminal(f.Fd()) {
+		return false
+	}
+	return !t.fullScreenJob(stages, command)
+}
+
+// progressLine draws a spinner and how long a job has run on the terminal
+// while the job is silent. The job's output is written through it, so that
+// the two don't interleave.
+type progressLine struct {
+	mu    sync.Mutex // Guards everything below, and writing to w
+	w     io.Writer  // The terminal
+	style string
+	after time.Duration
+	start time.Time // When the job started
+	last  time.Time // When the job last wrote, or started
+	// The output of the command line so far doesn't end with a line
+	// break, which outlasts the job
+	midLine *atomic.Bool
+	shown   bool
+	frame   int
+	stop    chan struct{}
+	stopped sync.Once
+	done    chan struct{} // Closed once the spinner has stopped
+}
+
+// newProgressLine returns a progressLine for a job about to start, which
+// draws nothing until begin is called
+func (t *Terminal) newProgressLine() *progressLine {
+	now := time.Now()
+	return &progressLine{
+		w:       t.cmdOut,
+		style:   adaptStyle(t.colors.Suggestion, t.ColorLevel()),
+		after:   t.progressAfter,
+		start:   now,
+		last:    now,
+		midLine: &t.outputMidLine,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// watchProgress returns a writer passing a job's output on to w, erasing
+// p's progress line first if it is shown. Output to the terminal has its
+// line endings fixed, as it may still be copied once raw mode is back.
+func (t *Terminal) watchProgress(p *progressLine, w io.Writer) io.Writer {
+	if _, ok := w.(*os.File); ok {
+		w = t.newLineWriter(w)
+	}
+	return &progressWriter{p: p, w: w}
+}
+
+// begin starts drawing the progress line once the job has been silent for
+// long enough
+func (p *progressLine) begin() {
+	if p == nil {
+		return
+	}
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case now := <-ticker.C:
+				p.tick(now)
+			}
+		}
+	}()
+}
+
+// tick draws the next frame of the spinner, if the job has been silent for
+// long enough
+func (p *progressLine) tick(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.midLine.Load() || now.Sub(p.last) < p.after {
+		return
+	}
+	elapsed := now.Sub(p.start).Truncate(time.Second).String()
+	line := "\r" + p.style + progressFrames[p.frame] + " " + elapsed
+	if p.style != "" {
+		line += resetColor
+	}
+	p.frame = (p.frame + 1) % len(progressFrames)
+	io.WriteString(p.w, line+clearToEndLine)
+	p.shown = true
+}
+
+// erase clears the progress line if it is shown. p.mu must be held.
+func (p *progressLine) erase() {
+	if p.shown {
+		io.WriteString(p.w, "\r"+clearToEndLine)
+		p.shown = false
+	}
+}
+
+// end stops drawing the progress line and erases it. It is called as soon
+// as the job is done, before anything is written after it such as the line
+// break following ^C, and can be called again.
+func (p *progressLine) end() {
+	if p == nil {
+		return
+	}
+	p.stopped.Do(func() { close(p.stop) })
+	<-p.done
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.erase()
+}
+
+// progressWriter passes a job's output on to w, noting when it was written
+type progressWriter struct {
+	p *progressLine
+	w io.Writer
+}
+
+func (pw *progressWriter) Write(b []byte) (int, error) {
+	p := pw.p
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.erase()
+	n, err := pw.w.Write(b)
+	if n > 0 {
+		p.midLine.Store(b[n-1] != '\n')
+	}
+	p.last = time.Now()
+	return n, err
+}
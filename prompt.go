@@ -0,0 +1,230 @@
+package goterm
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// PromptTemplate is a prompt format such as
+// "{cwd_short} {git_branch} {status_symbol}> ", parsed once by
+// ParsePromptTemplate and rendered for each prompt.
+//
+// The variables are:
+//
+//	cwd            the working directory, with ~ for the home directory
+//	cwd_short      its last directories, as in the default prompt
+//	user           the user name
+//	host           the host name, up to the first dot
+//	time           the time, as 15:04
+//	status         the exit status of the last command
+//	status_symbol  ✗ if the last command failed, and nothing otherwise
+//	jobs           the number of background and stopped jobs, if any
+//	git_branch     the git branch of the working directory, if any
+//
+// A color or attribute accepted by ParseColor, such as {green}, {bold} or
+// {bright_blue}, starts a style and {reset} ends it. {{ and }} stand for
+// braces.
+type PromptTemplate struct {
+	parts []promptPart
+}
+
+// promptPart is literal text, including escape sequences for colors, or a
+// variable
+type promptPart struct {
+	text     string
+	variable func(t *Terminal) string
+}
+
+// promptVariables renders each variable a prompt template can use
+var promptVariables = map[string]func(t *Terminal) string{
+	"cwd": func(t *Terminal) string {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return "?"
+		}
+		return abbreviateHome(cwd)
+	},
+	"cwd_short": func(t *Terminal) string {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return "?"
+		}
+		return shortenPath(abbreviateHome(cwd))
+	},
+	"user": func(t *Terminal) string {
+		if u, err := user.Current(); err == nil {
+			return u.Username
+		}
+		return t.Getenv("USER")
+	},
+	"host": func(t *Terminal) string {
+		host, _ := os.Hostname()
+		host, _, _ = strings.Cut(host, ".")
+		return host
+	},
+	"time": func(t *Terminal) string {
+		return time.Now().Format("15:04")
+	},
+	"status": func(t *Terminal) string {
+		return strconv.Itoa(t.lastStatus)
+	},
+	"status_symbol": func(t *Terminal) string {
+		if t.lastStatus != 0 {
+			return "✗"
+		}
+		return ""
+	},
+	"jobs": func(t *Terminal) string {
+		if n := len(t.Jobs()); n > 0 {
+			return strconv.Itoa(n)
+		}
+		return ""
+	},
+	"git_branch": func(t *Terminal) string {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return ""
+		}
+		return gitBranch(cwd)
+	},
+}
+
+// ParsePromptTemplate parses a prompt format. Names in braces that are
+// neither variables nor colors are returned as unknown, and are shown as
+// they were written.
+func ParsePromptTemplate(format string) (*PromptTemplate, []string) {
+	p := &PromptTemplate{}
+	var unknown []string
+	var text strings.Builder
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if (c == '{' || c == '}') && i+1 < len(format) && format[i+1] == c {
+			text.WriteByte(c)
+			i++
+			continue
+		}
+		end := strings.IndexByte(format[i:], '}')
+		if c != '{' || end < 0 {
+			text.WriteByte(c)
+			continue
+		}
+
+		name := format[i+1 : i+end]
+		if variable, ok := promptVariables[name]; ok {
+			p.parts = append(p.parts, promptPart{text: text.String()}, promptPart{variable: variable})
+			text.Reset()
+		} else if style, ok := promptStyle(name); ok {
+			text.WriteString(style)
+		} else {
+			unknown = append(unknown, name)
+			text.WriteString(format[i : i+end+1])
+		}
+		i += end
+	}
+	p.parts = append(p.parts, promptPart{text: text.String()})
+	return p, unknown
+}
+
+// promptStyle returns the escape sequence for a color tag in a prompt
+// template
+func promptStyle(name string) (string, bool) {
+	if name == "reset" {
+		return resetColor, true
+	}
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return "", false
+	}
+	style, err := ParseColor(name)
+	return style, err == nil
+}
+
+// Render renders the prompt for t
+func (p *PromptTemplate) Render(t *Terminal) string {
+	var b strings.Builder
+	for _, part := range p.parts {
+		if part.variable != nil {
+			b.WriteString(part.variable(t))
+		} else {
+			b.WriteString(part.text)
+		}
+	}
+	return b.String()
+}
+
+// SetPromptTemplate has GetPrompt render p rather than the default prompt,
+// or go back to the default if p is nil
+func (t *Terminal) SetPromptTemplate(p *PromptTemplate) {
+	t.promptTemplate = p
+}
+
+// SetTransientPrompt has each line, once entered, redrawn with prompt, such
+// as "❯ ", in place of the prompt it was typed at, so that long prompts
+// don't fill the scrollback. An empty prompt leaves lines as they were
+// typed, as is the default.
+func (t *Terminal) SetTransientPrompt(prompt string) {
+	t.transientPrompt = prompt
+}
+
+// collapsePrompt redraws the line just entered with the transient prompt,
+// if one is set, and without the inline suggestion. The rows the prompt
+// and the input were drawn on are rewritten in place however they wrapped.
+func (t *Terminal) collapsePrompt() {
+	if t.transientPrompt == "" || t.painted == nil {
+		return
+	}
+	if _, rows, _ := t.Size(); len(t.painted.rows) > rows {
+		// The start of the input has scrolled off the screen
+		return
+	}
+	prompt := t.transientPrompt
+	if t.colors.Prompt != "" {
+		prompt = t.colors.Prompt + prompt + resetColor
+	}
+	t.view.prompt = prompt
+	t.view.cursor = utf8.RuneCountInString(t.view.input)
+	t.view.suggest = false
+	t.render()
+}
+
+// gitBranch returns the branch checked out in the git repository holding
+// dir, or the start of the commit's hash if none is. HEAD is read directly
+// rather than running git, as it is read for every prompt.
+func gitBranch(dir string) string {
+	for {
+		gitDir := filepath.Join(dir, ".git")
+		if info, err := os.Stat(gitDir); err == nil {
+			if !info.IsDir() {
+				// A worktree or submodule, whose .git file points to its
+				// git directory
+				data, err := os.ReadFile(gitDir)
+				path, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+				if err != nil || !ok {
+					return ""
+				}
+				if !filepath.IsAbs(path) {
+					path = filepath.Join(dir, path)
+				}
+				gitDir = path
+			}
+			head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+			if err != nil {
+				return ""
+			}
+			ref := strings.TrimSpace(string(head))
+			if branch, ok := strings.CutPrefix(ref, "ref: refs/heads/"); ok {
+				return branch
+			}
+			return ref[:min(len(ref), 7)]
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
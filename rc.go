@@ -0,0 +1,79 @@
+package goterm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxSourceDepth limits how deeply sourced files can source others, so
+// that a file sourcing itself doesn't run forever
+const maxSourceDepth = 32
+
+// RCFilePath returns the path of the startup file run when go-term starts
+func RCFilePath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("could not get config directory: %v", err)
+	}
+	return filepath.Join(configDir, "go-term", "rc"), nil
+}
+
+// Source runs the commands in a file as if they had been typed, without
+// prompts. A line ending in a backslash or inside quotes carries on onto
+// the next, and blank lines and comments are skipped. Errors are reported
+// with the line they come from and don't stop the rest of the file from
+// running, though the exit builtin does, and Source then returns an
+// *ExitError.
+func (t *Terminal) Source(path string) error {
+	if t.sourcing >= maxSourceDepth {
+		return fmt.Errorf("%s: files sourced too deeply", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	t.sourcing++
+	outerLine := t.sourceLine
+	defer func() {
+		t.sourcing--
+		t.sourceLine = outerLine
+	}()
+
+	lines := strings.Split(string(data), "\n")
+	for i := 0; i < len(lines) && !t.exitRequested; i++ {
+		first := i
+		cmd := lines[i]
+		for NeedsContinuation(cmd) && i+1 < len(lines) {
+			i++
+			cmd += "\n" + lines[i]
+		}
+		if trimmed := strings.TrimSpace(cmd); trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		t.sourceLine = fmt.Sprintf("%s:%d", path, first+1)
+		var exitErr *ExitError
+		if err := t.Run(cmd); err != nil && !errors.As(err, &exitErr) {
+			t.writeError(err)
+		}
+	}
+	if t.exitRequested {
+		return &ExitError{Code: t.exitStatus}
+	}
+	return nil
+}
+
+// SourceCommand runs `source file`
+func (t *Terminal) SourceCommand(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: source file")
+	}
+	var exitErr *ExitError
+	if err := t.Source(args[0]); err != nil && !errors.As(err, &exitErr) {
+		return fmt.Errorf("source: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,839 @@
+package goterm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// lineReader holds the state of one call to ReadLine
+type lineReader struct {
+	t      *Terminal
+	prompt string
+	ed     *LineEditor
+
+	// Action of the key handled before the current one, for commands that
+	// depend on it
+	lastAction Action
+	// Length of the text inserted by the last yank, replaced by yank-pop
+	lastYankLen int
+	// History entry the last argument was taken from by yank-last-arg, and
+	// the length of the text it inserted
+	lastArgIndex, lastArgLen int
+}
+
+// ReadLine shows prompt and reads a line of input, handling editing,
+// history navigation and search, inline suggestions and completion until
+// Enter is pressed on a complete command. A command that is incomplete,
+// such as one with an open quote, carries on onto a continuation line.
+//
+// Ctrl+C abandons the line and starts a fresh one. Ctrl+D on an empty line,
+// or the end of the input, returns io.EOF. Pasted text holding several
+// lines is returned one line per call.
+//
+// In line mode the line is read as it is, with none of the editing.
+func (t *Terminal) ReadLine(prompt string) (string, error) {
+	return t.ReadLineContext(context.Background(), prompt)
+}
+
+// ReadLineContext is ReadLine, giving up with ctx.Err() once ctx is done,
+// such as when a signal asks the program to end
+func (t *Terminal) ReadLineContext(ctx context.Context, prompt string) (string, error) {
+	if t.LineMode() {
+		return t.readPlainLine(ctx, prompt)
+	}
+	r := &lineReader{t: t, prompt: prompt, ed: NewLineEditor()}
+
+	// Keys are handled with t.mu held so that completions finishing in the
+	// background only draw between keys. It is released while waiting for
+	// the next key.
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.reportWorkingDir()
+	t.showPromptTitle()
+	if h, ok := t.highlighter.(DefaultHighlighter); ok {
+		// Files may have come and gone since the last line
+		h.paths.reset()
+	}
+	t.painted = nil
+	r.redrawInput()
+
+	// Carry on with the lines of an earlier paste
+	if pending := t.pendingPaste; pending != "" {
+		t.pendingPaste = ""
+		if line, done := r.paste(pending); done {
+			return line, nil
+		}
+	}
+
+	for {
+		t.mu.Unlock()
+		key, action, err := t.readInputAction(ctx)
+		t.mu.Lock()
+
+		if err != nil {
+			// Leave the prompt line cleanly
+			t.CancelCompletions()
+			t.WriteLine("")
+			return "", err
+		}
+
+		// Everything the key changes is drawn, then written in one go
+		t.handlingKey = true
+
+		// Results computed for the input before this key are no longer
+		// wanted, nor is a menu left up while they were computed
+		t.CancelCompletions()
+		if t.staleMenu {
+			t.ClearCompletions()
+		}
+
+		line, done, err := r.handle(key, action)
+		t.handlingKey = false
+		t.writer.Flush()
+		if done {
+			return line, err
+		}
+	}
+}
+
+// LineMode reports whether the terminal reads plain lines rather than key
+// presses, as it does when there is no terminal to edit on or the terminal
+// is dumb. Nothing is drawn in line mode: there are no colors, completion
+// menu or inline suggestions, and the prompt is only shown, without colors,
+// when the lines are typed on a terminal. History and builtins work as
+// usual.
+func (t *Terminal) LineMode() bool {
+	return t.lines != nil
+}
+
+// readPlainLine reads a line in line mode, along with any lines after it
+// that an incomplete command carries on onto
+func (t *Terminal) readPlainLine(ctx context.Context, prompt string) (string, error) {
+	var line string
+	for {
+		if t.linePrompt {
+			t.print(stripEscapes(prompt))
+		}
+		// Lines are scanned in the background so that ctx can end the
+		// wait. A scan abandoned that way is picked up by the next call.
+		if t.scanned == nil {
+			scanned := make(chan bool, 1)
+			go func() { scanned <- t.lines.Scan() }()
+			t.scanned = scanned
+		}
+		var ok bool
+		select {
+		case ok = <-t.scanned:
+			t.scanned = nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+		if !ok {
+			if err := t.lines.Err(); err != nil {
+				return "", err
+			}
+			return "", io.EOF
+		}
+		line += strings.TrimSuffix(t.lines.Text(), "\r")
+		if !NeedsContinuation(line) {
+			return line, nil
+		}
+		line += "\n"
+		prompt = continuationPrompt
+	}
+}
+
+// showSearch shows the search prompt and the result found so far in place
+// of the input
+func (r *lineReader) showSearch() {
+	t := r.t
+	t.view = inputView{
+		prompt: t.GetSearchPrompt(),
+		text:   t.HighlightSearchMatch(r.ed.String()),
+		cursor: r.ed.Len(),
+	}
+	t.render()
+}
+
+// showJobNotices reports background jobs that have finished or stopped,
+// before a fresh prompt is shown
+func (r *lineReader) showJobNotices() {
+	for _, notice := range r.t.JobNotices() {
+		r.t.WriteLine(notice)
+	}
+}
+
+// redrawInput redraws the input with the cursor at its current position
+func (r *lineReader) redrawInput() {
+	if err := r.t.RedrawLine(r.prompt, r.ed); err != nil {
+		fmt.Fprintf(os.Stderr, "Error redrawing line: %v\n", err)
+	}
+}
+
+// historyPrev replaces the input with the previous command in the history
+func (r *lineReader) historyPrev() {
+	if cmd := r.t.GetPreviousHistory(r.ed.String()); cmd != "" {
+		// Replace the input, which may span several lines
+		r.ed.Set(cmd)
+		r.redrawInput()
+
+		// Show inline suggestion
+		r.t.RequestCompletions(cmd, r.ed.Cursor(), true, false)
+	}
+}
+
+// historyNext replaces the input with the next command in the history
+func (r *lineReader) historyNext() {
+	cmd := r.t.GetNextHistory(r.ed.String())
+	r.ed.Set(cmd)
+	r.redrawInput()
+
+	// Show inline suggestion
+	r.t.RequestCompletions(cmd, r.ed.Cursor(), true, false)
+}
+
+// deleteForward deletes the character under the cursor and shifts the rest
+// of the line left
+func (r *lineReader) deleteForward() {
+	if r.ed.Delete() {
+		r.t.ResetCompletions()
+		r.redrawInput()
+	}
+}
+
+// navigateCompletions moves through the completion menu, opening it first
+// if needed
+func (r *lineReader) navigateCompletions(forward bool) {
+	if len(r.t.currentSuggestions) == 0 {
+		r.t.OpenCompletions(r.ed.String(), r.ed.Cursor())
+	}
+
+	if len(r.t.currentSuggestions) > 0 {
+		if forward {
+			r.t.SelectNextCompletion()
+		} else {
+			r.t.SelectPreviousCompletion()
+		}
+		r.t.ActivateMenu()
+	}
+}
+
+// refreshCompletions shows the completions for the input straight away,
+// without waiting for the background request
+func (r *lineReader) refreshCompletions() {
+	r.t.ResetCompletions()
+	r.t.OpenCompletions(r.ed.String(), r.ed.Cursor())
+}
+
+// insertCompletion inserts a completion in place of the text it completes.
+// Completed commands and files are followed by a space, directories and
+// history entries are left open.
+func (r *lineReader) insertCompletion(c Completion) {
+	text := c.Text
+	if c.Kind != CompletionHistory && !strings.HasSuffix(text, "/") {
+		text += " "
+	}
+	r.ed.ReplaceBeforeCursor(c.Replace, text)
+	r.redrawInput()
+	r.refreshCompletions()
+}
+
+// complete completes the word before the cursor on Tab. Like bash, the text
+// shared by every candidate is inserted first and the menu is only opened
+// when there is nothing more to insert. Pressing Tab again moves through
+// the menu.
+func (r *lineReader) complete() {
+	t := r.t
+	if len(t.currentSuggestions) == 0 {
+		if !t.OpenCompletions(r.ed.String(), r.ed.Cursor()) {
+			return
+		}
+		if t.TabAcceptsFirst() {
+			// The first Tab only opens the menu
+			t.ActivateMenu()
+			return
+		}
+	}
+
+	if t.TabAcceptsFirst() {
+		// Accept the selected completion and keep the menu open for the
+		// next word
+		if selected, ok := t.GetSelectedCompletion(); ok {
+			r.insertCompletion(selected)
+			t.ActivateMenu()
+		}
+		return
+	}
+
+	switch {
+	case t.MenuActive():
+		t.SelectNextCompletion()
+	case len(t.currentSuggestions) == 1:
+		r.insertCompletion(t.currentSuggestions[0])
+	default:
+		if text, replace, ok := CommonCompletionPrefix(t.currentSuggestions); ok {
+			r.ed.ReplaceBeforeCursor(replace, text)
+			r.redrawInput()
+			r.refreshCompletions()
+			return
+		}
+		t.ActivateMenu()
+	}
+}
+
+// moveWord moves the cursor by word and updates the display
+func (r *lineReader) moveWord(forward bool) {
+	if forward {
+		r.ed.MoveWordRight()
+	} else {
+		r.ed.MoveWordLeft()
+	}
+	r.redrawInput()
+}
+
+// updateSuggestions refreshes the dropdown and inline suggestion for the
+// current input. The completions are computed in the background and shown
+// once ready, so typing is never held up.
+func (r *lineReader) updateSuggestions() {
+	// Forget the completions for the old input, but leave the menu showing
+	// until those for the new input replace it, rather than clear it in
+	// between
+	t := r.t
+	t.currentSuggestions = nil
+	t.selectedIndex = 0
+	t.menuActive = false
+	t.staleMenu = t.menu != nil
+
+	t.RequestCompletions(r.ed.String(), r.ed.Cursor(), r.ed.AtEnd(), true)
+}
+
+// acceptSuggestion accepts the inline suggestion, or just its next word,
+// when the cursor is at the end of the input. It returns false if there was
+// nothing to accept.
+func (r *lineReader) acceptSuggestion(wordOnly bool) bool {
+	if !r.ed.AtEnd() {
+		return false
+	}
+	rest := r.t.SuggestionSuffix(r.ed.String())
+	if rest == "" {
+		return false
+	}
+
+	if wordOnly {
+		r.ed.InsertString(NextWord(rest))
+	} else {
+		r.ed.Set(r.t.AcceptSuggestion())
+	}
+	r.redrawInput()
+	r.updateSuggestions()
+	return true
+}
+
+// acceptLine finishes the input on Enter, reporting whether it is complete.
+// An incomplete command carries on onto a continuation line instead.
+func (r *lineReader) acceptLine() (string, bool) {
+	// Clear any dropdown completion menu, going by what is on the screen,
+	// so that none is left to be mixed in with the command's output
+	r.t.ResetCompletions()
+
+	// Keep reading on a continuation line while the command is incomplete
+	if NeedsContinuation(r.ed.String()) {
+		r.ed.MoveCursorToEnd()
+		r.ed.Insert('\n')
+		r.redrawInput()
+		return "", false
+	}
+
+	r.t.CancelCompletions()
+	r.t.collapsePrompt()
+	r.t.EndInput()
+	r.t.WriteLine("") // New line after command
+
+	// Reset history index when executing a command
+	r.t.ResetHistoryIndex()
+	return r.ed.String(), true
+}
+
+// redraw draws the prompt, the input and any completions again from
+// scratch, as after the terminal is resized. Whatever was drawn below the
+// start of the input is erased first, as a resize can leave the menu and
+// the inline suggestion anywhere.
+func (r *lineReader) redraw() {
+	t := r.t
+	if t.painted != nil && t.painted.row > 0 {
+		t.print(fmt.Sprintf("\033[%dA", t.painted.row))
+	}
+	t.painted, t.menu = nil, nil
+
+	if t.IsInSearchMode() {
+		r.showSearch()
+		return
+	}
+	r.redrawInput()
+	if t.MenuActive() {
+		// Keep the selection the user is on
+		t.ShowCompletions()
+	} else {
+		r.updateSuggestions()
+	}
+}
+
+// cancelLine abandons the line being typed and starts a fresh one, as
+// Ctrl+C does in other shells
+func (r *lineReader) cancelLine() {
+	if r.t.IsInSearchMode() {
+		r.t.ExitHistorySearch()
+	} else {
+		r.ed.MoveCursorToEnd()
+		r.redrawInput()
+	}
+	r.t.HideSuggestions()
+	r.t.EndInput()
+	r.t.print("^C")
+	r.t.WriteLine("")
+	r.t.ResetHistoryIndex()
+	r.ed.Reset()
+	r.showJobNotices()
+	r.redrawInput()
+}
+
+// saveKill stores killed text, merging consecutive kills into one entry
+func (r *lineReader) saveKill(killed string, backward bool, prevAction Action) {
+	if killed == "" {
+		return
+	}
+	switch prevAction {
+	case ActionKillLine, ActionBackwardKillLine, ActionKillWord, ActionBackwardKillWord:
+		r.t.KillRing().Extend(killed, backward)
+	default:
+		r.t.KillRing().Push(killed)
+	}
+	r.t.ResetCompletions()
+	r.redrawInput()
+}
+
+// paste inserts pasted text. Each complete line is finished as if it had
+// been typed and followed by Enter, so the first is returned with done set
+// and the rest are kept for the next call to ReadLine. Text after the last
+// line break stays in the editor.
+func (r *lineReader) paste(text string) (line string, done bool) {
+	text = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(text)
+	for {
+		first, rest, ok := strings.Cut(text, "\n")
+		if !ok {
+			break
+		}
+		r.ed.InsertString(SanitizeInput(first))
+		r.redrawInput()
+		if line, done := r.acceptLine(); done {
+			r.t.pendingPaste = rest
+			return line, true
+		}
+		text = rest
+	}
+	r.ed.InsertString(SanitizeInput(text))
+	r.t.ResetCompletions()
+	r.redrawInput()
+	return "", false
+}
+
+// handle acts on a key press, returning the finished line with done set
+// once there is one
+func (r *lineReader) handle(key KeyEvent, action Action) (line string, done bool, err error) {
+	t, ed := r.t, r.ed
+
+	// Remember the previous action for commands that depend on it
+	prevAction := r.lastAction
+	r.lastAction = action
+
+	if action == ActionInterrupt {
+		r.cancelLine()
+		return "", false, nil
+	}
+	if action == ActionRedraw {
+		r.redraw()
+		return "", false, nil
+	}
+
+	// Handle Ctrl+R and Ctrl+S for search mode. Pressed again during a
+	// search, they move to the next older or newer match.
+	if action == ActionHistorySearch || action == ActionHistorySearchForward {
+		forward := action == ActionHistorySearchForward
+		if !t.IsInSearchMode() {
+			// The menu and the inline suggestion are for the line being
+			// typed, not the search
+			t.CancelCompletions()
+			t.currentSuggestion = ""
+			t.ResetCompletions()
+			t.StartHistorySearch(ed.String(), forward)
+		} else if result, ok := t.StepHistorySearch(forward); ok {
+			ed.Set(result)
+		}
+		r.showSearch()
+		return "", false, nil
+	}
+
+	// Handle input in search mode
+	if t.IsInSearchMode() {
+		return r.handleSearch(key, action)
+	}
+
+	// While navigating the completion menu the arrow keys move the
+	// selection around the grid
+	if t.MenuActive() {
+		switch action {
+		case ActionAcceptLine:
+			// Enter takes the selected completion rather than finishing
+			// the line
+			if selected, ok := t.GetSelectedCompletion(); ok {
+				r.insertCompletion(selected)
+			}
+			return "", false, nil
+		case ActionHistoryPrev:
+			t.MoveSelection(-1, 0)
+			return "", false, nil
+		case ActionHistoryNext:
+			t.MoveSelection(1, 0)
+			return "", false, nil
+		case ActionBackwardChar:
+			t.MoveSelection(0, -1)
+			return "", false, nil
+		case ActionForwardChar:
+			t.MoveSelection(0, 1)
+			return "", false, nil
+		}
+	}
+
+	switch action {
+	case ActionHistoryPrev:
+		r.historyPrev()
+
+	case ActionHistoryNext:
+		r.historyNext()
+
+	case ActionMenuPrev:
+		r.navigateCompletions(false)
+
+	case ActionMenuNext:
+		r.navigateCompletions(true)
+
+	case ActionMenuPageUp:
+		t.PageCompletions(false)
+
+	case ActionMenuPageDown:
+		t.PageCompletions(true)
+
+	case ActionBackwardChar:
+		ed.MoveLeft()
+		r.redrawInput()
+
+	case ActionForwardChar:
+		// At the end of the input, accept the inline suggestion instead
+		if r.acceptSuggestion(false) {
+			break
+		}
+		ed.MoveRight()
+		r.redrawInput()
+
+	case ActionBackwardWord:
+		r.moveWord(false)
+
+	case ActionForwardWord:
+		// At the end of the input, accept the next word of the suggestion
+		if r.acceptSuggestion(true) {
+			break
+		}
+		r.moveWord(true)
+
+	case ActionBeginningOfLine:
+		ed.MoveCursorToStart()
+		r.redrawInput()
+
+	case ActionEndOfLine:
+		if r.acceptSuggestion(false) {
+			break
+		}
+		ed.MoveCursorToEnd()
+		r.redrawInput()
+
+	case ActionDeleteChar:
+		r.deleteForward()
+
+	case ActionDeleteCharOrExit:
+		// End the input on an empty line, otherwise delete forward
+		if ed.Len() == 0 {
+			t.ResetCompletions()
+			t.WriteLine("exit")
+			return "", true, io.EOF
+		}
+		r.deleteForward()
+
+	case ActionDismiss:
+		// Dismiss the completion dropdown and inline suggestion
+		t.HideSuggestions()
+
+	case ActionUndo:
+		if ed.Undo() {
+			t.ResetCompletions()
+			r.redrawInput()
+		}
+
+	case ActionRedo:
+		if ed.Redo() {
+			t.ResetCompletions()
+			r.redrawInput()
+		}
+
+	case ActionKillLine:
+		r.saveKill(ed.KillToEnd(), false, prevAction)
+
+	case ActionBackwardKillLine:
+		r.saveKill(ed.KillToStart(), true, prevAction)
+
+	case ActionKillWord:
+		r.saveKill(ed.KillWordForward(), false, prevAction)
+
+	case ActionBackwardKillWord:
+		r.saveKill(ed.KillWordBackward(), true, prevAction)
+
+	case ActionYank:
+		// Right after an undo, Ctrl+Y redoes instead of yanking
+		if (prevAction == ActionUndo || prevAction == ActionRedo) && ed.Redo() {
+			r.lastAction = ActionRedo
+			t.ResetCompletions()
+			r.redrawInput()
+			break
+		}
+		if text := t.KillRing().Yank(); text != "" {
+			ed.InsertString(text)
+			r.lastYankLen = len([]rune(text))
+			t.ResetCompletions()
+			r.redrawInput()
+		}
+
+	case ActionYankPop:
+		// Only valid directly after a yank: replace the yanked text with an
+		// older kill
+		if prevAction != ActionYank && prevAction != ActionYankPop {
+			r.lastAction = ActionNone
+			break
+		}
+		if text := t.KillRing().Rotate(); text != "" {
+			ed.ReplaceBeforeCursor(r.lastYankLen, text)
+			r.lastYankLen = len([]rune(text))
+			r.redrawInput()
+		}
+
+	case ActionHistoryPicker:
+		// Choose a command from the full-screen history list. It isn't
+		// bound by default, but can replace Ctrl+R in the config file.
+		t.ResetCompletions()
+		picked, err := t.PickFromHistory()
+		if err != nil {
+			t.WriteLine(fmt.Sprintf("Error: %v", err))
+		}
+		if picked != "" {
+			ed.Set(picked)
+		}
+		r.redrawInput()
+
+	case ActionYankLastArg:
+		// Insert the last argument of the previous command. Repeating the
+		// key replaces it with the one from the command before.
+		history := t.History()
+		index, replace := len(history), 0
+		if prevAction == ActionYankLastArg {
+			index, replace = r.lastArgIndex, r.lastArgLen
+		}
+		arg := ""
+		for index > 0 && arg == "" {
+			index--
+			arg = LastWord(history[index])
+		}
+		if arg == "" {
+			// Nothing older, so keep what was inserted last time
+			r.lastArgIndex = index
+			break
+		}
+		ed.ReplaceBeforeCursor(replace, arg)
+		r.lastArgIndex, r.lastArgLen = index, len([]rune(arg))
+		t.ResetCompletions()
+		r.redrawInput()
+
+	case ActionEditCommandLine:
+		// Edit the command in $EDITOR, then show it below the old input
+		t.ResetCompletions()
+		t.EndInput()
+		t.WriteLine("")
+		edited, ok, err := t.EditInEditor(ed.String())
+		if err != nil {
+			t.WriteLine(fmt.Sprintf("Error: %v", err))
+		}
+		if ok {
+			ed.Set(edited)
+			ed.MoveCursorToEnd()
+		}
+		r.redrawInput()
+
+	case ActionCopyLine:
+		if err := t.CopyToClipboard(ed.String()); err != nil {
+			t.ResetCompletions()
+			t.EndInput()
+			t.WriteLine(fmt.Sprintf("Error: %v", err))
+			r.redrawInput()
+		}
+
+	case ActionPasteClipboard:
+		text, err := ReadClipboard()
+		if err != nil {
+			t.ResetCompletions()
+			t.EndInput()
+			t.WriteLine(fmt.Sprintf("Error: %v", err))
+			r.redrawInput()
+			break
+		}
+		// Copied text usually ends with a line break, which shouldn't run
+		// the line
+		line, done := r.paste(strings.TrimSuffix(strings.TrimSuffix(text, "\n"), "\r"))
+		return line, done, nil
+
+	case ActionComplete:
+		r.complete()
+
+	case ActionCompletePrevious:
+		// Move back through an open menu, otherwise act like Tab
+		if t.MenuActive() {
+			t.SelectPreviousCompletion()
+		} else {
+			r.complete()
+		}
+
+	case ActionAcceptLine:
+		line, done := r.acceptLine()
+		return line, done, nil
+
+	case ActionPaste:
+		line, done := r.paste(key.Text)
+		return line, done, nil
+
+	case ActionBackwardDeleteChar:
+		// Clear any dropdown completion menu
+		t.ResetCompletions()
+
+		if ed.Backspace() {
+			// Redraw so the rest of the line shifts left and the
+			// highlighting follows the change
+			r.redrawInput()
+			if ed.AtEnd() {
+				// Update inline suggestion
+				t.RequestCompletions(ed.String(), ed.Cursor(), true, false)
+			}
+		}
+
+	case ActionSelfInsert:
+		if !ed.AtEnd() {
+			// Insert in the middle of the line and redraw the rest
+			ed.Insert(key.Rune)
+			t.ResetCompletions()
+			r.redrawInput()
+			break
+		}
+
+		// Redraw rather than echo so the highlighting is updated
+		ed.Insert(key.Rune)
+		r.redrawInput()
+		r.updateSuggestions()
+	}
+	return "", false, nil
+}
+
+// exitSearch leaves history search mode with the command found in the
+// editor, or the line typed before the search if restore is set. Any menu
+// still on the screen is cleared.
+func (r *lineReader) exitSearch(restore bool) {
+	if restore {
+		r.ed.Set(r.t.AbortHistorySearch())
+	} else {
+		r.t.ExitHistorySearch()
+	}
+	r.t.ResetCompletions()
+}
+
+// handleSearch acts on a key press in history search mode
+func (r *lineReader) handleSearch(key KeyEvent, action Action) (line string, done bool, err error) {
+	t, ed := r.t, r.ed
+
+	switch action {
+	case ActionBackwardChar, ActionForwardChar, ActionBeginningOfLine, ActionEndOfLine:
+		// Leave the search to edit the command found, moving the cursor
+		// from the end of it as the key would
+		r.exitSearch(false)
+		ed.MoveCursorToEnd()
+		switch action {
+		case ActionBackwardChar:
+			ed.MoveLeft()
+		case ActionBeginningOfLine:
+			ed.MoveCursorToStart()
+		}
+		r.redrawInput()
+		return "", false, nil
+	}
+
+	switch key.Key {
+	case KeyEsc:
+		// Exit search mode, keeping the command found unless configured to
+		// go back to the original line
+		r.exitSearch(t.HistoryOptions().SearchEscapeRestores)
+		r.redrawInput()
+
+	case KeyControl:
+		// Ctrl+G gives up the search and puts the original line back
+		if key.Rune == 'G' {
+			r.exitSearch(true)
+			r.redrawInput()
+		}
+
+	case KeyEnter:
+		// Exit search mode and finish with the result
+		r.exitSearch(false)
+		line, done := r.acceptLine()
+		return line, done, nil
+
+	case KeyBackspace:
+		if len(t.searchQuery) > 0 {
+			// Update search query, removing the last character
+			query := []rune(t.searchQuery)
+			results := t.UpdateHistorySearch(string(query[:len(query)-1]))
+
+			// Update command buffer if we have results
+			if len(results) > 0 {
+				ed.Set(results[0])
+			}
+
+			// Show new prompt and command
+			r.showSearch()
+		}
+
+	case KeyTab: // Cycle through results
+		if result := t.GetNextSearchResult(); result != "" {
+			ed.Set(result)
+			r.showSearch()
+		}
+
+	case KeyRune:
+		// Update search query
+		results := t.UpdateHistorySearch(t.searchQuery + string(key.Rune))
+
+		// Update command buffer if we have results
+		if len(results) > 0 {
+			ed.Set(results[0])
+		}
+
+		// Show new prompt and command
+		r.showSearch()
+	}
+	return "", false, nil
+}
@@ -0,0 +1,346 @@
+package goterm
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// The input, the inline suggestion and the completion menu are drawn by
+// laying them out as rows of cells and comparing those with the rows drawn
+// last time. Only the cells that changed are written, so that a key press
+// rewrites a character or two rather than every row, which flickers on
+// slow terminals.
+
+// cell is one character on the screen and the colors it is drawn in
+type cell struct {
+	text  string // The character, or an escape sequence other than a color
+	style string // The color sequences in effect, "" for the default
+	width int
+}
+
+// frame is the input area laid out on the screen: the rows of the prompt and
+// the input, followed by those of the completion menu
+type frame struct {
+	rows      [][]cell
+	inputRows int // Rows before the menu
+	row, col  int // Position of the cursor
+}
+
+// inputView is what is shown as the input: the prompt, then text after it
+type inputView struct {
+	prompt string
+	text   string // The text as shown, colored, with \n between its lines
+	input  string // The text as typed, which the inline suggestion must extend
+	cursor int    // Position of the cursor in the text, in characters
+	// Whether the inline suggestion is shown, which needs the cursor at the
+	// end of the input
+	suggest bool
+}
+
+// render brings the screen up to date with the input, the inline
+// suggestion and the completion menu, writing only what changed since they
+// were last drawn. Nothing is drawn while a foreground command runs.
+func (t *Terminal) render() error {
+	if t.executing {
+		// A command has the screen
+		return nil
+	}
+	cols, _, _ := t.Size()
+	f := t.layoutFrame(cols)
+	if _, err := t.writer.WriteString(f.paint(t.painted, cols)); err != nil {
+		return err
+	}
+	t.painted = f
+	return t.flush()
+}
+
+// flush writes out what has been drawn. While a key is handled it is left
+// to ReadLine, so that everything the key changes is written at once.
+func (t *Terminal) flush() error {
+	if t.handlingKey {
+		return nil
+	}
+	return t.writer.Flush()
+}
+
+// inputRows returns the number of rows the input was last drawn on
+func (t *Terminal) inputRows() int {
+	if t.painted == nil {
+		return 1
+	}
+	return t.painted.inputRows
+}
+
+// layoutFrame lays out the current input, the inline suggestion and the
+// completion menu on a terminal cols wide
+func (t *Terminal) layoutFrame(cols int) *frame {
+	b := &frameBuilder{cols: max(cols, 1), level: t.ColorLevel()}
+	b.newRow()
+	v := t.view
+
+	// The prompt, then the text up to the cursor and the rest of it
+	b.write(v.prompt)
+	b.style = ""
+	b.continued = true
+	before, after := splitStyled(v.text, v.cursor)
+	b.write(before)
+	b.placeCursor = true
+	b.write(after)
+	if b.placeCursor {
+		// The cursor is at the end. A row filled exactly leaves it at the
+		// start of the next one.
+		if b.col >= b.cols {
+			b.newRow()
+		}
+		b.f.row, b.f.col = len(b.f.rows)-1, b.col
+		b.placeCursor = false
+	}
+
+	// The rest of the inline suggestion, cut short at the end of the row,
+	// and the whole suggestion in a box at the right edge, as long as there
+	// is room for it after the input. The last column is left empty so that
+	// the box doesn't wrap.
+	if rest := t.SuggestionSuffix(v.input); v.suggest && rest != "" {
+		rest, _, _ = strings.Cut(rest, "\n")
+		rest = truncateWidth(rest, b.cols-b.col-1)
+		b.style = adaptStyle(t.colors.Suggestion, b.level)
+		b.write(rest)
+		b.style = ""
+		suggestion, _, _ := strings.Cut(t.currentSuggestion, "\n")
+		if col := b.cols - stringWidth(suggestion) - 3; t.suggestionHint && col > b.col+1 {
+			b.write(strings.Repeat(" ", col-b.col) + "[")
+			b.style = adaptStyle(t.colors.Suggestion, b.level)
+			b.write(suggestion)
+			b.style = ""
+			b.write("]")
+		}
+	}
+	b.f.inputRows = len(b.f.rows)
+
+	// The menu's rows are cut short rather than wrapped
+	b.continued, b.clip = false, true
+	for _, row := range t.menu {
+		b.newRow()
+		b.style = ""
+		b.write(row)
+	}
+	return &b.f
+}
+
+// frameBuilder lays out text in rows of a terminal cols wide, wrapping it at
+// the right edge as the terminal would
+type frameBuilder struct {
+	f     frame
+	cols  int
+	col   int        // Width of the current row so far
+	style string     // Colors in effect
+	level ColorLevel // Colors the terminal can show
+	clip  bool       // Drop characters past the right edge rather than wrap
+	// Follow line breaks with the continuation prompt
+	continued bool
+	// Put the cursor before the next character
+	placeCursor bool
+}
+
+// newRow starts a row
+func (b *frameBuilder) newRow() {
+	b.f.rows = append(b.f.rows, nil)
+	b.col = 0
+}
+
+// write lays out s, which may hold escape sequences. A line break in s
+// starts a row. Colors are changed to those the terminal can show.
+func (b *frameBuilder) write(s string) {
+	for i := 0; i < len(s); {
+		if s[i] == '\033' {
+			n := escapeLen(s[i:])
+			seq := s[i : i+n]
+			i += n
+			switch {
+			case seq == resetColor || seq == "\033[m":
+				b.style = ""
+			case strings.HasPrefix(seq, "\033[") && strings.HasSuffix(seq, "m"):
+				b.style += adaptStyle(seq, b.level)
+			default:
+				b.put(cell{text: seq})
+			}
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		if r == '\n' {
+			if b.placeCursor {
+				// The cursor is at the end of a line, which keeps it on
+				// the row even if the line fills it
+				b.f.row, b.f.col = len(b.f.rows)-1, min(b.col, b.cols-1)
+				b.placeCursor = false
+			}
+			b.newRow()
+			if b.continued {
+				b.continuation()
+			}
+			continue
+		}
+		b.put(cell{text: string(r), style: b.style, width: runeWidth(r)})
+	}
+}
+
+// continuation puts the continuation prompt at the start of a row of text
+func (b *frameBuilder) continuation() {
+	style := b.style
+	b.style = ""
+	for _, r := range continuationPrompt {
+		b.put(cell{text: string(r), width: runeWidth(r)})
+	}
+	b.style = style
+}
+
+// put adds c to the current row, or to a new row if it doesn't fit
+func (b *frameBuilder) put(c cell) {
+	if b.col+c.width > b.cols {
+		if b.clip {
+			return
+		}
+		b.newRow()
+	}
+	if b.placeCursor && c.width > 0 {
+		b.f.row, b.f.col = len(b.f.rows)-1, b.col
+		b.placeCursor = false
+	}
+	row := &b.f.rows[len(b.f.rows)-1]
+	*row = append(*row, c)
+	b.col += c.width
+}
+
+// splitStyled splits the colored text s before its nth character, not
+// counting escape sequences
+func splitStyled(s string, n int) (string, string) {
+	for i := 0; i < len(s); {
+		if s[i] == '\033' {
+			i += escapeLen(s[i:])
+			continue
+		}
+		if n == 0 {
+			return s[:i], s[i:]
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		n--
+	}
+	return s, ""
+}
+
+// paint returns what to write to change the screen from showing old to
+// showing f, leaving the cursor at f's cursor position. Each row is written
+// from the first cell that differs, and rows old had beyond f's are
+// cleared. A nil old means nothing has been drawn yet, with the cursor on
+// the first row of the input.
+func (f *frame) paint(old *frame, cols int) string {
+	var b strings.Builder
+	var oldRows [][]cell
+	row, col := 0, 0
+	if old != nil {
+		oldRows, row, col = old.rows, old.row, old.col
+	} else {
+		b.WriteString("\r" + clearToEndScreen)
+	}
+
+	// moveTo moves the cursor to r and c. Line feeds are used to go down
+	// so that the screen scrolls if the input reaches the bottom.
+	moveTo := func(r, c int) {
+		switch {
+		case r == row && c == col:
+			return
+		case r == row && c < col && col < cols:
+			fmt.Fprintf(&b, "\033[%dD", col-c)
+		case r == row && c > col:
+			fmt.Fprintf(&b, "\033[%dC", c-col)
+		default:
+			// A column of cols means the cursor is at the right edge
+			// waiting to wrap, where it can only be placed from the left
+			if r < row {
+				fmt.Fprintf(&b, "\033[%dA", row-r)
+			} else if r > row {
+				b.WriteString(strings.Repeat("\n", r-row))
+			}
+			b.WriteString("\r")
+			if c > 0 {
+				fmt.Fprintf(&b, "\033[%dC", c)
+			}
+		}
+		row, col = r, c
+	}
+
+	for r, cells := range f.rows {
+		var oldCells []cell
+		if r < len(oldRows) {
+			oldCells = oldRows[r]
+		}
+		k := 0
+		for k < len(cells) && k < len(oldCells) && cells[k] == oldCells[k] {
+			k++
+		}
+		// Start from the character any escape sequences or combining
+		// marks before the change belong with
+		for k > 0 && k < len(cells) && cells[k].width == 0 {
+			k--
+		}
+		if k == len(cells) && k == len(oldCells) {
+			continue
+		}
+
+		// When the row is as wide as before, what follows the change can
+		// be left alone from the point where the rows end the same way,
+		// as for the box holding the inline suggestion
+		end := len(cells)
+		if cellsWidth(cells) == cellsWidth(oldCells) {
+			for n := len(oldCells); end > k && n > k && cells[end-1] == oldCells[n-1]; n-- {
+				end--
+			}
+			for end < len(cells) && cells[end].width == 0 {
+				end++
+			}
+		}
+
+		moveTo(r, cellsWidth(cells[:k]))
+		style := ""
+		for _, c := range cells[k:end] {
+			if c.style != style {
+				// Colors are added to those in effect by writing just the
+				// new sequences
+				if added, ok := strings.CutPrefix(c.style, style); ok {
+					b.WriteString(added)
+				} else {
+					b.WriteString(resetColor + c.style)
+				}
+				style = c.style
+			}
+			b.WriteString(c.text)
+			col += c.width
+		}
+		if style != "" {
+			b.WriteString(resetColor)
+		}
+		if cellsWidth(cells) < cellsWidth(oldCells) {
+			b.WriteString(clearToEndLine)
+		}
+	}
+
+	if len(oldRows) > len(f.rows) {
+		moveTo(len(f.rows), 0)
+		b.WriteString(clearToEndScreen)
+	}
+	moveTo(f.row, f.col)
+	return b.String()
+}
+
+// cellsWidth returns the number of columns cells take up
+func cellsWidth(cells []cell) int {
+	width := 0
+	for _, c := range cells {
+		width += c.width
+	}
+	return width
+}
@@ -0,0 +1,247 @@
+package goterm
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"testing"
+)
+
+// layoutTerm returns a terminal showing prompt and text with the cursor
+// before the cursor'th character of text
+func layoutTerm(prompt, text string, cursor int) *Terminal {
+	t := newTerminal(nil, io.Discard)
+	t.SetColorLevel(ColorTrue)
+	t.view = inputView{prompt: prompt, text: text, input: text, cursor: cursor}
+	return t
+}
+
+func TestStringWidth(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"", 0},
+		{"~> ", 3},
+		{"\033[32m~>\033[0m ", 3},
+		{"\033[1;38;2;255;0;0mred\033[m", 3},
+		{"\033]0;title\a$ ", 2},
+		{"日本", 4},
+		{"\033[32m日本>\033[0m ", 6},
+		{"é", 1},
+		{"🙂", 2},
+	}
+	for _, tt := range tests {
+		if got := stringWidth(tt.s); got != tt.want {
+			t.Errorf("stringWidth(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestLayoutFrameCursor(t *testing.T) {
+	tests := []struct {
+		prompt   string
+		text     string
+		cursor   int
+		cols     int
+		rows     int
+		row, col int
+	}{
+		{prompt: "> ", text: "ls", cursor: 2, cols: 80, rows: 1, row: 0, col: 4},
+		{prompt: "\033[32m~>\033[0m ", text: "ls", cursor: 1, cols: 80, rows: 1, row: 0, col: 4},
+		{prompt: "\033[32m日本>\033[0m ", text: "日本", cursor: 1, cols: 80, rows: 1, row: 0, col: 8},
+		// A wide character that doesn't fit at the end of a row goes on
+		// the next one
+		{prompt: "日本>", text: "日x", cursor: 2, cols: 6, rows: 2, row: 1, col: 3},
+		// Filling a row exactly leaves the cursor at the start of the next
+		{prompt: "> ", text: "abcd", cursor: 4, cols: 6, rows: 2, row: 1, col: 0},
+	}
+	for _, tt := range tests {
+		f := layoutTerm(tt.prompt, tt.text, tt.cursor).layoutFrame(tt.cols)
+		if len(f.rows) != tt.rows || f.row != tt.row || f.col != tt.col {
+			t.Errorf("layout of %q %q at %d in %d columns: %d rows, cursor at %d,%d, want %d rows, cursor at %d,%d",
+				tt.prompt, tt.text, tt.cursor, tt.cols, len(f.rows), f.row, f.col, tt.rows, tt.row, tt.col)
+		}
+	}
+}
+
+func TestPaint(t *testing.T) {
+	tests := []struct {
+		name       string
+		prompt     string
+		beforeDraw bool   // Paint over before rather than from nothing
+		before     string // Text drawn first
+		text       string
+		cursor     int
+		cols       int
+		want       string
+	}{
+		{
+			name:   "colored prompt",
+			prompt: "\033[32m~>\033[0m ",
+			text:   "ls",
+			cursor: 2,
+			cols:   80,
+			want:   "\r\033[J\033[32m~>\033[0m ls",
+		},
+		{
+			name:   "colors added to those in effect",
+			prompt: "> ",
+			text:   "\033[43m\033[1mc\033[22mmd\033[0m x",
+			cursor: 5,
+			cols:   80,
+			want:   "\r\033[J> \033[43m\033[1mc\033[22mmd\033[0m x",
+		},
+		{
+			name:   "wide characters with the cursor moved back",
+			prompt: "\033[32m日本>\033[0m ",
+			text:   "日本",
+			cursor: 1,
+			cols:   80,
+			want:   "\r\033[J\033[32m日本>\033[0m 日本\033[2D",
+		},
+		{
+			name:       "character typed after wide ones",
+			prompt:     "\033[32m日本>\033[0m ",
+			before:     "日",
+			text:       "日x",
+			cursor:     2,
+			cols:       80,
+			want:       "x",
+			beforeDraw: true,
+		},
+		{
+			name:       "character erased after a colored prompt",
+			prompt:     "\033[32m~>\033[0m ",
+			before:     "lss",
+			text:       "ls",
+			cursor:     2,
+			cols:       80,
+			want:       "\033[1D\033[K",
+			beforeDraw: true,
+		},
+		{
+			name:       "wrapped line shortened",
+			prompt:     "> ",
+			before:     "abcdef",
+			text:       "abc",
+			cursor:     3,
+			cols:       6,
+			want:       "\033[1A\r\033[5C\033[K\n\r\033[J\033[1A\r\033[5C",
+			beforeDraw: true,
+		},
+	}
+	for _, tt := range tests {
+		var old *frame
+		if tt.beforeDraw {
+			old = layoutTerm(tt.prompt, tt.before, len([]rune(tt.before))).layoutFrame(tt.cols)
+		}
+		f := layoutTerm(tt.prompt, tt.text, tt.cursor).layoutFrame(tt.cols)
+		if got := f.paint(old, tt.cols); got != tt.want {
+			t.Errorf("%s: paint = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// countingWriter counts the bytes and writes made to it
+type countingWriter struct {
+	bytes, writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.bytes += len(p)
+	w.writes++
+	return len(p), nil
+}
+
+// BenchmarkTypingWithMenu measures what is written to the terminal for each
+// key typed while the completion menu follows the input. The completions
+// are drawn as soon as the key is, as if they had come straight back.
+func BenchmarkTypingWithMenu(b *testing.B) {
+	dir := b.TempDir()
+	for i := 0; i < 200; i++ {
+		writeExecutables(b, dir, fmt.Sprintf("cmd%03d", i))
+	}
+	b.Setenv("PATH", dir)
+	wd, err := os.Getwd()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { os.Chdir(wd) })
+	if err := os.Chdir(dir); err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < 30; i++ {
+		if err := os.WriteFile(fmt.Sprintf("file%02d", i), nil, 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	const line = "cmd01 file1 file2"
+	var out countingWriter
+	t := newTerminal(nil, &out)
+	keys := 0
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := &lineReader{t: t, prompt: "> ", ed: NewLineEditor()}
+		t.painted = nil
+		r.redrawInput()
+		for _, c := range line {
+			// As ReadLine handles a key, then as the completions requested
+			// for it are drawn
+			t.handlingKey = true
+			t.CancelCompletions()
+			if t.staleMenu {
+				t.ClearCompletions()
+			}
+			r.handle(KeyEvent{Rune: c}, ActionSelfInsert)
+			t.CancelCompletions()
+			t.handlingKey = false
+			t.writer.Flush()
+
+			completions := t.GetCompletions(r.ed.String(), r.ed.Cursor())
+			t.currentSuggestions = completions
+			t.selectedIndex = 0
+			t.menuActive = false
+			t.ShowCompletions()
+			t.showInlineSuggestion(r.ed.String(), completions)
+			t.writer.Flush()
+			keys++
+		}
+		t.ClearCompletions()
+		t.writer.Flush()
+	}
+	b.ReportMetric(float64(out.bytes)/float64(keys), "bytes/key")
+	b.ReportMetric(float64(out.writes)/float64(keys), "writes/key")
+}
+
+// BenchmarkMenuRender measures drawing the completion menu as the selection
+// moves through it, with the terminal size kept from the first draw against
+// reading it again for each one, as after the window is resized
+func BenchmarkMenuRender(b *testing.B) {
+	var completions []Completion
+	for i := 0; i < 200; i++ {
+		name := fmt.Sprintf("cmd%03d", i)
+		completions = append(completions, Completion{Text: name, Display: name, Kind: CompletionCommand, Matched: []int{0, 1}})
+	}
+	for _, resize := range []bool{false, true} {
+		name := "cached"
+		if resize {
+			name = "resized"
+		}
+		b.Run(name, func(b *testing.B) {
+			t := layoutTerm("> ", "cm", 2)
+			t.currentSuggestions = completions
+			t.ShowCompletions()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if resize {
+					t.Resize()
+				}
+				t.selectedIndex = i % len(completions)
+				t.ShowCompletions()
+			}
+		})
+	}
+}
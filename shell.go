@@ -0,0 +1,75 @@
+package goterm
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// findShell returns the path of the shell commands are run with: $SHELL,
+// given as shellEnv, if it can be found, otherwise the first of
+// fallbackShells that can be. lookPath finds a program as exec.LookPath
+// does.
+func findShell(shellEnv string, lookPath func(string) (string, error)) (string, error) {
+	if shellEnv != "" {
+		if path, err := lookPath(shellEnv); err == nil {
+			return path, nil
+		}
+	}
+	for _, name := range fallbackShells {
+		if path, err := lookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no shell found: tried $SHELL and %s", strings.Join(fallbackShells, ", "))
+}
+
+// shellArgs returns the command line running line with shell: with /C for
+// cmd, -Command for PowerShell and -c for anything else
+func shellArgs(shell, line string) []string {
+	name := strings.ToLower(filepath.Base(shell))
+	switch strings.TrimSuffix(name, ".exe") {
+	case "cmd":
+		return []string{shell, "/C", line}
+	case "powershell", "pwsh":
+		return []string{shell, "-Command", line}
+	}
+	return []string{shell, "-c", line}
+}
+
+// Shell returns the path of the shell commands are run with, finding it on
+// first use unless it has been set with SetShell
+func (t *Terminal) Shell() (string, error) {
+	if t.shell == "" {
+		path, err := findShell(os.Getenv("SHELL"), exec.LookPath)
+		if err != nil {
+			return "", err
+		}
+		t.shell = path
+	}
+	return t.shell, nil
+}
+
+// SetShell sets the shell commands are run with, given as a path or the
+// name of a program on the PATH. It must accept a command with -c, as
+// POSIX shells and fish do, unless it is cmd or PowerShell.
+func (t *Terminal) SetShell(path string) error {
+	found, err := exec.LookPath(path)
+	if err != nil {
+		return fmt.Errorf("shell %q not found: %v", path, err)
+	}
+	t.shell = found
+	return nil
+}
+
+// shellQuote quotes s as a single argument, if it contains anything that
+// would be treated specially. Quotes are closed around an escaped quote,
+// which go-term, POSIX shells and fish all read the same way.
+func shellQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, shellSpecial+"~#%=") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,67 @@
+package goterm
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFindShell(t *testing.T) {
+	tests := []struct {
+		name      string
+		shellEnv  string
+		installed []string
+		want      string
+		tried     []string
+	}{
+		{
+			name:      "$SHELL found",
+			shellEnv:  "/opt/bin/elvish",
+			installed: append([]string{"/opt/bin/elvish"}, fallbackShells...),
+			want:      "/opt/bin/elvish",
+			tried:     []string{"/opt/bin/elvish"},
+		},
+		{
+			name:      "$SHELL unset",
+			installed: fallbackShells[1:],
+			want:      fallbackShells[1],
+			tried:     fallbackShells[:2],
+		},
+		{
+			name:      "$SHELL missing",
+			shellEnv:  "/opt/bin/elvish",
+			installed: fallbackShells[2:],
+			want:      fallbackShells[2],
+			tried:     append([]string{"/opt/bin/elvish"}, fallbackShells[:3]...),
+		},
+		{
+			name:     "nothing found",
+			shellEnv: "/opt/bin/elvish",
+			tried:    append([]string{"/opt/bin/elvish"}, fallbackShells...),
+		},
+	}
+	for _, tt := range tests {
+		var tried []string
+		lookPath := func(name string) (string, error) {
+			tried = append(tried, name)
+			for _, s := range tt.installed {
+				if s == name {
+					return "/found/" + name, nil
+				}
+			}
+			return "", errors.New("not found")
+		}
+
+		got, err := findShell(tt.shellEnv, lookPath)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("%s: findShell = %q, want an error", tt.name, got)
+			}
+		} else if want := "/found/" + tt.want; err != nil || got != want {
+			t.Errorf("%s: findShell = %q, %v, want %q", tt.name, got, err, want)
+		}
+		if !reflect.DeepEqual(tried, tt.tried) {
+			t.Errorf("%s: findShell tried %q, want %q", tt.name, tried, tt.tried)
+		}
+	}
+}
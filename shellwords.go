@@ -0,0 +1,271 @@
+package goterm
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/user"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// word is a shell word found by splitWords
+type word struct {
+	text       string   // Text with quotes and escapes removed
+	start, end int      // Rune offsets in the line
+	quoted     [][2]int // Rune offsets of quoted regions, including the quotes
+	openQuote  rune     // Quote left unclosed at the end of the line, or 0
+}
+
+// doubleQuoteEscapes lists the characters a backslash escapes inside double
+// quotes. Before anything else the backslash is kept.
+const doubleQuoteEscapes = "$`\"\\\n"
+
+// splitWords splits line into shell words on unquoted whitespace. Unclosed
+// quotes extend to the end of the line.
+func splitWords(line string) []word {
+	var words []word
+	var cur *word
+	var text strings.Builder
+	var quote rune
+	quoteStart := 0
+	escaped := false
+
+	runes := []rune(line)
+	for i, r := range runes {
+		if cur == nil {
+			if unicode.IsSpace(r) {
+				continue
+			}
+			words = append(words, word{start: i})
+			cur = &words[len(words)-1]
+			text.Reset()
+		}
+
+		switch {
+		case escaped:
+			// A backslash before a line break joins the lines
+			if r != '\n' {
+				text.WriteRune(r)
+			}
+			escaped = false
+		case quote != 0:
+			if r == quote {
+				quote = 0
+				cur.quoted = append(cur.quoted, [2]int{quoteStart, i + 1})
+			} else if r == '\\' && quote == '"' && i+1 < len(runes) && strings.ContainsRune(doubleQuoteEscapes, runes[i+1]) {
+				escaped = true
+			} else {
+				text.WriteRune(r)
+			}
+		case r == '\\':
+			escaped = true
+		case r == '\'' || r == '"':
+			quote = r
+			quoteStart = i
+		case unicode.IsSpace(r):
+			cur.end = i
+			cur.text = text.String()
+			cur = nil
+			continue
+		default:
+			text.WriteRune(r)
+		}
+	}
+
+	if cur != nil {
+		if quote != 0 {
+			cur.quoted = append(cur.quoted, [2]int{quoteStart, len(runes)})
+			cur.openQuote = quote
+		}
+		cur.end = len(runes)
+		cur.text = text.String()
+	}
+	return words
+}
+
+// shellFields splits line into words the way the shell would, removing
+// quotes and escapes, so that "My Documents" is a single argument
+func shellFields(line string) []string {
+	words := splitWords(line)
+	fields := make([]string, len(words))
+	for i, w := range words {
+		fields[i] = w.text
+	}
+	return fields
+}
+
+// tokenize splits a command line into arguments like shellFields, but fails
+// on an unterminated quote or a trailing backslash rather than guessing
+// where the argument was meant to end
+func tokenize(line string) ([]string, error) {
+	words := splitWords(line)
+	if n := len(words); n > 0 && words[n-1].openQuote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", words[n-1].openQuote)
+	}
+	if NeedsContinuation(line) {
+		return nil, fmt.Errorf("unexpected backslash at end of line")
+	}
+	fields := make([]string, len(words))
+	for i, w := range words {
+		fields[i] = w.text
+	}
+	return fields, nil
+}
+
+// LastWord returns the last word of line as it was typed, keeping any
+// quotes and escapes, or "" if the line has no words
+func LastWord(line string) string {
+	words := splitWords(line)
+	if len(words) == 0 {
+		return ""
+	}
+	w := words[len(words)-1]
+	return string([]rune(line)[w.start:w.end])
+}
+
+// backgroundCommand reports whether line ends with an & asking for it to
+// be run in the background, and returns it without the &. An escaped or
+// quoted &, or one ending && or a redirection such as 2>&, doesn't count.
+func backgroundCommand(line string) (string, bool) {
+	trimmed := []rune(strings.TrimRightFunc(line, unicode.IsSpace))
+	words := splitWords(string(trimmed))
+	if len(words) == 0 || words[len(words)-1].openQuote != 0 {
+		return line, false
+	}
+	n := len(trimmed)
+	if trimmed[n-1] != '&' || (n > 1 && strings.ContainsRune("&|>\\", trimmed[n-2])) {
+		return line, false
+	}
+	return strings.TrimRightFunc(string(trimmed[:n-1]), unicode.IsSpace), true
+}
+
+// wordAt returns the word being typed before rune offset pos in line, which
+// is empty and starts at pos when the cursor follows whitespace. isCommand
+// reports whether it is the first word of the line, that is the command name.
+func wordAt(line string, pos int) (w word, isCommand bool) {
+	words := splitWords(string([]rune(line)[:pos]))
+	if n := len(words); n > 0 && words[n-1].end == pos {
+		return words[n-1], n == 1
+	}
+	return word{start: pos, end: pos}, len(words) == 0
+}
+
+// variableRef finds a $NAME or ${NAME reference being typed just before
+// rune offset pos in line. It returns the offset of the $ and whether a
+// brace follows it. References that are escaped or inside single quotes
+// are not expanded by the shell and are ignored.
+func variableRef(line string, pos int) (start int, braced bool, ok bool) {
+	runes := []rune(line)[:pos]
+	i := len(runes)
+	for i > 0 && (runes[i-1] == '_' || unicode.IsLetter(runes[i-1]) || unicode.IsDigit(runes[i-1])) {
+		i--
+	}
+	if i > 0 && runes[i-1] == '{' {
+		braced = true
+		i--
+	}
+	if i == 0 || runes[i-1] != '$' {
+		return 0, false, false
+	}
+	start = i - 1
+
+	w, _ := wordAt(line, pos)
+	if w.openQuote == '\'' || (start > 0 && runes[start-1] == '\\') {
+		return 0, false, false
+	}
+	return start, braced, true
+}
+
+// shellSpecial lists the characters that must be escaped to appear
+// literally in an unquoted word
+const shellSpecial = " \t\n'\"\\$`&|;<>()*?[]{}!"
+
+// quoteWord prepares s for insertion into a word that was started with
+// quote, or unquoted if quote is 0. Special characters are escaped with
+// backslashes in unquoted words. Quoted words are reopened with their quote
+// and closed again unless closeQuote is false, as for a directory that is
+// still being completed.
+func quoteWord(s string, quote rune, closeQuote bool) string {
+	var b strings.Builder
+	switch quote {
+	case 0:
+		for _, r := range s {
+			if strings.ContainsRune(shellSpecial, r) {
+				b.WriteRune('\\')
+			}
+			b.WriteRune(r)
+		}
+		return b.String()
+	case '"':
+		b.WriteRune('"')
+		for _, r := range s {
+			if strings.ContainsRune("\"\\$`", r) {
+				b.WriteRune('\\')
+			}
+			b.WriteRune(r)
+		}
+	default:
+		// Nothing can be escaped inside single quotes, so close the quotes
+		// around an escaped quote
+		b.WriteString("'" + strings.ReplaceAll(s, "'", `'\''`))
+	}
+	if closeQuote {
+		b.WriteRune(quote)
+	}
+	return b.String()
+}
+
+// expandTilde replaces a leading ~ or ~user in path with that user's home
+// directory. The path is returned unchanged when the user is unknown.
+func expandTilde(path string) string {
+	if !strings.HasPrefix(path, "~") {
+		return path
+	}
+	name, _, _ := strings.Cut(path[1:], "/")
+	var home string
+	if name == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return path
+		}
+		home = homeDir
+	} else {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return path
+		}
+		home = u.HomeDir
+	}
+	return home + path[1+len(name):]
+}
+
+// systemUsers returns the accounts listed in /etc/passwd sorted by name,
+// since os/user has no way to enumerate users
+func systemUsers() ([]*user.User, error) {
+	file, err := os.Open("/etc/passwd")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var users []*user.User
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// name:password:uid:gid:gecos:home:shell
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) < 7 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		users = append(users, &user.User{
+			Username: fields[0],
+			Uid:      fields[2],
+			Gid:      fields[3],
+			Name:     fields[4],
+			HomeDir:  fields[5],
+		})
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users, scanner.Err()
+}
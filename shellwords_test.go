@@ -0,0 +1,60 @@
+package goterm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+		err  string
+	}{
+		{line: "", want: []string{}},
+		{line: "  ls  -l ", want: []string{"ls", "-l"}},
+		// Quotes of one kind inside the other are kept
+		{line: `echo "it's"`, want: []string{"echo", "it's"}},
+		{line: `echo 'say "hi"'`, want: []string{"echo", `say "hi"`}},
+		{line: `echo "a 'b c' d"`, want: []string{"echo", "a 'b c' d"}},
+		// Empty quotes are an empty argument, or nothing inside a word
+		{line: `echo "" ''`, want: []string{"echo", "", ""}},
+		{line: `echo a""b''c`, want: []string{"echo", "abc"}},
+		{line: `echo "a"'b'c`, want: []string{"echo", "abc"}},
+		// Inside double quotes a backslash only escapes $ ` " \ and a line
+		// break
+		{line: `echo "a\"b"`, want: []string{"echo", `a"b`}},
+		{line: `echo "a\\b"`, want: []string{"echo", `a\b`}},
+		{line: `echo "a\$b"`, want: []string{"echo", "a$b"}},
+		{line: `echo "a\nb"`, want: []string{"echo", `a\nb`}},
+		{line: "echo \"a\\\nb\"", want: []string{"echo", "ab"}},
+		// Inside single quotes it is just a backslash
+		{line: `echo 'a\'`, want: []string{"echo", `a\`}},
+		{line: `echo 'a\nb'`, want: []string{"echo", `a\nb`}},
+		// Outside quotes it escapes anything
+		{line: `echo a\ b \"`, want: []string{"echo", "a b", `"`}},
+		{line: `echo a\\`, want: []string{"echo", `a\`}},
+		{line: "echo a\\\nb", want: []string{"echo", "ab"}},
+		{line: `echo a\`, err: "unexpected backslash at end of line"},
+		{line: `echo "abc`, err: `unterminated " quote`},
+		{line: `echo 'abc`, err: "unterminated ' quote"},
+		{line: `echo "a\"`, err: `unterminated " quote`},
+		{line: `echo "it's`, err: `unterminated " quote`},
+	}
+	for _, tt := range tests {
+		got, err := tokenize(tt.line)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("tokenize(%q) error = %v, want %q", tt.line, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("tokenize(%q) failed: %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tokenize(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
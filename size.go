@@ -0,0 +1,71 @@
+package goterm
+
+import (
+	"os"
+
+	"golang.org/x/term"
+)
+
+// Fallback size used when the terminal can't report its own
+const (
+	defaultCols = 80
+	defaultRows = 24
+)
+
+// Size returns the width and height of the terminal in character cells.
+// The size is read once and kept until Resize is called. If it can't be
+// read, 80x24 is returned along with the error. Output that isn't a file,
+// such as a buffer, is taken to be 80x24.
+func (t *Terminal) Size() (cols, rows int, err error) {
+	t.sizeMu.Lock()
+	defer t.sizeMu.Unlock()
+	if t.cols == 0 {
+		cols, rows, err := t.readSize()
+		if err != nil {
+			return cols, rows, err
+		}
+		t.cols, t.rows = cols, rows
+	}
+	return t.cols, t.rows, nil
+}
+
+// Resize tells the terminal that its window has changed size, as the
+// SIGWINCH signal does. The size is read again and a line being read by
+// ReadLine is redrawn to fit.
+func (t *Terminal) Resize() {
+	t.sizeMu.Lock()
+	t.cols, t.rows = 0, 0
+	t.sizeMu.Unlock()
+	select {
+	case t.resizes <- struct{}{}:
+	default:
+		// A redraw is already pending
+	}
+}
+
+// readSize reads the size of the terminal from the system, asking the
+// output go-term draws on or, if that isn't a terminal, the one commands
+// are run on
+func (t *Terminal) readSize() (cols, rows int, err error) {
+	var fds []int
+	if f, ok := t.out.(interface{ Fd() uintptr }); ok {
+		fds = append(fds, int(f.Fd()))
+	}
+	if f, ok := t.cmdIn.(*os.File); ok {
+		fds = append(fds, int(f.Fd()))
+	}
+	if len(fds) == 0 {
+		return defaultCols, defaultRows, nil
+	}
+
+	for _, fd := range fds {
+		if cols, rows, err = term.GetSize(fd); err != nil {
+			continue
+		}
+		if cols == 0 || rows == 0 {
+			return defaultCols, defaultRows, nil
+		}
+		return cols, rows, nil
+	}
+	return defaultCols, defaultRows, err
+}
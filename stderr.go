@@ -0,0 +1,67 @@
+package goterm
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// SetColorStderr turns on or off coloring what commands write to standard
+// error, in Colors.Stderr, when it goes to the terminal. Commands then
+// write to a pipe rather than the terminal, which some tools notice and
+// behave differently for, such as dropping their progress bars.
+func (t *Terminal) SetColorStderr(on bool) {
+	t.colorStderr = on
+}
+
+// stderrStyle returns the style commands' standard error is drawn in, or
+// "" if it is left alone
+func (t *Terminal) stderrStyle() string {
+	if !t.colorStderr || t.LineMode() {
+		return ""
+	}
+	if f, ok := t.cmdErr.(*os.File); !ok || !isTerminal(f.Fd()) {
+		return ""
+	}
+	return adaptStyle(t.colors.Stderr, t.ColorLevel())
+}
+
+// stderrWriter colors each line written to it, as it is written. Lines
+// holding escape sequences of their own are passed on as they are.
+type stderrWriter struct {
+	w      io.Writer
+	style  string
+	styled bool // The style is in effect on the current line
+	plain  bool // The current line is left alone
+}
+
+func (s *stderrWriter) Write(p []byte) (int, error) {
+	var b bytes.Buffer
+	for rest := p; len(rest) > 0; {
+		text, after, ended := bytes.Cut(rest, []byte{'\n'})
+		rest = after
+		switch {
+		case bytes.IndexByte(text, '\033') >= 0:
+			if s.styled {
+				b.WriteString(resetColor)
+				s.styled = false
+			}
+			s.plain = true
+		case len(text) > 0 && !s.plain && !s.styled:
+			b.WriteString(s.style)
+			s.styled = true
+		}
+		b.Write(text)
+		if ended {
+			if s.styled {
+				b.WriteString(resetColor)
+			}
+			b.WriteByte('\n')
+			s.styled, s.plain = false, false
+		}
+	}
+	if _, err := s.w.Write(b.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,29 @@
+package goterm
+
+// NeedsContinuation reports whether a command is incomplete because it ends
+// with an unescaped backslash or has an unterminated single or double quote
+func NeedsContinuation(cmd string) bool {
+	inSingle, inDouble, escaped := false, false, false
+	for _, r := range cmd {
+		switch {
+		case escaped:
+			escaped = false
+		case inSingle:
+			// Nothing is special inside single quotes except the closing quote
+			if r == '\'' {
+				inSingle = false
+			}
+		case r == '\\':
+			escaped = true
+		case inDouble:
+			if r == '"' {
+				inDouble = false
+			}
+		case r == '\'':
+			inSingle = true
+		case r == '"':
+			inDouble = true
+		}
+	}
+	return inSingle || inDouble || escaped
+}
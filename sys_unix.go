@@ -0,0 +1,35 @@
+//go:build unix
+
+package goterm
+
+import (
+	"os"
+	"syscall"
+)
+
+// fallbackShells lists the shells tried, in order, when $SHELL is unset or
+// can't be found
+var fallbackShells = []string{"fish", "zsh", "bash", "sh"}
+
+// isExecutable reports whether path is a file, or a link to one, that
+// anyone may execute
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular() && info.Mode().Perm()&0111 != 0
+}
+
+// indexHas reports whether the sorted names of the executables on PATH
+// include the command name
+func indexHas(names []string, name string) bool {
+	return containsSorted(names, name)
+}
+
+// lockFile waits for an exclusive lock on f, which other sessions respect
+func lockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+}
+
+// unlockFile releases the lock taken by lockFile
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,61 @@
+package goterm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// fallbackShells lists the shells tried, in order, when $SHELL is unset or
+// can't be found
+var fallbackShells = []string{"pwsh", "powershell", "cmd"}
+
+// isExecutable reports whether path is a file, or a link to one, whose
+// extension is one of those in PATHEXT
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	exts := os.Getenv("PATHEXT")
+	if exts == "" {
+		exts = ".COM;.EXE;.BAT;.CMD"
+	}
+	ext := filepath.Ext(path)
+	for _, e := range filepath.SplitList(exts) {
+		if ext != "" && strings.EqualFold(e, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// indexHas reports whether the sorted names of the executables on PATH
+// include the command name, which can leave out the extension, as when it
+// is run. Case is ignored.
+func indexHas(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) || strings.EqualFold(strings.TrimSuffix(n, filepath.Ext(n)), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// lockRange is where lockFile locks: a byte far past the end of any file,
+// as Windows locks keep other sessions from reading what they cover
+var lockRange = windows.Overlapped{Offset: 0xFFFFFFFF, OffsetHigh: 0x7FFFFFFF}
+
+// lockFile waits for an exclusive lock on f, which other sessions respect
+func lockFile(f *os.File) error {
+	ol := lockRange
+	return windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, &ol)
+}
+
+// unlockFile releases the lock taken by lockFile
+func unlockFile(f *os.File) error {
+	ol := lockRange
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, &ol)
+}
@@ -1,192 +1,810 @@
-package main
+package goterm
 
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"sort"
-	"strconv"
+	"regexp"
 	"strings"
-	"github.com/pkg/term"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unicode/utf8"
 )
 
-// lineWriter wraps an io.Writer and ensures proper line endings
+// lineWriter wraps an io.Writer and ensures proper line endings, writing
+// each \n not already after a \r as \r\n
 type lineWriter struct {
 	w io.Writer
+	// Report writes to a closed pipe as done, for output that nothing
+	// need be told of going unread
+	ignoreEPIPE bool
+	raw bool // Write everything as it is
+	cr bool // The last byte written was \r
 }
 
-func (w *lineWriter) Write(p []byte) (n int, err error) {
-	// Convert any lone \n to \r\n
-	modified := bytes.ReplaceAll(p, []byte{'\n'}, []byte{'\r', '\n'})
-	n, err = w.w.Write(modified)
+// newLineWriter returns a lineWriter writing to w, which writes everything
+// as it is if SetRawOutput is on
+func (t *Terminal) newLineWriter(w io.Writer) *lineWriter {
+	return &lineWriter{w: w, raw: t.rawOutput}
+}
+
+// SetRawOutput turns on or off writing output as it is, without turning
+// \n into \r\n. It is on when output isn't going to a terminal in raw
+// mode: in line mode, and when standard output is redirected.
+func (t *Terminal) SetRawOutput(on bool) {
+	t.rawOutput = on
+	if w, ok := t.stdout.(*lineWriter); ok {
+		w.raw = on
+	}
+}
+
+// crlf is written in place of a lone \n
+var crlf = []byte{'\r', '\n'}
+
+// Write implements io.Writer. The count returned is of the bytes of p
+// written, a \n counting once all of the \r\n it became was written.
+func (w *lineWriter) Write(p []byte) (int, error) {
+	if w.raw {
+		written, err := w.w.Write(p)
+		if err != nil {
+			return w.result(p, written, err)
+		}
+		return written, nil
+	}
+	n := 0
+	for n < len(p) {
+		i := bytes.IndexByte(p[n:], '\n')
+		if i < 0 {
+			return w.write(p, n, len(p))
+		}
+		end := n + i
+		var err error
+		if (i > 0 && p[end-1] == '\r') || (i == 0 && w.cr) {
+			if n, err = w.write(p, n, end+1); err != nil {
+				return n, err
+			}
+			continue
+		}
+		if n, err = w.write(p, n, end); err != nil {
+			return n, err
+		}
+		written, err := w.w.Write(crlf)
+		if written > 0 {
+			w.cr = written == 1
+		}
+		if err == nil && written < len(crlf) {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			return w.result(p, n, err)
+		}
+		n = end + 1
+	}
+	return n, nil
+}
+
+// write writes p[from:to] as it is, returning the offset in p written up to
+func (w *lineWriter) write(p []byte, from, to int) (int, error) {
+	if from == to {
+		return from, nil
+	}
+	written, err := w.w.Write(p[from:to])
+	if written > 0 {
+		w.cr = p[from+written-1] == '\r'
+	}
+	if err == nil && written < to-from {
+		err = io.ErrShortWrite
+	}
 	if err != nil {
-		// Silently handle write errors to prevent them from bubbling up to the user
+		return w.result(p, from+written, err)
+	}
+	return to, nil
+}
+
+// result returns what Write reports after failing with err once n bytes of
+// p were written
+func (w *lineWriter) result(p []byte, n int, err error) (int, error) {
+	if w.ignoreEPIPE && errors.Is(err, syscall.EPIPE) {
 		return len(p), nil
 	}
-	return n, nil
+	return n, err
+}
+
+// syncWriter is a bufio.Writer that goroutines other than the one reading
+// keys can write to, such as one handling signals. Each write is atomic,
+// though writes from different goroutines may interleave.
+type syncWriter struct {
+	mu     sync.Mutex
+	w      *bufio.Writer
+	trace  *tracer // Logs what is flushed, if tracing is on
+	traced []byte  // Written since the last flush, for trace
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.trace != nil {
+		s.traced = append(s.traced, p...)
+	}
+	return s.w.Write(p)
+}
+
+func (s *syncWriter) WriteString(str string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.trace != nil {
+		s.traced = append(s.traced, str...)
+	}
+	return s.w.WriteString(str)
 }
 
+// writeOutput writes command output, which is left out of the trace
+func (s *syncWriter) writeOutput(str string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.trace != nil {
+		s.trace.output(s.traced)
+		s.traced = s.traced[:0]
+		s.trace.log(traceEvent{out: true, size: len(str)})
+	}
+	return s.w.WriteString(str)
+}
+
+// Flush writes out what has been buffered
+func (s *syncWriter) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.trace != nil {
+		s.trace.output(s.traced)
+		s.traced = s.traced[:0]
+	}
+	return s.w.Flush()
+}
+
+// setTrace has what is written logged to tr
+func (s *syncWriter) setTrace(tr *tracer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trace = tr
+}
+
+// Terminal is the controlling terminal in raw mode, along with the state of
+// the editor running on it: history, completion, key bindings, jobs and the
+// environment commands are run with
 type Terminal struct {
-	term *term.Term
-	writer *bufio.Writer
-	currentSuggestions []string
+	input inputSource // Where key presses are read from
+	tty rawTerminal // The terminal in raw mode, if input is one
+	out io.Writer // Where the editor draws
+	writer *syncWriter // Buffers output to out
+	cmdIn io.Reader // Standard input of commands, if any
+	cmdOut io.Writer // Standard output of commands
+	cmdErr io.Writer // Standard error of commands
+	currentSuggestions []Completion
 	suggestionIndex int
 	selectedIndex int
 	history []string
+	entries []HistoryEntry // history with timing, in step with history
 	historyIndex int
+	historyDraft string // Line being typed when history navigation started
+	historyEdits map[int]string // Changes to history entries made while navigating
 	historyFile string
+	historyOffset int64 // Size of the history file when it was last read or written
+	historyOptions HistoryOptions
+	historyLimit int // Number of entries kept
+	historyIgnore []*regexp.Regexp // Compiled HistoryOptions.Ignore
 	searchMode bool
 	searchQuery string
 	searchResults []string
 	searchIndex int
+	searchForward bool // Searching towards newer entries, after Ctrl+S
+	searchOriginal string // Line before the search started, restored by Ctrl+G
 	currentSuggestion string
+	keymap Keymap
+	prefixKeymaps map[KeyEvent]Keymap
+	killRing KillRing
+	view inputView // The input as last asked to be shown
+	menu []string // Rows of the completion menu shown below the input
+	painted *frame // What is on the screen, nil when nothing is
+	handlingKey bool // Set while a key is handled, when drawing isn't flushed
+	staleMenu bool // The menu is for an earlier input, until completions for this one replace it
+	highlighter Highlighter
+	completers []Completer
+	commands *CommandCompleter
+	mu sync.Mutex // Held while handling a key, so background completions don't interleave output
+	completionGen uint64
+	cancelCompletion context.CancelFunc
+	historyMu sync.RWMutex
+	matchMode MatchMode
+	arguments *ArgumentCompleter
+	menuLayout menuLayout
+	menuRows int
+	menuActive bool
+	stats *UsageStats
+	files *FileCompleter
+	tabAcceptsFirst bool
+	suggestionHint bool // Show the whole inline suggestion in a box at the right edge
+	interrupts chan struct{} // Signalled by Interrupt while waiting for a key
+	resizes chan struct{} // Signalled by Resize while waiting for a key
+	sizeMu sync.Mutex // Guards cols and rows
+	cols, rows int // Size of the terminal, or 0 until it is first read
+	jobsMu sync.Mutex // Guards foreground and jobs
+	foreground *Job // Command being run by ExecuteCommand, if any
+	jobs []*Job
+	shell string // Path of the shell commands are run with, found by Shell
+	stdout io.Writer // Where builtins write to the terminal
+	lastStatus int // Exit status of the last command run
+	statusColor bool // Show the prompt in red after a command fails
+	statusCode bool // Show the exit status of a failed command in the prompt
+	exitRequested bool // Set by the exit builtin
+	exitStatus int // Status the exit builtin asked to exit with
+	noMatchError bool // A glob matching nothing is an error
+	envMu sync.RWMutex // Guards env
+	env map[string]string // Environment commands are run with
+	aliasMu sync.RWMutex // Guards aliases and aliasFile
+	aliases map[string]string
+	aliasFile string // Where aliases are saved, if anywhere
+	aliasSourced map[string]bool // Aliases defined by sourced files, which aren't saved
+	sourcing int // Depth of files being run by Source
+	sourceLine string // File and line of the sourced command being run, for errors
+	builtinMu sync.RWMutex // Guards builtins
+	builtins map[string]Builtin
+	pendingPaste string // Pasted lines ReadLine has yet to return
+	dirStack []string // Directories saved by pushd, most recent first
+	prompt func() string // Renders the prompt in place of the default, if set
+	promptTemplate *PromptTemplate // Renders the prompt in place of the default, if set
+	transientPrompt string // Shown in place of the prompt once a line is entered, if set
+	colors Colors
+	theme string // Name of the theme colors came from
+	colorLevel ColorLevel // Colors the terminal can show
+	noColor bool // Draw nothing in color, whatever colorLevel is
+	preExec []func(cmd string) // Called by Run before each line
+	postExec []func(cmd string, status int, dur time.Duration) // Called by Run after each line
+	notFound []func(cmd string) bool // Called by Run when a command isn't found
+	durationThreshold time.Duration // Commands taking longer have their time shown, if set
+	lines *bufio.Scanner // Where lines are read from in line mode, nil otherwise
+	linePrompt bool // Show the prompt in line mode, as the lines come from a terminal
+	scanned chan bool // Result of the scan of lines under way, if any
+	windowTitles bool // Set the window title to the working directory and running command
+	title string // Window title last set, "" if it hasn't been
+	reportCwd bool // Tell the terminal the working directory with OSC 7
+	reportedCwd string // Working directory the terminal was last told of
+	restoreCwd bool // Record the working directory for the next session to go back to
+	paging bool // Page command output that doesn't fit on the screen
+	colorStderr bool // Draw what commands write to standard error in Colors.Stderr
+	progressAfter time.Duration // How long a command is silent before its progress is shown, 0 for never
+	progress *progressLine // Shown while the foreground job started by Run is silent, if any
+	notifyOptions NotifyOptions
+	outputMidLine atomic.Bool // Commands' output since Run began ends mid-line, as far as progress lines saw
+	rawOutput bool // Write output without turning \n into \r\n
+	executing bool // A foreground command has the screen, so nothing is drawn; guarded by mu
+	trace *tracer // Logs the editor's input and output, if tracing is on
+	closeOnce sync.Once
+	closeErr error // What the first Close returned
+	closed chan struct{} // Closed by Close, ending waits for input
 }
 
-// NewTerminal creates a new terminal wrapper
-func NewTerminal() (*Terminal, error) {
-	t, err := term.Open("/dev/tty")
-	if err != nil {
-		return nil, fmt.Errorf("failed to open terminal: %v", err)
+// NewTerminal opens the controlling terminal, /dev/tty or the Windows
+// console, in raw mode and draws on standard output. Commands are run with go-term's own standard
+// input and output. Settings, aliases and history are loaded from the
+// user's files, which can override the defaults set by opts.
+//
+// When standard input isn't a terminal, as when a script is piped in, or
+// TERM is dumb, the terminal is in line mode instead: see LineMode.
+func NewTerminal(opts ...Option) (*Terminal, error) {
+	if stdinTerminal := isTerminal(os.Stdin.Fd()); !stdinTerminal || os.Getenv("TERM") == "dumb" {
+		terminal := newTerminal(nil, os.Stdout)
+		terminal.lines = bufio.NewScanner(os.Stdin)
+		terminal.linePrompt = stdinTerminal
+		if err := terminal.apply(opts); err != nil {
+			terminal.release()
+			return nil, err
+		}
+		// Lines read ahead from a pipe would be lost to commands, so they
+		// are only given standard input if it is a terminal
+		if stdinTerminal {
+			terminal.cmdIn = os.Stdin
+		}
+		terminal.cmdOut = os.Stdout
+		terminal.cmdErr = os.Stderr
+		terminal.loadUserFiles()
+		return terminal, nil
 	}
 
-	// Set raw mode
-	err = term.RawMode(t)
+	tty, err := openRaw(controllingTerminal)
 	if err != nil {
-		t.Close()
-		return nil, fmt.Errorf("failed to set raw mode: %v", err)
+		return nil, err
+	}
+	terminal := newTerminal(tty, os.Stdout)
+	terminal.tty = tty
+	if err := terminal.apply(opts); err != nil {
+		terminal.release()
+		return nil, err
+	}
+	terminal.cmdIn = os.Stdin
+	terminal.cmdOut = os.Stdout
+	terminal.cmdErr = os.Stderr
+	terminal.loadUserFiles()
+	return terminal, nil
+}
+
+// NewTerminalWithIO creates a terminal that reads key presses from in and
+// draws on out, set up like NewTerminal. If in is a terminal it is put in
+// raw mode and commands are run on it, and otherwise, as with a pipe, it is
+// read as it is, with commands given no input and writing to out.
+func NewTerminalWithIO(in io.Reader, out io.Writer, opts ...Option) (*Terminal, error) {
+	var terminal *Terminal
+	if f, ok := in.(*os.File); ok && isTerminal(f.Fd()) {
+		tty, err := openRaw(f.Name())
+		if err != nil {
+			return nil, err
+		}
+		terminal = newTerminal(tty, out)
+		terminal.tty = tty
+		terminal.cmdIn = f
+	} else {
+		terminal = newTerminal(newReaderInput(in), out)
 	}
+	if err := terminal.apply(opts); err != nil {
+		terminal.release()
+		return nil, err
+	}
+	terminal.cmdOut = out
+	terminal.cmdErr = out
+	terminal.loadUserFiles()
+	return terminal, nil
+}
+
+// rawTerminal is a terminal put in raw mode by openRaw, which key presses
+// are read from
+type rawTerminal interface {
+	inputSource
+	// Restore puts the terminal back in the mode it was opened in
+	Restore() error
+	// RawMode puts the terminal in raw mode again after Restore
+	RawMode() error
+	Close() error
+}
+
+// sizeWatcher is a terminal that tells of its own resizes, as the Windows
+// console does in place of SIGWINCH
+type sizeWatcher interface {
+	// watchSize calls resized whenever the size changes, until the
+	// terminal is closed
+	watchSize(resized func())
+}
 
-	// Create terminal instance
+// newTerminal creates a terminal reading from input and drawing on out,
+// with the default builtins and completers but nothing loaded from the
+// user's files. A nil input is for line mode, where there are no keys to
+// read and nothing is drawn.
+func newTerminal(input inputSource, out io.Writer) *Terminal {
 	terminal := &Terminal{
-		term: t,
-		writer: bufio.NewWriter(os.Stdout),
+		input: input,
+		out: out,
+		writer: &syncWriter{w: bufio.NewWriter(out)},
+		closed: make(chan struct{}),
 		historyIndex: -1,
 		history: []string{},
+		keymap: DefaultKeymap(),
+		prefixKeymaps: defaultKeySequences(),
+		commands: NewCommandCompleter(),
+		menuRows: defaultMenuRows,
+		historyLimit: defaultHistoryLimit,
+		historyOptions: DefaultHistoryOptions(),
+		notifyOptions: DefaultNotifyOptions(),
+		stats: &UsageStats{},
+		interrupts: make(chan struct{}, 1),
+		resizes: make(chan struct{}, 1),
+		stdout: &lineWriter{w: out, ignoreEPIPE: true},
+		env: environMap(os.Environ()),
+		reportCwd: true,
+		suggestionHint: true,
+	}
+
+	for _, b := range defaultBuiltins() {
+		terminal.RegisterBuiltin(b)
+	}
+	terminal.highlighter = DefaultHighlighter{
+		Aliases:   terminal.Alias,
+		IsBuiltin: terminal.isBuiltin,
+		Commands:  terminal.commands,
+		paths:     &pathCache{},
+	}
+	terminal.SetTheme("default")
+	terminal.colorLevel = DetectColorLevel(os.Getenv)
+	terminal.OnPostExec(terminal.notifyFinished)
+	// Output not going to a terminal in raw mode needs no \r added
+	if f, ok := out.(*os.File); input == nil || (ok && !isTerminal(f.Fd())) {
+		terminal.SetRawOutput(true)
+	}
+
+	// Default completion sources, in the order they are offered
+	terminal.RegisterCompleter(&HistoryCompleter{History: terminal.History, Limit: 3, Stats: terminal.stats})
+	terminal.commands.Stats = terminal.stats
+	terminal.commands.Aliases = terminal.Aliases
+	terminal.commands.Builtins = terminal.builtinSummaries
+	terminal.RefreshCommandCache()
+	terminal.RegisterCompleter(terminal.commands)
+	terminal.files = &FileCompleter{}
+	terminal.arguments = &ArgumentCompleter{
+		Rules:     defaultArgumentRules(terminal.files),
+		Fallback:  terminal.files,
+		Variables: &VariableCompleter{},
+		Aliases:   terminal.Alias,
+	}
+	terminal.arguments.Rules["j"] = &JumpCompleter{Terminal: terminal}
+	terminal.RegisterCompleter(terminal.arguments)
+
+	if w, ok := input.(sizeWatcher); ok {
+		w.watchSize(terminal.Resize)
+	}
+	if input == nil {
+		// The terminal, if any, isn't in raw mode, and turns \n into \r\n
+		// itself
+		terminal.input = noInput{}
+		terminal.stdout = out
+		return terminal
+	}
+
+	// Have the terminal mark pasted text so it can be inserted in one go
+	terminal.writer.WriteString(enableBracketedPaste)
+	terminal.writer.Flush()
+	return terminal
+}
+
+// loadUserFiles loads the config file, aliases, history and usage stats,
+// warning about any that can't be read
+func (terminal *Terminal) loadUserFiles() {
+	// Load settings from the config file. The history settings decide how
+	// the history is loaded.
+	if err := terminal.loadConfigFile(os.Stderr); err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "Warning: Could not load config: %v\n", err)
+	}
+	if path, err := aliasFilePath(); err == nil {
+		if err := terminal.LoadAliases(path); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not load aliases: %v\n", err)
+		}
 	}
 
-	// Load history
+	// Load history, noting whether this is the first run
+	firstRun := false
+	if path, err := terminal.historyPath(); err == nil {
+		_, err := os.Stat(path)
+		firstRun = os.IsNotExist(err)
+	}
 	if err := terminal.loadHistory(); err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: Could not load history: %v\n", err)
 	}
+	if path, err := statsFilePath(); err == nil {
+		if err := terminal.stats.Load(path); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not load usage stats: %v\n", err)
+		}
+	}
 
-	return terminal, nil
+	// Start out with the history of the shell used before
+	if firstRun {
+		messages, err := terminal.importShellHistories(HistoryBash, HistoryZsh, HistoryFish)
+		for _, msg := range messages {
+			terminal.WriteLine(msg)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not import history: %v\n", err)
+		}
+	}
 }
 
-// Close closes the terminal
+// Lock stops completions computed in the background from being drawn,
+// which is done while a key is handled so that their output doesn't
+// interleave with the editor's. It should be released with Unlock while
+// waiting for the next key.
+func (t *Terminal) Lock() {
+	t.mu.Lock()
+}
+
+// Unlock lets completions computed in the background be drawn again
+func (t *Terminal) Unlock() {
+	t.mu.Unlock()
+}
+
+// Close restores the original terminal mode and closes the terminal. It
+// is safe to call from another goroutine, such as one handling signals,
+// while a key is waited for, which ends the wait with io.EOF. Calls after
+// the first return what it did.
 func (t *Terminal) Close() error {
-	return t.term.Close()
+	t.closeOnce.Do(func() {
+		close(t.closed)
+		// Trim the history file now that appending is over
+		if err := t.compactHistory(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not compact history: %v\n", err)
+		}
+		if err := t.stats.Save(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not save usage stats: %v\n", err)
+		}
+		t.closeErr = t.release()
+	})
+	return t.closeErr
+}
+
+// release puts the terminal back the way it was found
+func (t *Terminal) release() error {
+	defer t.trace.close()
+	t.restoreTitle()
+	if !t.LineMode() {
+		t.writer.WriteString(disableBracketedPaste)
+	}
+	t.writer.Flush()
+	if t.tty == nil {
+		return nil
+	}
+	t.tty.Restore()
+	return t.tty.Close()
+}
+
+// Suspend hands the terminal back to another program, such as an editor, by
+// flushing pending output and restoring the original terminal mode
+func (t *Terminal) Suspend() error {
+	if !t.LineMode() {
+		t.writer.WriteString(disableBracketedPaste)
+	}
+	if err := t.writer.Flush(); err != nil {
+		return err
+	}
+	if t.tty == nil {
+		return nil
+	}
+	if err := t.tty.Restore(); err != nil {
+		return fmt.Errorf("failed to restore terminal mode: %v", err)
+	}
+	return nil
+}
+
+// Resume puts the terminal back into raw mode after Suspend
+func (t *Terminal) Resume() error {
+	if t.tty != nil {
+		if err := t.tty.RawMode(); err != nil {
+			return fmt.Errorf("failed to set raw mode: %v", err)
+		}
+	}
+	if !t.LineMode() {
+		t.writer.WriteString(enableBracketedPaste)
+	}
+	return t.writer.Flush()
 }
 
-// ReadChar reads a single character from the terminal
+// ReadChar reads a single character from the terminal. It returns io.EOF when
+// the input has ended, including when the terminal has been hung up or
+// closed.
 func (t *Terminal) ReadChar() (byte, error) {
+	// Input is waited for rather than blocked on in Read, so that Close
+	// can end the wait
+	for !t.WaitForInput(interruptPollInterval) {
+	}
+	select {
+	case <-t.closed:
+		return 0, io.EOF
+	default:
+	}
 	buf := make([]byte, 1)
-	_, err := t.term.Read(buf)
+	n, err := t.input.Read(buf)
 	if err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, syscall.EIO) {
+			return 0, io.EOF
+		}
 		return 0, err
 	}
+	if n == 0 {
+		return 0, io.EOF
+	}
 	return buf[0], nil
 }
 
+// WaitForInput reports whether input is available to read within d. It only
+// peeks at the terminal's input queue, so no bytes are consumed and nothing is
+// lost if input arrives after the timeout.
+func (t *Terminal) WaitForInput(d time.Duration) bool {
+	return t.waitForInput(context.Background(), d)
+}
+
+// inputPollInterval is how often waitForInput checks for input
+const inputPollInterval = 5 * time.Millisecond
+
+// waitForInput is WaitForInput, also giving up once ctx is done
+func (t *Terminal) waitForInput(ctx context.Context, d time.Duration) bool {
+	deadline := time.Now().Add(d)
+	for {
+		select {
+		case <-t.closed:
+			// Let the next read report that the terminal is closed
+			return true
+		case <-ctx.Done():
+			return false
+		default:
+		}
+		n, err := t.input.Available()
+		if err != nil || n > 0 {
+			// Let the next read report any error
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(inputPollInterval)
+	}
+}
+
+// ReadRune reads a single UTF-8 encoded character from the terminal and
+// returns it together with its encoded size in bytes
+func (t *Terminal) ReadRune() (rune, int, error) {
+	b, err := t.ReadChar()
+	if err != nil {
+		return 0, 0, err
+	}
+	r, err := finishRune(t, b)
+	if err != nil {
+		return 0, 0, err
+	}
+	if r == utf8.RuneError {
+		return r, 1, nil
+	}
+	return r, utf8.RuneLen(r), nil
+}
+
 // Write writes data to the terminal
 func (t *Terminal) Write(data []byte) (int, error) {
-	return t.term.Write(data)
+	t.writer.Flush()
+	return t.out.Write(data)
+}
+
+// print writes s to the terminal, straight away unless a key is being
+// handled
+func (t *Terminal) print(s string) {
+	t.writer.WriteString(s)
+	t.flush()
 }
 
 // WriteLine writes a line to the terminal with proper line ending
 func (t *Terminal) WriteLine(s string) error {
-	// Write the content with both carriage return and newline
-	_, err := t.writer.WriteString(s)
-	if err != nil {
-		return err
-	}
-	_, err = t.writer.WriteString("\r\n")
-	if err != nil {
+	// Write the content with both carriage return and newline, unless in
+	// line mode where there is no raw mode to make up for. They go in one
+	// write so that lines from other goroutines can't come in between.
+	newline := "\r\n"
+	if t.LineMode() {
+		newline = "\n"
+	}
+	if _, err := t.writer.WriteString(s + newline); err != nil {
 		return err
 	}
 	return t.writer.Flush()
 }
 
-// ExecuteCommand executes a shell command
-func (t *Terminal) ExecuteCommand(command string, args ...string) error {
-	// Special handling for cd command
-	if command == "cd" {
-		var dir string
-		if len(args) == 0 {
-			// No args means cd to home directory
-			homeDir, err := os.UserHomeDir()
-			if err != nil {
-				return fmt.Errorf("could not get home directory: %v", err)
-			}
-			dir = homeDir
-		} else {
-			dir = args[0]
-			// Handle ~ expansion
-			if strings.HasPrefix(dir, "~") {
-				homeDir, err := os.UserHomeDir()
-				if err != nil {
-					return fmt.Errorf("could not get home directory: %v", err)
-				}
-				dir = homeDir + dir[1:]
+// continuationPrompt is shown at the start of each additional line of a
+// multi-line command
+const continuationPrompt = "... "
+
+// RedrawLine shows the prompt and the editor contents, with the visible
+// cursor at the editor's cursor position. Multi-line contents are drawn with
+// a continuation prompt on each additional line, and lines wider than the
+// terminal wrap onto as many rows as they need. Only what changed since the
+// input was last drawn is written.
+func (t *Terminal) RedrawLine(prompt string, ed *LineEditor) error {
+	// Color the input if a highlighter is set
+	var spans []StyledSpan
+	if t.highlighter != nil {
+		spans = t.highlighter.Highlight(ed.String())
+	}
+	t.view = inputView{
+		prompt:  prompt,
+		text:    strings.Join(highlightLines(ed.String(), spans), "\n"),
+		input:   ed.String(),
+		cursor:  ed.Cursor(),
+		suggest: ed.AtEnd(),
+	}
+
+	// Keep the inline suggestion while the input still leads to it, so that
+	// it doesn't flicker as it is typed
+	if t.SuggestionSuffix(ed.String()) == "" {
+		t.currentSuggestion = ""
+	}
+	return t.render()
+}
+
+// SetHighlighter replaces the highlighter used to color the input line.
+// A nil highlighter turns highlighting off.
+func (t *Terminal) SetHighlighter(h Highlighter) {
+	t.highlighter = h
+}
+
+// EndInput moves the cursor to the last row of a multi-line input and forgets
+// what RedrawLine drew, ready for output or a new prompt
+func (t *Terminal) EndInput() error {
+	if f := t.painted; f != nil {
+		if down := f.inputRows - 1 - f.row; down > 0 {
+			if _, err := fmt.Fprintf(t.writer, "\033[%dB", down); err != nil {
+				return err
 			}
 		}
-		// Change directory
-		if err := os.Chdir(dir); err != nil {
-			return fmt.Errorf("could not change directory: %v", err)
-		}
-		return nil
 	}
+	t.painted = nil
+	t.view = inputView{}
+	return t.flush()
+}
 
-	// For all other commands
-	// Use the shell to handle environment variables
-	shellCmd := command
+// ExecuteCommand runs a command as Run does. command is taken as typed and
+// each of args is quoted as a single argument.
+func (t *Terminal) ExecuteCommand(command string, args ...string) error {
+	line := command
 	for _, arg := range args {
-		shellCmd += " " + arg
-	}
-	
-	// Use fish shell to execute the command with environment variable expansion
-	cmd := exec.Command("fish", "-c", shellCmd)
-	
-	// Use our custom writer for stdout
-	lw := &lineWriter{w: os.Stdout}
-	cmd.Stdout = lw
-	cmd.Stderr = lw // Use the same line writer for stderr
-	cmd.Stdin = os.Stdin
-
-	// Run the command and handle errors gracefully
-	err := cmd.Run()
-	if err != nil {
-		// Only return the error if it's not a write error
-		if !strings.Contains(err.Error(), "write") {
-			return err
-		}
+		line += " " + shellQuote(arg)
 	}
-	return nil
+	return t.Run(line)
+}
+
+// LastStatus returns the exit status of the last command run: 0 if it
+// succeeded, 127 if it wasn't found and 128 plus the signal number if it was
+// killed by a signal
+func (t *Terminal) LastStatus() int {
+	return t.lastStatus
+}
+
+// ExitRequested reports whether the exit builtin has been run. Run and
+// Source then return an *ExitError with the status to exit with.
+func (t *Terminal) ExitRequested() bool {
+	return t.exitRequested
 }
 
-// GetPrompt returns a formatted prompt string showing the current directory
+// Interrupt handles SIGINT. A running command is sent the signal, otherwise
+// the key handling loop is told to abandon the line being typed. It is safe
+// to call from a signal handling goroutine.
+func (t *Terminal) Interrupt() {
+	if t.ForwardSignal(syscall.SIGINT) {
+		return
+	}
+	select {
+	case t.interrupts <- struct{}{}:
+	default:
+		// An interrupt is already pending
+	}
+}
+
+// GetPrompt returns a formatted prompt string showing the current directory,
+// or the prompt given by WithPrompt or a prompt template
 func (t *Terminal) GetPrompt() (string, error) {
+	if t.prompt != nil {
+		return t.prompt(), nil
+	}
+	if t.promptTemplate != nil {
+		return t.promptTemplate.Render(t), nil
+	}
 	cwd, err := os.Getwd()
 	if err != nil {
 		return "> ", err
 	}
+	result := shortenPath(abbreviateHome(cwd))
 
-	// Get the current user's home directory
-	home, err := os.UserHomeDir()
-	if err != nil {
-		home = ""
+	if t.lastStatus != 0 && t.statusCode {
+		result += fmt.Sprintf(" [%d]", t.lastStatus)
 	}
-
-	// Replace home directory with ~
-	if home != "" && strings.HasPrefix(cwd, home) {
-		cwd = "~" + cwd[len(home):]
+	if t.lastStatus != 0 && t.statusColor {
+		return t.colors.PromptError + result + "> " + resetColor, nil
+	}
+	if t.colors.Prompt != "" {
+		return t.colors.Prompt + result + "> " + resetColor, nil
 	}
+	return result + "> ", nil
+}
 
+// shortenPath shortens path to the last directories that fit in 20
+// characters, with ... in place of the rest
+func shortenPath(path string) string {
 	// Split the path into parts
-	parts := strings.Split(cwd, string(filepath.Separator))
+	parts := strings.Split(path, string(filepath.Separator))
 
 	// Start with just the last directory
 	result := parts[len(parts)-1]
@@ -194,11 +812,7 @@ func (t *Terminal) GetPrompt() (string, error) {
 
 	// Add parent directories if there's room
 	for i := len(parts) - 2; i >= 0; i-- {
-		part := parts[i]
-		if part == "" {
-			part = "/"
-		}
-		testResult := part + string(filepath.Separator) + result
+		testResult := parts[i] + string(filepath.Separator) + result
 		if len(testResult) > maxLen {
 			// If we can't fit the full parent, add ... and stop
 			if i > 0 {
@@ -208,95 +822,106 @@ func (t *Terminal) GetPrompt() (string, error) {
 		}
 		result = testResult
 	}
+	return result
+}
 
-	return result + "> ", nil
+// SetPromptStatus sets how the prompt shows that the last command failed:
+// in red if color is set, and with its exit status if code is set
+func (t *Terminal) SetPromptStatus(color, code bool) {
+	t.statusColor = color
+	t.statusCode = code
 }
 
 // ANSI color codes
 const (
 	greenColor = "\033[32m"
+	redColor = "\033[31m"
 	resetColor = "\033[0m"
 	clearToEndLine = "\033[K"
+	clearToEndScreen = "\033[J"
 )
 
-// ShowInlineSuggestion displays the current suggestion in green
+// ShowInlineSuggestion displays the inline suggestion for input: the newest
+// history entry starting with all of it, or else the first completion that
+// extends it
 func (t *Terminal) ShowInlineSuggestion(input string) error {
-	// Get unique completions for current input
-	completions := t.GetCompletions(input)
-	
-	// Deduplicate completions
-	seen := make(map[string]bool)
-	unique := make([]string, 0)
-	for _, comp := range completions {
-		if !seen[comp] {
-			seen[comp] = true
-			unique = append(unique, comp)
-		}
-	}
-	completions = unique
-	
-	if len(completions) == 0 {
-		// Clear any existing suggestion
-		t.currentSuggestion = ""
-		_, err := t.writer.WriteString(clearToEndLine)
-		return err
-	}
+	// Get completions for the input with the cursor at its end
+	return t.showInlineSuggestion(input, t.GetCompletions(input, utf8.RuneCountInString(input)))
+}
 
-	// Find the best completion (first unique one that starts with current input)
-	var suggestion string
-	seenSuggestions := make(map[string]bool)
-	for _, comp := range completions {
-		if strings.HasPrefix(strings.ToLower(comp), strings.ToLower(input)) && !seenSuggestions[comp] {
-			seenSuggestions[comp] = true
-			suggestion = comp
+// showInlineSuggestion displays the suggestion for input: the newest
+// history entry starting with all of it, so that `git pu` suggests the
+// whole of `git push origin main` rather than a file starting with pu, or
+// else the first of completions that extends it, ignoring case
+func (t *Terminal) showInlineSuggestion(input string, completions []Completion) error {
+	t.currentSuggestion = ""
+	if s, ok := t.historySuggestion(input); ok {
+		t.currentSuggestion = s
+		return t.render()
+	}
+	for _, c := range completions {
+		comp := c.Apply(input, utf8.RuneCountInString(input))
+		if n, ok := foldedPrefixLen(comp, input); ok && n < len(comp) {
+			t.currentSuggestion = comp
 			break
 		}
 	}
+	return t.render()
+}
 
-	if suggestion == "" {
-		// Clear any existing suggestion
-		t.currentSuggestion = ""
-		_, err := t.writer.WriteString(clearToEndLine)
-		return err
+// historySuggestion returns the newest history entry that starts with the
+// whole of input and goes further
+func (t *Terminal) historySuggestion(input string) (string, bool) {
+	if strings.TrimSpace(input) == "" {
+		return "", false
 	}
-
-	// Store the current suggestion
-	t.currentSuggestion = suggestion
-
-	// Save cursor position
-	_, err := t.writer.WriteString("\033[s")
-	if err != nil {
-		return err
-	}
-
-	// Move to rightmost position (column 60)
-	_, err = t.writer.WriteString("\033[60G")
-	if err != nil {
-		return err
+	t.historyMu.RLock()
+	defer t.historyMu.RUnlock()
+	for i := len(t.history) - 1; i >= 0; i-- {
+		if cmd := t.history[i]; len(cmd) > len(input) && strings.HasPrefix(cmd, input) {
+			return cmd, true
+		}
 	}
+	return "", false
+}
 
-	// Write the full suggestion at rightmost position
-	_, err = t.writer.WriteString("[" + greenColor + suggestion + resetColor + "]" + clearToEndLine)
-	if err != nil {
-		return err
+// foldedPrefixLen reports whether prefix is a prefix of s when compared
+// rune by rune ignoring case, and returns the length in bytes of the part of s
+// that matched. Lengths are taken from s itself since case folding can change
+// the encoded length of a character.
+func foldedPrefixLen(s, prefix string) (int, bool) {
+	i := 0
+	for _, r := range prefix {
+		if i >= len(s) {
+			return 0, false
+		}
+		sr, size := utf8.DecodeRuneInString(s[i:])
+		if sr != r && !strings.EqualFold(string(sr), string(r)) {
+			return 0, false
+		}
+		i += size
 	}
+	return i, true
+}
 
-	// Restore cursor position
-	_, err = t.writer.WriteString("\033[u")
-	if err != nil {
-		return err
+// foldedIndex returns the byte offsets in s of the first match of substr,
+// compared as foldedPrefixLen does, or -1, -1 if there is none. Offsets
+// are worked out in s itself, as case folding can change byte lengths.
+func foldedIndex(s, substr string) (start, end int) {
+	if substr == "" {
+		return 0, 0
 	}
-
-	// Show the suggestion in green, starting from where the user input ends
-	suffixPart := suggestion[len(input):]
-	_, err = t.writer.WriteString(greenColor + suffixPart + resetColor + clearToEndLine)
-	
-	// Move cursor back to end of user input
-	if err == nil {
-		_, err = t.writer.WriteString(strings.Repeat("\b", len(suffixPart)))
+	for i := range s {
+		if n, ok := foldedPrefixLen(s[i:], substr); ok {
+			return i, i + n
+		}
 	}
+	return -1, -1
+}
 
-	return t.writer.Flush()
+// KillRing returns the session's kill ring, shared by all commands
+func (t *Terminal) KillRing() *KillRing {
+	return &t.killRing
 }
 
 // AcceptSuggestion accepts the current suggestion
@@ -304,6 +929,16 @@ func (t *Terminal) AcceptSuggestion() string {
 	return t.currentSuggestion
 }
 
+// SuggestionSuffix returns the part of the current inline suggestion that
+// follows input, or "" if the suggestion doesn't extend input
+func (t *Terminal) SuggestionSuffix(input string) string {
+	n, ok := foldedPrefixLen(t.currentSuggestion, input)
+	if !ok {
+		return ""
+	}
+	return t.currentSuggestion[n:]
+}
+
 // SelectNextCompletion moves the selection to the next completion item
 func (t *Terminal) SelectNextCompletion() {
 	if len(t.currentSuggestions) > 0 {
@@ -332,212 +967,38 @@ func (t *Terminal) SelectPreviousCompletion() {
 	}
 }
 
-// GetSelectedCompletion returns the currently selected completion
-func (t *Terminal) GetSelectedCompletion() string {
-	if len(t.currentSuggestions) > 0 && t.selectedIndex >= 0 && t.selectedIndex < len(t.currentSuggestions) {
-		suggestion := t.currentSuggestions[t.selectedIndex]
-		// Remove any prefix (HIST: or CMD:)
-		if strings.Contains(suggestion, ": ") {
-			parts := strings.SplitN(suggestion, ": ", 2)
-			if len(parts) == 2 {
-				return parts[1]
-			}
-		}
-		return suggestion
-	}
-	return ""
+// Completions returns the completions in the menu, if it is open
+func (t *Terminal) Completions() []Completion {
+	return t.currentSuggestions
 }
 
-// GetCompletions returns possible completions for the current input
-func (t *Terminal) GetCompletions(input string) []string {
-	// If input is empty, show unique history items
-	if input == "" {
-		// Get unique recent history items (up to 3)
-		var uniqueHistory []string
-		var seenCommands = make(map[string]bool)
-		for i := len(t.history) - 1; i >= 0 && len(uniqueHistory) < 3; i-- {
-			cmd := t.history[i]
-			if !seenCommands[cmd] {
-				seenCommands[cmd] = true
-				uniqueHistory = append(uniqueHistory, cmd)
-			}
-		}
-
-		// Add prefix to unique history matches
-		var historyItems = make([]string, len(uniqueHistory))
-		for i, cmd := range uniqueHistory {
-			historyItems[i] = "HIST: " + cmd
-		}
-		return historyItems
-	}
-
-	// Check if input matches any unique history items (up to 3)
-	seen := make(map[string]bool)
-	historyMatches := []string{}
-	for i := len(t.history) - 1; i >= 0 && len(historyMatches) < 3; i-- {
-		if strings.HasPrefix(strings.ToLower(t.history[i]), strings.ToLower(input)) && !seen[t.history[i]] {
-			seen[t.history[i]] = true
-			historyMatches = append(historyMatches, t.history[i])
-		}
-	}
-
-	// Split input into command and current argument
-	parts := strings.Fields(input)
-	if len(parts) == 0 {
-		return historyMatches
-	}
-
-	// If we're completing the command itself
-	if len(parts) == 1 && !strings.Contains(input, " ") {
-		// Get PATH directories
-		pathDirs := strings.Split(os.Getenv("PATH"), ":")
-		
-		// Add built-in commands
-		builtins := []string{"cd", "clear", "exit", "help", "quit"}
-		completions := make(map[string]bool)
-		
-		// Add matching built-ins
-		for _, cmd := range builtins {
-			if strings.HasPrefix(cmd, parts[0]) {
-				completions[cmd] = true
-			}
-		}
-
-		// Search PATH for executables
-		for _, dir := range pathDirs {
-			files, err := os.ReadDir(dir)
-			if err != nil {
-				continue
-			}
-			for _, file := range files {
-				name := file.Name()
-				if strings.HasPrefix(name, parts[0]) {
-					completions[name] = true
-				}
-			}
-		}
-
-		// Convert map to sorted slice and add CMD: prefix
-		result := make([]string, 0, len(completions))
-		for cmd := range completions {
-			result = append(result, "CMD: "+cmd)
-		}
-		sort.Strings(result)
-		
-		// Get unique history matches
-		var uniqueHistory []string
-		var seenHistory = make(map[string]bool)
-		for i := len(t.history) - 1; i >= 0 && len(uniqueHistory) < 3; i-- {
-			cmd := t.history[i]
-			if strings.HasPrefix(strings.ToLower(cmd), strings.ToLower(parts[0])) && !seenHistory[cmd] {
-				seenHistory[cmd] = true
-				uniqueHistory = append(uniqueHistory, cmd)
-			}
-		}
-
-		// Add prefix to unique history matches
-		var historyMatches = make([]string, len(uniqueHistory))
-		for i, cmd := range uniqueHistory {
-			historyMatches[i] = "HIST: " + cmd
-		}
-		
-		// Combine history with unique commands (limit to 3 commands)
-		if len(result) > 3 {
-			result = result[:3]
-		}
-		if len(historyMatches) > 0 {
-			combined := append(historyMatches, result...)
-			return combined
-		}
-		
-		// If no history matches, limit regular completions to 6
-		if len(result) > 6 {
-			result = result[:6]
-		}
-		return result
+// OpenCompletions fills the menu with the completions for line with the
+// cursor at pos and shows it, with the first selected. It reports whether
+// there were any.
+func (t *Terminal) OpenCompletions(line string, pos int) bool {
+	t.currentSuggestions = t.GetCompletions(line, pos)
+	t.selectedIndex = 0
+	if len(t.currentSuggestions) == 0 {
+		return false
 	}
+	t.ShowCompletions()
+	return true
+}
 
-	// If we're completing a path argument
-	if len(parts) > 0 {
-		currentArg := ""
-		if strings.HasSuffix(input, " ") {
-			currentArg = ""
-		} else {
-			currentArg = parts[len(parts)-1]
-		}
-
-		// Expand ~ in path
-		if strings.HasPrefix(currentArg, "~") {
-			homeDir, err := os.UserHomeDir()
-			if err == nil {
-				currentArg = homeDir + currentArg[1:]
-			}
-		}
-
-		// Get the directory to search in
-		searchDir := "."
-		searchPrefix := ""
-		if currentArg != "" {
-			searchDir = filepath.Dir(currentArg)
-			searchPrefix = filepath.Base(currentArg)
-		}
-
-		// Read directory contents
-		files, err := os.ReadDir(searchDir)
-		if err != nil {
-			return nil
-		}
-
-		// Filter and format unique completions
-		seen := make(map[string]bool)
-		var completions []string
-		for _, file := range files {
-			name := file.Name()
-			if strings.HasPrefix(name, searchPrefix) && !seen[name] {
-				seen[name] = true
-				if file.IsDir() {
-					name += "/"
-				}
-				completions = append(completions, "CMD: "+name)
-			}
-		}
-
-		sort.Strings(completions)
-		
-		// Check if any unique history items match the current path
-		var uniqueHistory []string
-		var seenPaths = make(map[string]bool)
-		for i := len(t.history) - 1; i >= 0 && len(uniqueHistory) < 3; i-- {
-			cmd := t.history[i]
-			if strings.Contains(cmd, currentArg) && !seenPaths[cmd] {
-				seenPaths[cmd] = true
-				uniqueHistory = append(uniqueHistory, cmd)
-			}
-		}
-
-		// Add prefix to unique history matches
-		var historyMatches = make([]string, len(uniqueHistory))
-		for i, cmd := range uniqueHistory {
-			historyMatches[i] = "HIST: " + cmd
-		}
-
-		// Limit completions to 3 if we have history matches
-		if len(historyMatches) > 0 && len(completions) > 3 {
-			completions = completions[:3]
-		} else if len(completions) > 6 { // Otherwise limit to 6
-			completions = completions[:6]
-		}
-		
-		// Combine history with file completions
-		if len(historyMatches) > 0 {
-			combined := append(historyMatches, completions...)
-			return combined
-		}
-		
-		return completions
+// GetSelectedCompletion returns the currently selected completion, if any
+func (t *Terminal) GetSelectedCompletion() (Completion, bool) {
+	if len(t.currentSuggestions) > 0 && t.selectedIndex >= 0 && t.selectedIndex < len(t.currentSuggestions) {
+		return t.currentSuggestions[t.selectedIndex], true
 	}
+	return Completion{}, false
+}
 
-	return nil
+// History returns the command history, oldest first. It is safe to call
+// from background completers.
+func (t *Terminal) History() []string {
+	t.historyMu.RLock()
+	defer t.historyMu.RUnlock()
+	return t.history
 }
 
 // ANSI color codes
@@ -549,273 +1010,238 @@ const (
 	Reset    = "\033[0m"  // Reset formatting
 )
 
-// ClearCompletions clears the dropdown completion menu
+// ClearCompletions clears the completion menu below the input, leaving the
+// cursor where it was
 func (t *Terminal) ClearCompletions() error {
-	// Save cursor position
-	_, err := t.writer.WriteString("\033[s")
-	if err != nil {
-		return err
+	t.staleMenu = false
+	if t.menu == nil && !t.menuShown() {
+		return nil
 	}
+	t.menu = nil
+	return t.render()
+}
 
-	// Move cursor down one line
-	_, err = t.writer.WriteString("\r\n")
-	if err != nil {
-		return err
-	}
+// menuShown reports whether a completion menu is on the screen. It goes by
+// what was last drawn, which is what has to be cleared, rather than by
+// what is due to be drawn.
+func (t *Terminal) menuShown() bool {
+	return t.painted != nil && len(t.painted.rows) > t.painted.inputRows
+}
 
-	// Clear several lines below (enough to cover the dropdown)
-	for i := 0; i < 12; i++ {
-		_, err = t.writer.WriteString("\033[K\r\n")
-		if err != nil {
-			return err
-		}
-	}
+// ResetCompletions clears the completion menu and forgets its items, so
+// that nothing is selected until new completions are shown
+func (t *Terminal) ResetCompletions() error {
+	t.currentSuggestions = nil
+	t.selectedIndex = 0
+	t.menuActive = false
+	return t.ClearCompletions()
+}
 
-	// Restore cursor position
-	_, err = t.writer.WriteString("\033[u")
-	if err != nil {
+// HideSuggestions clears the dropdown menu and the inline suggestion and
+// forgets the current completion state
+func (t *Terminal) HideSuggestions() error {
+	t.currentSuggestion = ""
+	if err := t.ResetCompletions(); err != nil {
 		return err
 	}
-
-	return t.writer.Flush()
+	return t.render()
 }
 
-// ShowCompletions displays the current completion suggestions in a dropdown with yellow background
-func (t *Terminal) ShowCompletions() error {
-	// First clear any existing dropdown
-	if err := t.ClearCompletions(); err != nil {
-		return err
-	}
+// AddToHistory adds a command to history and saves it
+func (t *Terminal) AddToHistory(cmd string) error {
+	return t.AddHistoryEntry(HistoryEntry{Command: cmd, Time: time.Now()})
+}
 
-	if len(t.currentSuggestions) == 0 {
+// AddHistoryEntry adds a command that ran at e.Time for e.Duration to
+// history and saves it
+func (t *Terminal) AddHistoryEntry(e HistoryEntry) error {
+	if e.Command == "" || t.ignoredByHistory(e.Command) {
 		return nil
 	}
 
-	// Get terminal width
-	cmd := exec.Command("tput", "cols")
-	output, err := cmd.Output()
-	if err != nil {
+	// Count the use for ranking completions, even when it repeats the last
+	// command
+	if err := t.stats.Record(e.Command); err != nil {
 		return err
 	}
-	termWidth, err := strconv.Atoi(strings.TrimSpace(string(output)))
-	if err != nil {
-		termWidth = 80 // fallback width
-	}
-
-	// Save cursor position
-	_, err = t.writer.WriteString("\033[s")
-	if err != nil {
-		return err
-	}
-
-	// Draw dropdown box with yellow background
-	maxWidth := 25
-	maxItems := 6 // Maximum number of items to show in dropdown
 
-	// Limit the number of suggestions shown
-	shownSuggestions := t.currentSuggestions
-	if len(shownSuggestions) > maxItems {
-		shownSuggestions = shownSuggestions[:maxItems]
-	}
-
-	// Calculate rightmost position
-	rightPos := termWidth - maxWidth - 2 // -2 for box borders
-
-	// Draw top border at rightmost position
-	_, err = t.writer.WriteString(fmt.Sprintf("\033[%dG", rightPos))
-	if err != nil {
-		return err
-	}
-	_, err = t.writer.WriteString("┌" + strings.Repeat("─", maxWidth+1) + "┐\r\n") // +1 for arrow space
-	if err != nil {
-		return err
-	}
-
-	// First suggestion will be highlighted in green
-
-	// Draw suggestions
-	for i, suggestion := range shownSuggestions {
-		// Move to rightmost position
-		_, err = t.writer.WriteString(fmt.Sprintf("\033[%dG", rightPos))
-		if err != nil {
-			return err
-		}
-
-		// Pad suggestion to fixed width (account for arrow space)
-		padded := suggestion
-		if len(padded) > maxWidth-3 { // -3 to account for arrow space
-			padded = padded[:maxWidth-6] + "..."
-		} else {
-			padded = padded + strings.Repeat(" ", maxWidth-3-len(padded))
-		}
-
-		// Determine background color based on type and position
-		background := YellowBg
-		
-		// Use green for selected item
-		if i == t.selectedIndex {
-			background = GreenBg
-		}
-		
-		// Use blue background for history items
-		if strings.HasPrefix(suggestion, "HIST: ") {
-			if i == t.selectedIndex {
-				background = "\033[44m" // Bright blue for selected history
-			} else {
-				background = BlueBg
-			}
-		}
-
-		// Add arrow indicator for selected item
-		indicator := "  " // Two spaces for non-selected items
-		if i == t.selectedIndex {
-			indicator = "► " // Arrow with space for selected item
-		}
-
-		// Write with colored background and black text
-		_, err = t.writer.WriteString("│" + indicator + background + BlackFg + padded + Reset + "│\r\n")
-		if err != nil {
-			return err
-		}
-	}
-
-	// Move to rightmost position and draw bottom border
-	_, err = t.writer.WriteString(fmt.Sprintf("\033[%dG", rightPos))
-	if err != nil {
-		return err
+	// Don't add duplicates of the last command
+	if len(t.history) > 0 && t.history[len(t.history)-1] == e.Command {
+		return nil
 	}
-	_, err = t.writer.WriteString("└" + strings.Repeat("─", maxWidth+1) + "┘") // +1 for arrow space
-	if err != nil {
-		return err
+	if t.historyOptions.EraseDups {
+		return t.addHistoryEraseDups(e)
 	}
 
-	// Restore cursor position
-	_, err = t.writer.WriteString("\033[u")
-	if err != nil {
-		return err
-	}
-
-	return t.writer.Flush()
-}
-
-// AddToHistory adds a command to history and saves it
-func (t *Terminal) AddToHistory(cmd string) error {
-	// Don't add empty commands or duplicates of the last command
-	if cmd == "" || (len(t.history) > 0 && t.history[len(t.history)-1] == cmd) {
-		return nil
-	}
+	// Append to the file rather than rewriting it, so concurrent sessions
+	// don't lose each other's commands. Their new commands are merged in
+	// first.
+	err := t.appendHistory(e)
 
 	// Add to memory
-	t.history = append(t.history, cmd)
-
-	// Trim history to last 1000 commands
-	if len(t.history) > 1000 {
-		t.history = t.history[len(t.history)-1000:]
-	}
-
-	// Save to file
-	return t.saveHistory()
+	t.historyMu.Lock()
+	t.setHistory(append(t.entries, e))
+	t.historyMu.Unlock()
+	return err
 }
 
-// GetPreviousHistory moves back in history
-func (t *Terminal) GetPreviousHistory() string {
+// GetPreviousHistory moves back in history. current is the line being
+// edited: when navigation starts it is kept as a draft for GetNextHistory to
+// return to, and when it is a changed history entry the change is kept
+// until the line is accepted, without altering the history itself.
+func (t *Terminal) GetPreviousHistory(current string) string {
+	// Pick up commands run in other sessions before navigating
+	if t.historyIndex == -1 {
+		t.SyncHistory()
+	}
 	if len(t.history) == 0 {
 		return ""
 	}
 
-	// First time pressing up arrow
 	if t.historyIndex == -1 {
+		// First time pressing up arrow
+		t.historyDraft = current
+		t.historyEdits = nil
 		t.historyIndex = len(t.history) - 1
-	} else if t.historyIndex > 0 {
-		// Move back in history
-		t.historyIndex--
+	} else {
+		t.saveHistoryEdit(current)
+		if t.historyIndex > 0 {
+			// Move back in history
+			t.historyIndex--
+		}
 	}
 
-	return t.history[t.historyIndex]
+	return t.historyLine(t.historyIndex)
 }
 
-// GetNextHistory moves forward in history
-func (t *Terminal) GetNextHistory() string {
+// GetNextHistory moves forward in history, returning the draft saved by
+// GetPreviousHistory after the newest entry. current is the line being
+// edited, which is returned unchanged when not navigating history.
+func (t *Terminal) GetNextHistory(current string) string {
 	if t.historyIndex == -1 || len(t.history) == 0 {
-		return ""
+		return current
 	}
 
+	t.saveHistoryEdit(current)
 	if t.historyIndex < len(t.history)-1 {
 		// Move forward in history
 		t.historyIndex++
-		return t.history[t.historyIndex]
-	} else {
-		// Reached the end of history
-		t.historyIndex = -1
-		return ""
+		return t.historyLine(t.historyIndex)
 	}
+
+	// Reached the end of history, back to the line being typed before
+	draft := t.historyDraft
+	t.ResetHistoryIndex()
+	return draft
 }
 
-// ResetHistoryIndex resets the history navigation index
+// saveHistoryEdit remembers current as the edited form of the history entry
+// being shown, if it differs from the entry
+func (t *Terminal) saveHistoryEdit(current string) {
+	if t.historyIndex < 0 || t.historyIndex >= len(t.history) {
+		return
+	}
+	if current == t.history[t.historyIndex] {
+		delete(t.historyEdits, t.historyIndex)
+		return
+	}
+	if t.historyEdits == nil {
+		t.historyEdits = make(map[int]string)
+	}
+	t.historyEdits[t.historyIndex] = current
+}
+
+// historyLine returns history entry i as last edited during navigation
+func (t *Terminal) historyLine(i int) string {
+	if edited, ok := t.historyEdits[i]; ok {
+		return edited
+	}
+	return t.history[i]
+}
+
+// ResetHistoryIndex resets the history navigation index, dropping the
+// saved draft and any edits to history entries
 func (t *Terminal) ResetHistoryIndex() {
 	t.historyIndex = -1
+	t.historyDraft = ""
+	t.historyEdits = nil
 }
 
-// loadHistory loads command history from file
+// loadHistory loads command history from file. Errors are wrapped in
+// ErrHistoryIO.
 func (t *Terminal) loadHistory() error {
-	// Create history directory if it doesn't exist
-	homeDir, err := os.UserHomeDir()
+	path, err := t.historyPath()
 	if err != nil {
-		return fmt.Errorf("could not get home directory: %v", err)
+		return historyError(err)
 	}
 
-	// Set history file path
-	t.historyFile = filepath.Join(homeDir, ".go_term_history")
-
 	// Try to read existing history file
-	data, err := os.ReadFile(t.historyFile)
+	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			// Create empty history file
-			if err := os.WriteFile(t.historyFile, []byte{}, 0600); err != nil {
-				return fmt.Errorf("could not create history file: %v", err)
+			if err := os.WriteFile(path, []byte{}, 0600); err != nil {
+				return historyError(err)
 			}
 			// Initialize empty history
-			t.history = []string{}
+			t.setHistory(nil)
 			return nil
 		}
-		return err
+		return historyError(err)
 	}
 
-	// Split by newlines and filter empty lines
-	t.history = []string{}
-	lines := strings.Split(string(data), "\n")
-	for _, cmd := range lines {
-		if cmd != "" {
-			t.history = append(t.history, cmd)
-		}
-	}
+	t.setHistory(parseHistory(string(data)))
+	t.historyOffset = int64(len(data))
 
 	return nil
 }
 
-// saveHistory saves command history to file
+// saveHistory replaces the history file with the history in memory
 func (t *Terminal) saveHistory() error {
-	// Make sure we have a valid history file path
-	if t.historyFile == "" {
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			return fmt.Errorf("could not get home directory: %v", err)
-		}
-		t.historyFile = filepath.Join(homeDir, ".go_term_history")
-	}
+	t.historyMu.RLock()
+	entries := t.entries
+	t.historyMu.RUnlock()
 
-	// Join history with newlines and save
-	data := strings.Join(t.history, "\n")
-	return os.WriteFile(t.historyFile, []byte(data), 0600)
+	return t.withHistoryFile(func(f *os.File) error {
+		return rewriteHistoryFile(f, entries)
+	})
 }
 
-// StartHistorySearch enters history search mode
-func (t *Terminal) StartHistorySearch() {
+// StartHistorySearch enters history search mode, searching towards newer
+// entries if forward is set. original is the line being edited, which is
+// returned by AbortHistorySearch.
+func (t *Terminal) StartHistorySearch(original string, forward bool) {
+	t.SyncHistory()
 	t.searchMode = true
 	t.searchQuery = ""
 	t.searchResults = nil
 	t.searchIndex = -1
+	t.searchForward = forward
+	t.searchOriginal = original
+}
+
+// AbortHistorySearch exits history search mode and returns the line that
+// was being edited when the search started
+func (t *Terminal) AbortHistorySearch() string {
+	original := t.searchOriginal
+	t.ExitHistorySearch()
+	return original
+}
+
+// StepHistorySearch moves to the next older match, or the next newer one if
+// forward is set, and searches in that direction from then on. ok is false
+// when there is no match further in that direction.
+func (t *Terminal) StepHistorySearch(forward bool) (result string, ok bool) {
+	t.searchForward = forward
+	index := t.searchIndex + 1
+	if forward {
+		index = t.searchIndex - 1
+	}
+	if index < 0 || index >= len(t.searchResults) {
+		return "", false
+	}
+	t.searchIndex = index
+	return t.searchResults[index], true
 }
 
 // ExitHistorySearch exits history search mode
@@ -837,10 +1263,25 @@ func (t *Terminal) UpdateHistorySearch(query string) []string {
 	t.searchResults = nil
 	t.searchIndex = -1
 
-	// Search through history in reverse order
-	for i := len(t.history) - 1; i >= 0; i-- {
-		if strings.Contains(strings.ToLower(t.history[i]), strings.ToLower(query)) {
-			t.searchResults = append(t.searchResults, t.history[i])
+	// Search through history in reverse order, each command once
+	commands := newestFirst(t.history)
+	if t.matchMode == MatchFuzzy {
+		// Rank by how well each entry matches, most recent first among equals
+		var matches []Completion
+		for _, cmd := range commands {
+			if score, _, ok := fuzzyMatch(query, cmd); ok {
+				matches = append(matches, Completion{Text: cmd, Score: score})
+			}
+		}
+		sortByScore(matches)
+		for _, m := range matches {
+			t.searchResults = append(t.searchResults, m.Text)
+		}
+	} else {
+		for _, cmd := range commands {
+			if start, _ := foldedIndex(cmd, query); start >= 0 {
+				t.searchResults = append(t.searchResults, cmd)
+			}
 		}
 	}
 
@@ -875,13 +1316,45 @@ func (t *Terminal) GetPreviousSearchResult() string {
 	return t.searchResults[t.searchIndex]
 }
 
+// SearchQuery returns the text being searched for in history search mode
+func (t *Terminal) SearchQuery() string {
+	return t.searchQuery
+}
+
 // GetSearchPrompt returns the search prompt with current query
 func (t *Terminal) GetSearchPrompt() string {
+	if t.searchForward {
+		return fmt.Sprintf("(i-search)`%s': ", t.searchQuery)
+	}
 	return fmt.Sprintf("(reverse-i-search)`%s': ", t.searchQuery)
 }
 
-// Clear clears the terminal screen and resets cursor position
+// HighlightSearchMatch renders a search result with the part matching the
+// query in inverse video
+func (t *Terminal) HighlightSearchMatch(result string) string {
+	if t.searchQuery == "" {
+		return result
+	}
+	var positions []int
+	if t.matchMode == MatchFuzzy {
+		_, positions, _ = fuzzyMatch(t.searchQuery, result)
+	} else if start, end := foldedIndex(result, t.searchQuery); start >= 0 {
+		// Offsets are found in result itself, as case folding can change
+		// byte lengths
+		first := utf8.RuneCountInString(result[:start])
+		for n := range []rune(result[start:end]) {
+			positions = append(positions, first+n)
+		}
+	}
+	return styleRunes(result, positions, inverseVideo, "\033[27m")
+}
+
+// Clear clears the terminal screen and resets cursor position. It does
+// nothing in line mode.
 func (t *Terminal) Clear() error {
+	if t.LineMode() {
+		return nil
+	}
 	_, err := t.writer.WriteString("\033[2J\033[H")
 	if err != nil {
 		return err
@@ -0,0 +1,261 @@
+package goterm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestFoldedPrefixLen(t *testing.T) {
+	tests := []struct {
+		s, prefix string
+		want      int
+		ok        bool
+	}{
+		{s: "git status", prefix: "", want: 0, ok: true},
+		{s: "git status", prefix: "git", want: 3, ok: true},
+		{s: "Git status", prefix: "git", want: 3, ok: true},
+		{s: "git status", prefix: "GIT S", want: 5, ok: true},
+		{s: "git status", prefix: "got", ok: false},
+		// Lengths are those of s, which case folding may change
+		{s: "Ärger", prefix: "är", want: 3, ok: true},
+		{s: "ſtatus", prefix: "st", want: 3, ok: true},
+		{s: "KELVIN", prefix: "K", want: 1, ok: true},
+		{s: "日本語", prefix: "日本", want: 6, ok: true},
+		// Input longer than the suggestion never matches
+		{s: "git", prefix: "git status", ok: false},
+		{s: "", prefix: "a", ok: false},
+	}
+	for _, tt := range tests {
+		got, ok := foldedPrefixLen(tt.s, tt.prefix)
+		if ok != tt.ok || (ok && got != tt.want) {
+			t.Errorf("foldedPrefixLen(%q, %q) = %d, %v, want %d, %v", tt.s, tt.prefix, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSuggestionSuffix(t *testing.T) {
+	tests := []struct {
+		suggestion, input, want string
+	}{
+		{suggestion: "git status", input: "git", want: " status"},
+		{suggestion: "Git status", input: "git", want: " status"},
+		{suggestion: "git status", input: "GIT ST", want: "atus"},
+		{suggestion: "échó hello", input: "ÉCHÓ", want: " hello"},
+		{suggestion: "日本語", input: "日本", want: "語"},
+		{suggestion: "git", input: "git status", want: ""},
+		{suggestion: "git status", input: "git status", want: ""},
+		{suggestion: "ls -l", input: "git", want: ""},
+		{suggestion: "", input: "git", want: ""},
+	}
+	term := newTerminal(nil, io.Discard)
+	for _, tt := range tests {
+		term.currentSuggestion = tt.suggestion
+		if got := term.SuggestionSuffix(tt.input); got != tt.want {
+			t.Errorf("SuggestionSuffix(%q) with suggestion %q = %q, want %q", tt.input, tt.suggestion, got, tt.want)
+		}
+	}
+}
+
+// failingWriter takes at most limit bytes, then fails with err, or writes
+// short without an error if err is nil
+type failingWriter struct {
+	bytes.Buffer
+	limit int
+	err   error
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) <= w.limit {
+		w.limit -= len(p)
+		return w.Buffer.Write(p)
+	}
+	n, _ := w.Buffer.Write(p[:w.limit])
+	w.limit = 0
+	return n, w.err
+}
+
+func TestLineWriter(t *testing.T) {
+	tests := []struct {
+		input string
+		raw   bool
+		want  string
+	}{
+		{input: "", want: ""},
+		{input: "ls", want: "ls"},
+		{input: "a\nb\n", want: "a\r\nb\r\n"},
+		{input: "\n\n", want: "\r\n\r\n"},
+		// Line endings already \r\n are left alone
+		{input: "a\r\nb\r\n", want: "a\r\nb\r\n"},
+		{input: "\r\n\n", want: "\r\n\r\n"},
+		{input: "a\r\n\nb\r\r\n", want: "a\r\n\r\nb\r\r\n"},
+		{input: "a\n\r\n", want: "a\r\n\r\n"},
+		{input: "a\nb\n", raw: true, want: "a\nb\n"},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		w := &lineWriter{w: &b, raw: tt.raw}
+		n, err := w.Write([]byte(tt.input))
+		if err != nil || n != len(tt.input) {
+			t.Errorf("Write(%q) = %d, %v, want %d, nil", tt.input, n, err, len(tt.input))
+		}
+		if b.String() != tt.want {
+			t.Errorf("Write(%q) wrote %q, want %q", tt.input, b.String(), tt.want)
+		}
+	}
+}
+
+func TestLineWriterFailing(t *testing.T) {
+	errFull := errors.New("disk full")
+	tests := []struct {
+		input       string
+		limit       int
+		err         error
+		ignoreEPIPE bool
+		raw         bool
+		wantN       int
+		wantErr     error
+		wrote       string
+	}{
+		{input: "abc", limit: 2, err: errFull, wantN: 2, wantErr: errFull, wrote: "ab"},
+		{input: "abc", limit: 2, raw: true, err: errFull, wantN: 2, wantErr: errFull, wrote: "ab"},
+		{input: "abc", limit: 2, wantN: 2, wantErr: io.ErrShortWrite, wrote: "ab"},
+		// A \n only counts once all of the \r\n it became is written
+		{input: "a\nb", limit: 1, err: errFull, wantN: 1, wantErr: errFull, wrote: "a"},
+		{input: "a\nb", limit: 2, err: errFull, wantN: 1, wantErr: errFull, wrote: "a\r"},
+		{input: "a\nb", limit: 3, err: errFull, wantN: 2, wantErr: errFull, wrote: "a\r\n"},
+		{input: "a\r\nb", limit: 2, err: errFull, wantN: 2, wantErr: errFull, wrote: "a\r"},
+		{input: "a\nb", limit: 2, wantN: 1, wantErr: io.ErrShortWrite, wrote: "a\r"},
+		// A closed pipe can be ignored
+		{input: "a\nb", limit: 1, err: syscall.EPIPE, wantN: 1, wantErr: syscall.EPIPE, wrote: "a"},
+		{input: "a\nb", limit: 1, err: syscall.EPIPE, ignoreEPIPE: true, wantN: 3, wrote: "a"},
+		{input: "a\nb", limit: 1, err: fmt.Errorf("write: %w", syscall.EPIPE), ignoreEPIPE: true, wantN: 3, wrote: "a"},
+	}
+	for _, tt := range tests {
+		fw := &failingWriter{limit: tt.limit, err: tt.err}
+		w := &lineWriter{w: fw, raw: tt.raw, ignoreEPIPE: tt.ignoreEPIPE}
+		n, err := w.Write([]byte(tt.input))
+		if n != tt.wantN || !errors.Is(err, tt.wantErr) {
+			t.Errorf("Write(%q) failing after %d bytes = %d, %v, want %d, %v", tt.input, tt.limit, n, err, tt.wantN, tt.wantErr)
+		}
+		if fw.String() != tt.wrote {
+			t.Errorf("Write(%q) failing after %d bytes wrote %q, want %q", tt.input, tt.limit, fw.String(), tt.wrote)
+		}
+	}
+}
+
+func TestLineWriterSplitWrites(t *testing.T) {
+	tests := []struct {
+		writes []string
+		want   string
+	}{
+		{writes: []string{"a\r", "\nb"}, want: "a\r\nb"},
+		{writes: []string{"a\r", "\n", "\n"}, want: "a\r\n\r\n"},
+		{writes: []string{"a", "\n"}, want: "a\r\n"},
+		{writes: []string{"a\r", "b\n"}, want: "a\rb\r\n"},
+		{writes: []string{"\r", "", "\n"}, want: "\r\n"},
+		{writes: []string{"a\n", "\n"}, want: "a\r\n\r\n"},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		w := &lineWriter{w: &b}
+		for _, s := range tt.writes {
+			if _, err := w.Write([]byte(s)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if b.String() != tt.want {
+			t.Errorf("writing %q wrote %q, want %q", tt.writes, b.String(), tt.want)
+		}
+	}
+
+	// A \r that was only partly written doesn't count
+	fw := &failingWriter{limit: 1, err: errors.New("disk full")}
+	w := &lineWriter{w: fw}
+	w.Write([]byte("a\r"))
+	fw.limit = 10
+	w.Write([]byte("\n"))
+	if want := "a\r\n"; fw.String() != want {
+		t.Errorf("writing \\n after a failed \\r wrote %q, want %q", fw.String(), want)
+	}
+}
+
+func TestInlineSuggestionSource(t *testing.T) {
+	bin, dir := t.TempDir(), t.TempDir()
+	writeExecutables(t, bin, "zqalpha")
+	t.Setenv("PATH", bin)
+	for _, name := range []string{"pudding.txt", "purple.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	term := newTerminal(nil, io.Discard)
+	term.historyFile = filepath.Join(t.TempDir(), "history")
+	for _, cmd := range []string{
+		"cat " + dir + "/purple.txt",
+		"cat " + dir + "/pumpkin.txt",
+		"zqalpha --all",
+	} {
+		if err := term.AddToHistory(cmd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		// The newest history entry starting with the whole line comes
+		// first, even over a file that matches the last word
+		{input: "cat " + dir + "/pu", want: "cat " + dir + "/pumpkin.txt"},
+		{input: "cat " + dir + "/pur", want: "cat " + dir + "/purple.txt"},
+		{input: "zqa", want: "zqalpha --all"},
+		// Without one, the first completion
+		{input: "cat " + dir + "/pud", want: "cat " + dir + "/pudding.txt"},
+		{input: "zqalpha --all", want: ""},
+		{input: "zqalpha -", want: "zqalpha --all"},
+		// History is matched on the whole line, not the last word
+		{input: "echo " + dir + "/pum", want: ""},
+		{input: " ", want: ""},
+	}
+	for _, tt := range tests {
+		if err := term.ShowInlineSuggestion(tt.input); err != nil {
+			t.Fatal(err)
+		}
+		if got := term.currentSuggestion; got != tt.want {
+			t.Errorf("suggestion for %q = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestHighlightSearchMatch(t *testing.T) {
+	const on, off = inverseVideo, "\033[27m"
+	tests := []struct {
+		result, query string
+		want          string
+	}{
+		{result: "git status", query: "", want: "git status"},
+		{result: "git status", query: "STAT", want: "git " + on + "stat" + off + "us"},
+		{result: "git status", query: "xyz", want: "git status"},
+		{result: "echo Ärger", query: "är", want: "echo " + on + "Är" + off + "ger"},
+		{result: "echo 日本語", query: "本", want: "echo 日" + on + "本" + off + "語"},
+		// Case folding that changes byte lengths
+		{result: "ȺȺȺȺȺȺx", query: "x", want: "ȺȺȺȺȺȺ" + on + "x" + off},
+		{result: "ȺȺȺȺȺȺx", query: "ⱥx", want: "ȺȺȺȺȺ" + on + "Ⱥx" + off},
+		{result: "cd İstanbul; ls", query: "ls", want: "cd İstanbul; " + on + "ls" + off},
+		{result: "cd İstanbul", query: "tan", want: "cd İs" + on + "tan" + off + "bul"},
+	}
+	for _, tt := range tests {
+		term := newTerminal(nil, io.Discard)
+		term.searchQuery = tt.query
+		if got := term.HighlightSearchMatch(tt.result); got != tt.want {
+			t.Errorf("HighlightSearchMatch(%q) for %q = %q, want %q", tt.result, tt.query, got, tt.want)
+		}
+	}
+}
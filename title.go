@@ -0,0 +1,81 @@
+package goterm
+
+import (
+	"os"
+	"strings"
+)
+
+// maxTitleWidth is the most columns of a running command shown in the title
+const maxTitleWidth = 60
+
+// Escape sequences that save the window title before it is first set and
+// restore it when the terminal is released. Terminals without a stack of
+// titles ignore them.
+const (
+	pushTitle = "\033[22;0t"
+	popTitle  = "\033[23;0t"
+)
+
+// SetWindowTitles turns on or off setting the terminal window's title, to
+// the working directory at the prompt and to the command while one runs.
+// The title is left alone on terminals that have none, such as the Linux
+// console.
+func (t *Terminal) SetWindowTitles(on bool) {
+	t.windowTitles = on
+}
+
+// oscSupported reports whether the terminal takes operating system
+// commands, such as setting its window title, rather than showing them
+func (t *Terminal) oscSupported() bool {
+	if t.LineMode() {
+		return false
+	}
+	switch os.Getenv("TERM") {
+	case "dumb", "linux", "cons25", "vt100", "vt220":
+		return false
+	}
+	return true
+}
+
+// setTitle sets the window title to title, if titles are turned on and it
+// isn't already showing
+func (t *Terminal) setTitle(title string) {
+	if !t.windowTitles || !t.oscSupported() || title == t.title {
+		return
+	}
+	if t.title == "" {
+		t.writer.WriteString(pushTitle)
+	}
+	t.title = title
+	// A control character would end the sequence early
+	t.writer.WriteString("\033]0;" + SanitizeInput(title) + "\a")
+	t.flush()
+}
+
+// showPromptTitle sets the window title to the working directory, as it is
+// while waiting at the prompt
+func (t *Terminal) showPromptTitle() {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return
+	}
+	t.setTitle("go-term: " + shortenPath(abbreviateHome(cwd)))
+}
+
+// showCommandTitle sets the window title to line while it runs, shortened
+// to its first line and maxTitleWidth columns
+func (t *Terminal) showCommandTitle(line string) {
+	line = strings.TrimSpace(line)
+	if first, _, more := strings.Cut(line, "\n"); more || stringWidth(line) > maxTitleWidth {
+		line = truncateWidth(first, maxTitleWidth-1) + "…"
+	}
+	t.setTitle(line)
+}
+
+// restoreTitle puts back the title the window had before it was first set
+func (t *Terminal) restoreTitle() {
+	if t.title != "" {
+		t.writer.WriteString(popTitle)
+		t.title = ""
+	}
+}
@@ -0,0 +1,35 @@
+//go:build unix
+
+package goterm
+
+import (
+	"fmt"
+
+	"github.com/pkg/term"
+)
+
+// controllingTerminal is the terminal NewTerminal reads key presses from
+const controllingTerminal = "/dev/tty"
+
+// unixTerminal is a terminal device in raw mode
+type unixTerminal struct {
+	*term.Term
+}
+
+// openRaw opens the terminal at path in raw mode
+func openRaw(path string) (rawTerminal, error) {
+	t, err := term.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNotATTY, err)
+	}
+	if err := term.RawMode(t); err != nil {
+		t.Close()
+		return nil, fmt.Errorf("%w: failed to set raw mode: %w", ErrNotATTY, err)
+	}
+	return unixTerminal{t}, nil
+}
+
+// RawMode implements rawTerminal
+func (t unixTerminal) RawMode() error {
+	return term.RawMode(t.Term)
+}
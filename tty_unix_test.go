@@ -0,0 +1,25 @@
+//go:build unix
+
+package goterm
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestNotATTYError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := openRaw(path)
+	if !errors.Is(err, ErrNotATTY) {
+		t.Errorf("openRaw(%q) error %v doesn't wrap ErrNotATTY", path, err)
+	}
+	if !errors.Is(err, syscall.ENOTTY) {
+		t.Errorf("openRaw(%q) error %v doesn't wrap its cause", path, err)
+	}
+}
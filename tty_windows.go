@@ -0,0 +1,162 @@
+package goterm
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/term"
+)
+
+// controllingTerminal is the console NewTerminal reads key presses from
+const controllingTerminal = "CONIN$"
+
+// sizePollInterval is how often the console's size is checked, as there is
+// no signal telling of resizes
+const sizePollInterval = 250 * time.Millisecond
+
+var (
+	kernel32                          = windows.NewLazySystemDLL("kernel32.dll")
+	procGetNumberOfConsoleInputEvents = kernel32.NewProc("GetNumberOfConsoleInputEvents")
+	procPeekConsoleInputW             = kernel32.NewProc("PeekConsoleInputW")
+)
+
+// keyEvent is the event type of an inputRecord for a key press or release
+const keyEvent = 0x0001
+
+// inputRecord is the layout of an INPUT_RECORD holding a key event
+type inputRecord struct {
+	eventType       uint16
+	_               uint16
+	keyDown         int32
+	repeatCount     uint16
+	virtualKeyCode  uint16
+	virtualScanCode uint16
+	unicodeChar     uint16
+	controlKeyState uint32
+}
+
+// consoleTerminal is the Windows console in raw mode. Key presses are read
+// as the escape sequences of a VT terminal, and the ANSI sequences written
+// to it are interpreted.
+type consoleTerminal struct {
+	in      *os.File    // The console's input
+	out     *os.File    // The console's screen buffer
+	state   *term.State // Mode of the input before raw mode
+	outMode uint32      // Mode of the screen buffer before raw mode
+	done    chan struct{}
+	once    sync.Once
+}
+
+// openRaw opens the console in raw mode. A process has only the one console,
+// which is opened whatever the path.
+func openRaw(string) (rawTerminal, error) {
+	in, err := os.OpenFile(controllingTerminal, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNotATTY, err)
+	}
+	out, err := os.OpenFile("CONOUT$", os.O_RDWR, 0)
+	if err != nil {
+		in.Close()
+		return nil, fmt.Errorf("%w: %w", ErrNotATTY, err)
+	}
+	c := &consoleTerminal{in: in, out: out, done: make(chan struct{})}
+	if err := windows.GetConsoleMode(windows.Handle(out.Fd()), &c.outMode); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("%w: failed to set raw mode: %w", ErrNotATTY, err)
+	}
+	if err := c.RawMode(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Read implements io.Reader
+func (c *consoleTerminal) Read(p []byte) (int, error) {
+	return c.in.Read(p)
+}
+
+// Available implements inputSource by peeking at the console's input
+// events. Only presses of keys that produce characters count, as reading
+// skips the rest.
+func (c *consoleTerminal) Available() (int, error) {
+	handle := c.in.Fd()
+	var events uint32
+	if r, _, err := procGetNumberOfConsoleInputEvents.Call(handle, uintptr(unsafe.Pointer(&events))); r == 0 {
+		return 0, err
+	}
+	if events == 0 {
+		return 0, nil
+	}
+	records := make([]inputRecord, events)
+	var read uint32
+	if r, _, err := procPeekConsoleInputW.Call(handle, uintptr(unsafe.Pointer(&records[0])), uintptr(events), uintptr(unsafe.Pointer(&read))); r == 0 {
+		return 0, err
+	}
+	n := 0
+	for _, record := range records[:read] {
+		if record.eventType == keyEvent && record.keyDown != 0 && record.unicodeChar != 0 {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// RawMode implements rawTerminal. The input is put in raw mode with VT
+// input, and the screen buffer has escape sequences interpreted and line
+// feeds left to move down only, as on a terminal in raw mode.
+func (c *consoleTerminal) RawMode() error {
+	state, err := term.MakeRaw(int(c.in.Fd()))
+	if err != nil {
+		return fmt.Errorf("failed to set raw mode: %v", err)
+	}
+	c.state = state
+	mode := c.outMode | windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING | windows.DISABLE_NEWLINE_AUTO_RETURN
+	if err := windows.SetConsoleMode(windows.Handle(c.out.Fd()), mode); err != nil {
+		return fmt.Errorf("failed to enable VT processing: %v", err)
+	}
+	return nil
+}
+
+// Restore implements rawTerminal
+func (c *consoleTerminal) Restore() error {
+	if c.state != nil {
+		if err := term.Restore(int(c.in.Fd()), c.state); err != nil {
+			return err
+		}
+	}
+	return windows.SetConsoleMode(windows.Handle(c.out.Fd()), c.outMode)
+}
+
+// Close implements rawTerminal, stopping watchSize
+func (c *consoleTerminal) Close() error {
+	c.once.Do(func() { close(c.done) })
+	c.out.Close()
+	return c.in.Close()
+}
+
+// watchSize implements sizeWatcher by checking the size of the console's
+// window every sizePollInterval
+func (c *consoleTerminal) watchSize(resized func()) {
+	go func() {
+		ticker := time.NewTicker(sizePollInterval)
+		defer ticker.Stop()
+		cols, rows, _ := term.GetSize(int(c.out.Fd()))
+		for {
+			select {
+			case <-c.done:
+				return
+			case <-ticker.C:
+			}
+			newCols, newRows, err := term.GetSize(int(c.out.Fd()))
+			if err == nil && (newCols != cols || newRows != rows) {
+				cols, rows = newCols, newRows
+				resized()
+			}
+		}
+	}()
+}